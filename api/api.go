@@ -0,0 +1,109 @@
+// Package api is the HTTP layer of the server: a Router that enforces methods and body
+// limits, tags every request with an ID and answers browser CORS requests, and helpers
+// that write JSON results and JSON error envelopes.
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error is the body of every error response:
+//
+//	{"error": {"code": "not_found", "message": "Signal not found", "request_id": "5f2b..."}}
+type Error struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Error codes by HTTP status.
+var codes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusInternalServerError:   "internal_error",
+	http.StatusServiceUnavailable:    "unavailable",
+}
+
+// Code returns the error code of an HTTP status.
+func Code(status int) string {
+	if code, ok := codes[status]; ok {
+		return code
+	}
+	return "error"
+}
+
+// WriteJSON writes v as the JSON body of a response with the given status.
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// WriteError writes an error envelope with the code of status.
+func WriteError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	WriteJSON(w, status, map[string]Error{"error": {Code: Code(status), Message: message, RequestID: RequestID(r.Context())}})
+}
+
+// WriteInternalError logs err with the request ID and answers 500 with a message that
+// only says what failed, e.g. "Failed to fetch signals", so internals do not leak.
+func WriteInternalError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	fmt.Printf("[%s] %s: %v\n", RequestID(r.Context()), message, err)
+	WriteError(w, r, http.StatusInternalServerError, message)
+}
+
+// WriteBodyError answers a failure to read the request body: 413 if it exceeded the
+// router's limit, 400 otherwise.
+func WriteBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		WriteError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body larger than %d bytes", tooLarge.Limit))
+		return
+	}
+	WriteError(w, r, http.StatusBadRequest, "Failed to read request body")
+}
+
+type requestIDKey struct{}
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID returns the ID of the request ctx belongs to, or "" outside a request.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithRequestID returns a context carrying the request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID accepts IDs set by a proxy or client if they are short and printable.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
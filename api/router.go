@@ -0,0 +1,169 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxBodyBytes limits request bodies if Options.MaxBodyBytes is zero.
+const DefaultMaxBodyBytes = 1 << 20
+
+// Options configure a Router.
+type Options struct {
+	MaxBodyBytes int64 // DefaultMaxBodyBytes if zero
+
+	// CORSOrigins are the browser origins allowed to call the server, e.g. a dashboard
+	// at "https://dash.example.com"; "*" allows all. Without origins CORS is not enabled.
+	CORSOrigins []string
+}
+
+// Router routes requests by method and path. Requests for other paths get a JSON 404,
+// other methods a JSON 405 with the Allow header. Every response carries the request ID.
+type Router struct {
+	opts Options
+	mux  *http.ServeMux
+
+	mu     sync.Mutex
+	routes map[string]*route
+}
+
+func NewRouter(opts Options) *Router {
+	if opts.MaxBodyBytes == 0 {
+		opts.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	rt := &Router{opts: opts, mux: http.NewServeMux(), routes: make(map[string]*route)}
+	rt.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		WriteError(w, r, http.StatusNotFound, "No route for "+r.URL.Path)
+	})
+	return rt
+}
+
+// Handle registers h for requests with method to pattern, a ServeMux pattern without method.
+func (rt *Router) Handle(method, pattern string, h http.Handler) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rr, ok := rt.routes[pattern]
+	if !ok {
+		rr = &route{handlers: make(map[string]http.Handler)}
+		rt.routes[pattern] = rr
+		rt.mux.Handle(pattern, rr)
+	}
+	rr.handlers[method] = h
+}
+
+// HandleFunc registers the handler function f for requests with method to pattern.
+func (rt *Router) HandleFunc(method, pattern string, f http.HandlerFunc) {
+	rt.Handle(method, pattern, f)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		id = newRequestID()
+	}
+	r = r.WithContext(WithRequestID(r.Context(), id))
+	w.Header().Set(RequestIDHeader, id)
+
+	defer func() {
+		if p := recover(); p != nil {
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			fmt.Printf("[%s] panic serving %s %s: %v\n%s", id, r.Method, r.URL.Path, p, debug.Stack())
+			WriteError(w, r, http.StatusInternalServerError, "Internal error")
+		}
+	}()
+
+	if rt.cors(w, r) {
+		return
+	}
+	if r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, rt.opts.MaxBodyBytes)
+	}
+	rt.mux.ServeHTTP(w, r)
+}
+
+// cors sets the CORS headers for allowed origins. It reports true if it answered a preflight request.
+func (rt *Router) cors(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(rt.opts.CORSOrigins) == 0 {
+		return false
+	}
+	allowed := ""
+	for _, o := range rt.opts.CORSOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			allowed = o
+			break
+		}
+	}
+	if allowed == "" {
+		return false
+	}
+
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	if allowed == "*" {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+	}
+	h.Set("Access-Control-Expose-Headers", RequestIDHeader+", X-Next-Cursor, Content-Disposition")
+
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		return false
+	}
+	h.Set("Access-Control-Allow-Methods", strings.Join(rt.methods(r), ", "))
+	h.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Webhook-Token, X-Signature, X-Timestamp, "+RequestIDHeader)
+	h.Set("Access-Control-Max-Age", strconv.Itoa(600))
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// methods returns the methods of the route matching r.
+func (rt *Router) methods(r *http.Request) []string {
+	if h, pattern := rt.mux.Handler(r); pattern != "" {
+		if rr, ok := h.(*route); ok {
+			return rr.methods()
+		}
+	}
+	return nil
+}
+
+type route struct {
+	handlers map[string]http.Handler // By method
+}
+
+func (rr *route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, ok := rr.handlers[r.Method]
+	if !ok && r.Method == http.MethodHead {
+		h, ok = rr.handlers[http.MethodGet]
+	}
+	if !ok {
+		w.Header().Set("Allow", strings.Join(rr.methods(), ", "))
+		WriteError(w, r, http.StatusMethodNotAllowed, fmt.Sprintf("Method %s not allowed", r.Method))
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+func (rr *route) methods() []string {
+	methods := make([]string, 0, len(rr.handlers))
+	for m := range rr.handlers {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// Deprecated marks the responses of h, e.g. of an unversioned path, as deprecated.
+func Deprecated(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		h.ServeHTTP(w, r)
+	})
+}
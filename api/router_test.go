@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestRouter() *Router {
+	rt := NewRouter(Options{MaxBodyBytes: 16, CORSOrigins: []string{"https://dash.example.com"}})
+	rt.HandleFunc(http.MethodGet, "/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "0" {
+			WriteInternalError(w, r, "Failed to fetch item", errors.New("database is locked"))
+			return
+		}
+		WriteJSON(w, http.StatusOK, map[string]string{"id": r.PathValue("id")})
+	})
+	rt.HandleFunc(http.MethodPost, "/items", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			WriteBodyError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	rt.HandleFunc(http.MethodGet, "/panic", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	return rt
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) Error {
+	t.Helper()
+	var body struct{ Error Error }
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding error body: %v", err)
+	}
+	if body.Error.RequestID == "" || body.Error.RequestID != rec.Header().Get(RequestIDHeader) {
+		t.Errorf("request id %q, header %q", body.Error.RequestID, rec.Header().Get(RequestIDHeader))
+	}
+	return body.Error
+}
+
+func TestRouter(t *testing.T) {
+	rt := newTestRouter()
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rec
+	}
+
+	if rec := serve(http.MethodGet, "/items/7", ""); rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"id":"7"}` {
+		t.Errorf("GET: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(http.MethodPost, "/items", "small"); rec.Code != http.StatusCreated {
+		t.Errorf("POST: %d %s", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		method, target, body string
+		status               int
+		code                 string
+	}{
+		{http.MethodDelete, "/items/7", "", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{http.MethodGet, "/missing", "", http.StatusNotFound, "not_found"},
+		{http.MethodPost, "/items", strings.Repeat("x", 17), http.StatusRequestEntityTooLarge, "payload_too_large"},
+		{http.MethodGet, "/items/0", "", http.StatusInternalServerError, "internal_error"},
+		{http.MethodGet, "/panic", "", http.StatusInternalServerError, "internal_error"},
+	}
+	for _, tt := range tests {
+		rec := serve(tt.method, tt.target, tt.body)
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.target, rec.Code, tt.status)
+			continue
+		}
+		e := decodeError(t, rec)
+		if e.Code != tt.code || strings.Contains(e.Message, "locked") {
+			t.Errorf("%s %s: error %+v", tt.method, tt.target, e)
+		}
+	}
+	if rec := serve(http.MethodDelete, "/items/7", ""); rec.Header().Get("Allow") != "GET" {
+		t.Errorf("Allow = %q", rec.Header().Get("Allow"))
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	rt := newTestRouter()
+	r := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	r.Header.Set(RequestIDHeader, "lb-1234")
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, r)
+	if got := rec.Header().Get(RequestIDHeader); got != "lb-1234" {
+		t.Errorf("request id = %q", got)
+	}
+
+	r.Header.Set(RequestIDHeader, "bad id\n")
+	rec = httptest.NewRecorder()
+	rt.ServeHTTP(rec, r)
+	if got := rec.Header().Get(RequestIDHeader); len(got) != 16 {
+		t.Errorf("generated request id = %q", got)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	rt := newTestRouter()
+	preflight := httptest.NewRequest(http.MethodOptions, "/items/1", nil)
+	preflight.Header.Set("Origin", "https://dash.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "GET")
+	rec := httptest.NewRecorder()
+	rt.ServeHTTP(rec, preflight)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://dash.example.com" ||
+		rec.Header().Get("Access-Control-Allow-Methods") != "GET" {
+		t.Errorf("preflight: %d %v", rec.Code, rec.Header())
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+	r.Header.Set("Origin", "https://evil.example.com")
+	rec = httptest.NewRecorder()
+	rt.ServeHTTP(rec, r)
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("other origin: %d %v", rec.Code, rec.Header())
+	}
+}
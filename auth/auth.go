@@ -0,0 +1,57 @@
+// Package auth authenticates webhook requests: shared secrets in a header, the query
+// string or the body, HMAC-SHA256 body signatures, and IP allowlists. Secrets are
+// compared in constant time, and several secrets can be valid at once so they can be
+// rotated without downtime.
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+)
+
+// Authenticator checks that a webhook request comes from a trusted sender.
+// Body is the request body, which has already been read.
+type Authenticator interface {
+	Authenticate(r *http.Request, body []byte) error
+}
+
+// ErrUnauthorized is wrapped by all authentication failures. The messages of
+// the wrapping errors say why, but never include the presented credentials.
+var ErrUnauthorized = errors.New("unauthorized")
+
+func unauthorized(reason string) error {
+	return &authError{reason}
+}
+
+type authError struct{ reason string }
+
+func (e *authError) Error() string { return "unauthorized: " + e.reason }
+func (e *authError) Unwrap() error { return ErrUnauthorized }
+
+// All requires every authenticator to accept a request, e.g. an IP allowlist and a token.
+type All []Authenticator
+
+func (all All) Authenticate(r *http.Request, body []byte) error {
+	if len(all) == 0 {
+		return unauthorized("no authentication configured")
+	}
+	for _, a := range all {
+		if err := a.Authenticate(r, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchSecret reports whether s equals one of secrets. Empty secrets never match.
+// Every secret is compared, so the time taken does not depend on which one matches.
+func matchSecret(s []byte, secrets []string) bool {
+	match := 0
+	for _, secret := range secrets {
+		if secret != "" {
+			match |= subtle.ConstantTimeCompare(s, []byte(secret))
+		}
+	}
+	return match == 1
+}
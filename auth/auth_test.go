@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToken(t *testing.T) {
+	a := &Token{Secrets: []string{"new", "old"}}
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		body   string
+		ok     bool
+	}{
+		{"body", "/", nil, `{"token":"new"}`, true},
+		{"old secret", "/", nil, `{"token":"old"}`, true},
+		{"query", "/?token=new", nil, `{}`, true},
+		{"header", "/", http.Header{DefaultTokenHeader: {"old"}}, ``, true},
+		{"bearer", "/", http.Header{"Authorization": {"Bearer new"}}, ``, true},
+		{"header before body", "/", http.Header{DefaultTokenHeader: {"wrong"}}, `{"token":"new"}`, false},
+		{"wrong", "/", nil, `{"token":"newer"}`, false},
+		{"missing", "/", nil, `{}`, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, tt.target, nil)
+		for k, v := range tt.header {
+			r.Header[k] = v
+		}
+		err := a.Authenticate(r, []byte(tt.body))
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+		if err != nil && (!errors.Is(err, ErrUnauthorized) || strings.Contains(err.Error(), "wrong")) {
+			t.Errorf("%s: error %q", tt.name, err)
+		}
+	}
+
+	if err := (&Token{Secrets: []string{""}}).Authenticate(httptest.NewRequest(http.MethodPost, "/", nil), []byte(`{"token":""}`)); err == nil {
+		t.Error("empty secret accepted")
+	}
+}
+
+func TestHMAC(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	a := &HMAC{Secrets: []string{"s1", "s2"}, now: func() time.Time { return now }}
+	body := []byte(`{"pair":"XBTUSD"}`)
+	request := func(sig string, sent time.Time) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.Header.Set(SignatureHeader, sig)
+		r.Header.Set(TimestampHeader, strconv.FormatInt(sent.Unix(), 10))
+		return r
+	}
+
+	if err := a.Authenticate(request(Sign("s2", now, body), now), body); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Authenticate(request(Sign("s2", now, body), now), body); err == nil || !strings.Contains(err.Error(), "replayed") {
+		t.Errorf("replay: err = %v", err)
+	}
+	sent := now.Add(-time.Minute)
+	if err := a.Authenticate(request(Sign("s1", sent, body), sent), []byte(`{"pair":"ETHUSD"}`)); err == nil {
+		t.Error("accepted a modified body")
+	}
+	if err := a.Authenticate(request(Sign("s3", sent, body), sent), body); err == nil {
+		t.Error("accepted an unknown secret")
+	}
+	if err := a.Authenticate(request(Sign("s1", sent, body), now), body); err == nil {
+		t.Error("accepted a changed timestamp")
+	}
+	old := now.Add(-DefaultWindow - time.Second)
+	if err := a.Authenticate(request(Sign("s1", old, body), old), body); err == nil || !strings.Contains(err.Error(), "window") {
+		t.Errorf("old request: err = %v", err)
+	}
+}
+
+func TestIPAllowlist(t *testing.T) {
+	allowed, err := ParsePrefixes("tradingview, 203.0.113.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxies, _ := ParsePrefixes("10.0.0.0/8")
+	a := &IPAllowlist{Allowed: allowed, TrustedProxies: proxies}
+
+	tests := []struct {
+		remote, forwarded string
+		ok                bool
+	}{
+		{"52.89.214.238:443", "", true},
+		{"203.0.113.7:5000", "", true},
+		{"198.51.100.1:5000", "", false},
+		{"198.51.100.1:5000", "52.89.214.238", false}, // Not from a trusted proxy
+		{"10.0.0.2:80", "52.89.214.238", true},
+		{"10.0.0.2:80", "52.89.214.238, 10.0.0.5", true},
+		{"10.0.0.2:80", "52.89.214.238, 198.51.100.1", false}, // Spoofed entry left of the client
+		{"10.0.0.2:80", "", false},
+		{"[::ffff:52.89.214.238]:443", "", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwarded)
+		}
+		if err := a.Authenticate(r, nil); (err == nil) != tt.ok {
+			t.Errorf("%s via %q: err = %v", tt.remote, tt.forwarded, err)
+		}
+	}
+
+	if _, err := ParsePrefixes("10.0.0.300"); err == nil {
+		t.Error("parsed an invalid address")
+	}
+	if p, _ := ParsePrefixes("10.1.2.3/8"); p[0] != netip.MustParsePrefix("10.0.0.0/8") {
+		t.Errorf("prefix = %v", p)
+	}
+}
+
+func TestAll(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.RemoteAddr = "52.89.214.238:443"
+	ips, _ := ParsePrefixes("tradingview")
+	a := All{&IPAllowlist{Allowed: ips}, &Token{Secrets: []string{"s"}}}
+	if err := a.Authenticate(r, []byte(`{"token":"s"}`)); err != nil {
+		t.Error(err)
+	}
+	if err := a.Authenticate(r, []byte(`{"token":"x"}`)); err == nil {
+		t.Error("accepted a wrong token")
+	}
+	if err := (All{}).Authenticate(r, nil); err == nil {
+		t.Error("accepted a request without authenticators")
+	}
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TradingViewIPs are the addresses TradingView sends webhook alerts from, as published
+// in https://www.tradingview.com/support/solutions/43000529348-about-webhooks/.
+var TradingViewIPs = []string{"52.89.214.238", "34.212.75.30", "54.218.53.128", "52.32.178.7"}
+
+// IPAllowlist accepts requests from the allowed addresses. Behind a reverse proxy the
+// client address is taken from X-Forwarded-For: the entries added by trusted proxies
+// are skipped from the right, and the first other entry is the client. The header is
+// ignored for requests that do not come from a trusted proxy, so clients cannot spoof it.
+type IPAllowlist struct {
+	Allowed        []netip.Prefix
+	TrustedProxies []netip.Prefix
+}
+
+// ParsePrefixes parses a comma separated list of addresses and CIDR ranges.
+// "tradingview" stands for TradingViewIPs.
+func ParsePrefixes(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		switch {
+		case field == "":
+		case strings.EqualFold(field, "tradingview"):
+			for _, ip := range TradingViewIPs {
+				prefixes = append(prefixes, netip.PrefixFrom(netip.MustParseAddr(ip), 32))
+			}
+		case strings.Contains(field, "/"):
+			p, err := netip.ParsePrefix(field)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, p.Masked())
+		default:
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes, nil
+}
+
+func (l *IPAllowlist) Authenticate(r *http.Request, body []byte) error {
+	ip, err := l.ClientIP(r)
+	if err != nil {
+		return unauthorized(err.Error())
+	}
+	if !contains(l.Allowed, ip) {
+		return unauthorized(fmt.Sprintf("address %s not allowed", ip))
+	}
+	return nil
+}
+
+// ClientIP returns the address of the client that sent r.
+func (l *IPAllowlist) ClientIP(r *http.Request) (netip.Addr, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid remote address %q", r.RemoteAddr)
+	}
+	ip = ip.Unmap()
+	if !contains(l.TrustedProxies, ip) {
+		return ip, nil
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("invalid X-Forwarded-For entry %q", hops[i])
+		}
+		ip = hop.Unmap()
+		if !contains(l.TrustedProxies, ip) {
+			break
+		}
+	}
+	return ip, nil
+}
+
+func contains(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// DefaultTokenHeader is the header Token reads the secret from if Header is empty.
+const DefaultTokenHeader = "X-Webhook-Token"
+
+// Token accepts requests that present one of its secrets, in order of precedence as
+// "Authorization: Bearer <secret>", in the Header, in the "token" query parameter or
+// in the "token" field of a JSON body. TradingView cannot set headers, so its alerts
+// put the token in the body or the URL.
+type Token struct {
+	Secrets []string // All accepted secrets; list the old and the new one while rotating
+	Header  string   // DefaultTokenHeader if empty
+}
+
+func (t *Token) Authenticate(r *http.Request, body []byte) error {
+	token := presentedToken(r, body, t.Header)
+	if token == "" {
+		return unauthorized("missing token")
+	}
+	if !matchSecret([]byte(token), t.Secrets) {
+		return unauthorized("invalid token")
+	}
+	return nil
+}
+
+func presentedToken(r *http.Request, body []byte, header string) string {
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return bearer
+	}
+	if header == "" {
+		header = DefaultTokenHeader
+	}
+	if token := r.Header.Get(header); token != "" {
+		return token
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	var payload struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(body, &payload)
+	return payload.Token
+}
@@ -0,0 +1,235 @@
+// Package chart renders candlestick charts with trade markers as PNG images.
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"time"
+	"tvwh2k/kraken"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Marker marks an execution: buys are drawn as a green triangle below the price,
+// sells as a red triangle above it.
+type Marker struct {
+	Time  time.Time
+	Price float64
+	Side  string // buy or sell
+}
+
+// Level is a horizontal price line, e.g. a take profit or stop loss.
+type Level struct {
+	Price float64
+	Label string
+	Color color.Color // Gray if nil
+}
+
+// Chart is a candlestick chart.
+type Chart struct {
+	Title   string
+	Candles []kraken.Candle // Oldest first, evenly spaced
+	Markers []Marker
+	Levels  []Level
+
+	Width, Height int // 800x400 if zero
+}
+
+var (
+	background = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	gridColor  = color.RGBA{0xe6, 0xe6, 0xe6, 0xff}
+	textColor  = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	upColor    = color.RGBA{0x26, 0xa6, 0x9a, 0xff}
+	downColor  = color.RGBA{0xef, 0x53, 0x50, 0xff}
+	levelColor = color.RGBA{0x90, 0x90, 0x90, 0xff}
+)
+
+// Colors for levels.
+var (
+	Red  color.Color = downColor
+	Blue color.Color = color.RGBA{0x29, 0x62, 0xff, 0xff}
+)
+
+// Margins around the plot area; the right margin holds the price axis.
+const (
+	marginLeft   = 8
+	marginRight  = 72
+	marginTop    = 22
+	marginBottom = 20
+)
+
+// PNG renders the chart.
+func (c *Chart) PNG() ([]byte, error) {
+	if len(c.Candles) == 0 {
+		return nil, fmt.Errorf("chart has no candles")
+	}
+	w, h := c.Width, c.Height
+	if w == 0 || h == 0 {
+		w, h = 800, 400
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+
+	p := plot{
+		img:    img,
+		left:   marginLeft,
+		right:  w - marginRight,
+		top:    marginTop,
+		bottom: h - marginBottom,
+	}
+	p.min, p.max = c.priceRange()
+	p.slot = float64(p.right-p.left) / float64(len(c.Candles))
+
+	p.drawGrid()
+	for i, candle := range c.Candles {
+		p.drawCandle(i, candle)
+	}
+	for _, l := range c.Levels {
+		col := l.Color
+		if col == nil {
+			col = levelColor
+		}
+		y := p.y(l.Price)
+		p.dashedLine(y, col)
+		p.text(p.right+4, y+4, l.Label, col)
+	}
+	for _, m := range c.Markers {
+		if i, ok := c.candleAt(m.Time); ok {
+			p.marker(i, m)
+		}
+	}
+
+	p.text(marginLeft, 15, c.Title, textColor)
+	for _, i := range []int{0, len(c.Candles) / 2, len(c.Candles) - 1} {
+		label := c.Candles[i].Time.UTC().Format("01-02 15:04")
+		x := p.x(i) - len(label)*7/2
+		x = max(p.left, min(x, p.right-len(label)*7))
+		p.text(x, h-5, label, textColor)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// priceRange returns the padded price range covering all candles, levels and markers.
+func (c *Chart) priceRange() (float64, float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, candle := range c.Candles {
+		lo, hi = math.Min(lo, candle.Low), math.Max(hi, candle.High)
+	}
+	for _, l := range c.Levels {
+		lo, hi = math.Min(lo, l.Price), math.Max(hi, l.Price)
+	}
+	for _, m := range c.Markers {
+		lo, hi = math.Min(lo, m.Price), math.Max(hi, m.Price)
+	}
+	pad := (hi - lo) * 0.06
+	if pad == 0 {
+		pad = math.Max(hi*0.01, 1e-9)
+	}
+	return lo - pad, hi + pad
+}
+
+// candleAt returns the index of the candle containing t. Times after the last
+// candle belong to the last candle.
+func (c *Chart) candleAt(t time.Time) (int, bool) {
+	if t.Before(c.Candles[0].Time) {
+		return 0, false
+	}
+	i := len(c.Candles) - 1
+	for i > 0 && c.Candles[i].Time.After(t) {
+		i--
+	}
+	return i, true
+}
+
+type plot struct {
+	img                      *image.RGBA
+	left, right, top, bottom int
+	min, max                 float64
+	slot                     float64 // Width per candle
+}
+
+func (p *plot) x(i int) int {
+	return p.left + int((float64(i)+0.5)*p.slot)
+}
+
+func (p *plot) y(price float64) int {
+	return p.top + int((p.max-price)/(p.max-p.min)*float64(p.bottom-p.top))
+}
+
+func (p *plot) drawGrid() {
+	const lines = 5
+	for i := 0; i <= lines; i++ {
+		price := p.min + (p.max-p.min)*float64(i)/lines
+		y := p.y(price)
+		p.fill(p.left, y, p.right, y+1, gridColor)
+		p.text(p.right+4, y+4, FormatPrice(price), textColor)
+	}
+	p.fill(p.right, p.top, p.right+1, p.bottom, gridColor)
+}
+
+func (p *plot) drawCandle(i int, c kraken.Candle) {
+	col := upColor
+	if c.Close < c.Open {
+		col = downColor
+	}
+	x := p.x(i)
+	p.fill(x, p.y(c.High), x+1, p.y(c.Low)+1, col)
+
+	half := max(int(p.slot*0.35), 1)
+	top, bottom := p.y(math.Max(c.Open, c.Close)), p.y(math.Min(c.Open, c.Close))
+	p.fill(x-half+1, top, x+half, max(bottom, top+1), col)
+}
+
+func (p *plot) dashedLine(y int, col color.Color) {
+	for x := p.left; x < p.right; x += 8 {
+		p.fill(x, y, min(x+5, p.right), y+1, col)
+	}
+}
+
+// marker draws a triangle pointing at the price: up below it for buys, down above it for sells.
+func (p *plot) marker(i int, m Marker) {
+	const size = 7
+	x, y := p.x(i), p.y(m.Price)
+	col, dir := upColor, 1
+	if m.Side == "sell" {
+		col, dir = downColor, -1
+	}
+	for row := 0; row < size; row++ {
+		yy := y + dir*(3+row)
+		p.fill(x-row, yy, x+row+1, yy+1, col)
+	}
+}
+
+func (p *plot) fill(x0, y0, x1, y1 int, col color.Color) {
+	draw.Draw(p.img, image.Rect(x0, y0, x1, y1), image.NewUniform(col), image.Point{}, draw.Src)
+}
+
+func (p *plot) text(x, y int, s string, col color.Color) {
+	d := font.Drawer{Dst: p.img, Src: image.NewUniform(col), Face: basicfont.Face7x13, Dot: fixed.P(x, y)}
+	d.DrawString(s)
+}
+
+// FormatPrice formats a price with a precision that suits its magnitude.
+func FormatPrice(price float64) string {
+	switch abs := math.Abs(price); {
+	case abs >= 1000:
+		return fmt.Sprintf("%.0f", price)
+	case abs >= 1:
+		return fmt.Sprintf("%.2f", price)
+	default:
+		return fmt.Sprintf("%.5g", price)
+	}
+}
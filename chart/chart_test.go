@@ -0,0 +1,60 @@
+package chart
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+	"tvwh2k/kraken"
+)
+
+func TestPNG(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var candles []kraken.Candle
+	for i := 0; i < 20; i++ {
+		open, close := 100.0+float64(i), 101.0+float64(i)
+		if i%2 == 1 {
+			open, close = close, open
+		}
+		candles = append(candles, kraken.Candle{Time: start.Add(time.Duration(i) * time.Hour), Open: open, High: 103 + float64(i), Low: 98 + float64(i), Close: close})
+	}
+	c := &Chart{
+		Title:   "XBTUSD 1h",
+		Candles: candles,
+		Markers: []Marker{{Time: start.Add(2 * time.Hour), Price: 102, Side: "buy"}, {Time: start.Add(15 * time.Hour), Price: 116, Side: "sell"}},
+		Levels:  []Level{{Price: 125, Label: "TP", Color: Blue}, {Price: 95, Label: "SL", Color: Red}},
+		Width:   400,
+		Height:  200,
+	}
+	data, err := c.PNG()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 400 || b.Dy() != 200 {
+		t.Fatalf("size = %v", b)
+	}
+
+	var up, down bool
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			switch {
+			case r>>8 == 0x26 && g>>8 == 0xa6 && b>>8 == 0x9a:
+				up = true
+			case r>>8 == 0xef && g>>8 == 0x53 && b>>8 == 0x50:
+				down = true
+			}
+		}
+	}
+	if !up || !down {
+		t.Errorf("up candles drawn %v, down candles drawn %v", up, down)
+	}
+
+	if _, err := (&Chart{}).PNG(); err == nil {
+		t.Error("rendered a chart without candles")
+	}
+}
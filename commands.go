@@ -0,0 +1,345 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"tvwh2k/config"
+	"tvwh2k/database"
+	"tvwh2k/export"
+	"tvwh2k/kraken"
+	"tvwh2k/maintenance"
+)
+
+// runCommand executes the subcommand name with its arguments.
+func runCommand(cfg *config.Config, name string, args []string) error {
+	switch name {
+	case "backup":
+		return runBackup(cfg, args)
+	case "export":
+		return runExport(cfg, args)
+	case "migrate":
+		return runMigrate(cfg, args)
+	case "sweep":
+		return runSweep(cfg, args)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+// runSweep withdraws the realized profit of the trades quoted in an asset, above a threshold
+// left on the account, to an allowlisted withdrawal key. Swept profit is recorded so it is
+// only withdrawn once.
+//
+//	tvwh2k sweep -asset ZEUR -key my-bank [-threshold 100] [-min 50] [-dry-run]
+func runSweep(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("sweep", flag.ContinueOnError)
+	var in kraken.SweepInput
+	fs.StringVar(&in.Asset, "asset", "", "quote asset to sweep as named in the balance (e.g. ZEUR)")
+	fs.StringVar(&in.Key, "key", "", "withdrawal key, must be listed in KRAKEN_WITHDRAW_KEYS")
+	fs.Float64Var(&in.Threshold, "threshold", 0, "realized profit to keep on the account")
+	fs.Float64Var(&in.MinAmount, "min", 0, "minimum amount worth sweeping")
+	fs.BoolVar(&in.DryRun, "dry-run", false, "only report what would be withdrawn")
+	method := fs.String("method", cfg.Database.PnLMethod, "cost method for the realized profit: fifo, lifo or average")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if in.Asset == "" || in.Key == "" {
+		return fmt.Errorf("sweep: -asset and -key are required")
+	}
+	costMethod, err := database.ParseCostMethod(*method)
+	if err != nil {
+		return fmt.Errorf("sweep: %w", err)
+	}
+
+	k, err := newKrakenClient(cfg)
+	if err != nil {
+		return err
+	}
+	if k == nil {
+		return fmt.Errorf("sweep: KRAKEN_API_KEY and KRAKEN_API_SECRET must be set")
+	}
+	db, err := database.Open(string(cfg.Database.URL))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	profit, err := realizedProfit(db, k, costMethod, in.Asset)
+	if err != nil {
+		return fmt.Errorf("sweep: %w", err)
+	}
+	swept, err := db.SweptAmount(in.Asset)
+	if err != nil {
+		return fmt.Errorf("sweep: %w", err)
+	}
+	in.Profit = profit - swept
+
+	res, err := k.Sweep(in)
+	if err != nil {
+		return fmt.Errorf("sweep: %w", err)
+	}
+
+	switch {
+	case res.Amount == "":
+		fmt.Printf("Unswept profit %s %g does not exceed threshold %g by at least %g, nothing to sweep.\n", in.Asset, in.Profit, in.Threshold, in.MinAmount)
+	case in.DryRun:
+		fmt.Printf("Would withdraw %s %s of %g unswept profit to %q (fee %s).\n", res.Amount, in.Asset, in.Profit, in.Key, res.Fee)
+	default:
+		fmt.Printf("Withdrew %s %s to %q (fee %s), refid %s.\n", res.Amount, in.Asset, in.Key, res.Fee, res.RefID)
+		amount, _ := strconv.ParseFloat(res.Amount, 64)
+		if err := db.SaveSweep(in.Asset, in.Key, amount, res.RefID); err != nil {
+			return fmt.Errorf("sweep: recording withdrawal %s: %w", res.RefID, err)
+		}
+	}
+	return nil
+}
+
+// realizedProfit sums the realized PnL of the pairs quoted in asset.
+func realizedProfit(db *database.DB, k *kraken.Kraken, method database.CostMethod, asset string) (float64, error) {
+	report, err := db.ComputePnL(method)
+	if err != nil {
+		return 0, err
+	}
+	quotes := make(map[string]string)
+	var profit float64
+	for _, r := range report.Realized {
+		quote, ok := quotes[r.Pair]
+		if !ok {
+			pairs, err := k.AssetPairs(r.Pair)
+			if err != nil {
+				return 0, fmt.Errorf("asset pair %s: %w", r.Pair, err)
+			}
+			for _, info := range pairs {
+				quote = info.Quote
+			}
+			quotes[r.Pair] = quote
+		}
+		if quote == asset {
+			profit += r.PnL
+		}
+	}
+	return profit, nil
+}
+
+// runMigrate shows or changes the schema version of the database.
+//
+//	tvwh2k migrate status
+//	tvwh2k migrate up [version]
+//	tvwh2k migrate down [steps]
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate status|up [version]|down [steps]")
+	}
+	n := 0
+	if len(args) > 1 {
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil || n < 0 {
+			return fmt.Errorf("migrate %s: invalid number %q", args[0], args[1])
+		}
+	}
+
+	db, err := database.Open(string(cfg.Database.URL))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "status":
+		statuses, err := db.MigrationStatuses()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-40s %s\n", s.Version, s.Name, applied)
+		}
+	case "up":
+		versions, err := db.MigrateUp(n)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied migrations: %v\n", versions)
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		versions, err := db.MigrateDown(n)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted migrations: %v\n", versions)
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+	return nil
+}
+
+// runExport writes a CSV report of trades, fills or tax data.
+//
+//	tvwh2k export -report gains -year 2024 [-fiat EUR] [-method fifo] [-o gains-2024.csv]
+//	tvwh2k export -report koinly -from 2024-01-01 -to 2024-07-01
+func runExport(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	report := fs.String("report", "trades", "report to write: "+strings.Join(export.Reports, ", "))
+	year := fs.Int("year", 0, "calendar year to export (UTC)")
+	from := fs.String("from", "", "start of the period (YYYY-MM-DD, inclusive)")
+	to := fs.String("to", "", "end of the period (YYYY-MM-DD, exclusive)")
+	fiat := fs.String("fiat", "", "currency for the gains report (e.g. EUR)")
+	method := fs.String("method", cfg.Database.PnLMethod, "cost method for the gains report: fifo, lifo or average")
+	out := fs.String("o", "", "output file (default stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := export.Options{}
+	if *year != 0 {
+		opts = export.Year(*year)
+	}
+	for _, v := range []struct {
+		s   string
+		dst *time.Time
+	}{{*from, &opts.From}, {*to, &opts.To}} {
+		if v.s == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", v.s)
+		if err != nil {
+			return fmt.Errorf("export: invalid date %q", v.s)
+		}
+		*v.dst = t
+	}
+	var err error
+	if opts.Method, err = database.ParseCostMethod(*method); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	opts.Fiat = strings.ToUpper(*fiat)
+
+	db, err := database.Open(string(cfg.Database.URL))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Kraken is optional: without it ledger entries and currency conversion are unavailable.
+	k, err := newKrakenClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := export.New(db, k).Write(w, *report, opts); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	return nil
+}
+
+// runBackup creates, lists or restores database backups, or prunes old signal payloads.
+//
+//	tvwh2k backup create
+//	tvwh2k backup list
+//	tvwh2k backup restore <name or path>
+//	tvwh2k backup prune
+func runBackup(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: backup create|list|restore <backup>|prune")
+	}
+
+	switch args[0] {
+	case "list":
+		dir := cfg.Maintenance.BackupDir
+		if dir == "" {
+			return fmt.Errorf("backup list: BACKUP_DIR must be set")
+		}
+		backups, err := maintenance.Backups(dir)
+		if err != nil {
+			return err
+		}
+		for _, b := range backups {
+			fmt.Printf("%-32s %s %10d bytes\n", b.Name, b.Time.Format("2006-01-02 15:04:05"), b.Size)
+		}
+		return nil
+	case "restore":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: backup restore <name or path>")
+		}
+		return runRestore(cfg, args[1])
+	}
+
+	db, err := database.Open(string(cfg.Database.URL))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	m := newMaintainer(cfg, db)
+
+	switch args[0] {
+	case "create":
+		if m.BackupDir == "" {
+			return fmt.Errorf("backup create: BACKUP_DIR must be set")
+		}
+		path, err := m.Backup()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Database backed up to %s.\n", path)
+		removed, err := m.Rotate()
+		for _, b := range removed {
+			fmt.Printf("Removed old backup %s.\n", b.Path)
+		}
+		return err
+	case "prune":
+		if m.Retention == 0 {
+			return fmt.Errorf("backup prune: SIGNAL_RETENTION_DAYS must be set")
+		}
+		n, err := db.PruneSignals(time.Now().Add(-m.Retention))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Pruned payloads of %d signals.\n", n)
+		return nil
+	default:
+		return fmt.Errorf("unknown backup command %q", args[0])
+	}
+}
+
+// runRestore replaces the SQLite database with a backup, given by name (in BACKUP_DIR) or path.
+// The server must be stopped while restoring.
+func runRestore(cfg *config.Config, backup string) error {
+	dbFile, ok := database.SQLiteFile(string(cfg.Database.URL))
+	if !ok {
+		return fmt.Errorf("backup restore: %w", database.ErrBackupUnsupported)
+	}
+	if dir := cfg.Maintenance.BackupDir; dir != "" {
+		if _, err := os.Stat(backup); os.IsNotExist(err) {
+			backup = filepath.Join(dir, backup)
+		}
+	}
+
+	saved, err := maintenance.Restore(backup, dbFile)
+	if err != nil {
+		return fmt.Errorf("backup restore: %w", err)
+	}
+	if saved != "" {
+		fmt.Printf("Previous database saved as %s.\n", saved)
+	}
+	fmt.Printf("Restored %s from %s.\n", dbFile, backup)
+	return nil
+}
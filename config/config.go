@@ -0,0 +1,286 @@
+// Package config loads the settings of the server from an optional YAML or TOML file
+// and the environment, which overrides the file. Secrets can be read from files, e.g.
+// Docker secrets, and the whole configuration is validated before it is used.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable with the path of the configuration file.
+const FileEnv = "CONFIG_FILE"
+
+// Config holds all settings. The env tags name the environment variables that override
+// the file; see env.go for how they are parsed.
+type Config struct {
+	Server      Server              `yaml:"server"`
+	Database    Database            `yaml:"database"`
+	Kraken      Kraken              `yaml:"kraken"`
+	Futures     Futures             `yaml:"futures"`
+	Earn        Earn                `yaml:"earn"`
+	Webhook     Webhook             `yaml:"webhook"`
+	Strategies  map[string]Strategy `yaml:"strategies"` // By name; "*" sets the topic of other strategies
+	Telegram    Telegram            `yaml:"telegram"`
+	Notify      Notify              `yaml:"notify"`
+	Reports     Reports             `yaml:"reports"`
+	Maintenance Maintenance         `yaml:"maintenance"`
+}
+
+// Server configures the HTTP server.
+type Server struct {
+	Addr            string        `yaml:"addr" env:"LISTEN_ADDR"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"` // Long enough to place an order
+	IdleTimeout     time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	MaxBodyBytes    int64         `yaml:"max_body_bytes" env:"MAX_BODY_BYTES"`
+	CORSOrigins     []string      `yaml:"cors_origins" env:"CORS_ORIGINS"`
+}
+
+// Database configures the storage and the PnL cost method.
+type Database struct {
+	URL       Secret `yaml:"url" env:"DATABASE_URL"` // SQLite file or postgres:// URL, which may hold a password
+	PnLMethod string `yaml:"pnl_method" env:"PNL_METHOD"`
+}
+
+// Kraken configures the spot client and its risk limits.
+type Kraken struct {
+	APIKey            Secret        `yaml:"api_key" env:"KRAKEN_API_KEY"`
+	APISecret         Secret        `yaml:"api_secret" env:"KRAKEN_API_SECRET"`
+	TestMode          bool          `yaml:"test_mode" env:"KRAKEN_TEST_MODE"`               // Reloadable
+	MinMarginLevel    float64       `yaml:"min_margin_level" env:"KRAKEN_MIN_MARGIN_LEVEL"` // Reloadable
+	WithdrawKeys      []string      `yaml:"withdraw_keys" env:"KRAKEN_WITHDRAW_KEYS"`
+	ReconcileInterval time.Duration `yaml:"reconcile_interval" env:"RECONCILE_INTERVAL"`
+}
+
+// Futures configures the Kraken Futures client.
+type Futures struct {
+	APIKey    Secret `yaml:"api_key" env:"KRAKEN_FUTURES_API_KEY"`
+	APISecret Secret `yaml:"api_secret" env:"KRAKEN_FUTURES_API_SECRET"`
+	Demo      bool   `yaml:"demo" env:"KRAKEN_FUTURES_DEMO"`
+}
+
+// Earn configures the idle-balance Earn policy. Zero amounts keep the policy defaults.
+type Earn struct {
+	StrategyID string  `yaml:"strategy_id" env:"EARN_STRATEGY_ID"`
+	Asset      string  `yaml:"asset" env:"EARN_ASSET"`
+	Quote      string  `yaml:"quote" env:"EARN_QUOTE"`
+	Reserve    float64 `yaml:"reserve" env:"EARN_RESERVE"`
+	MinMove    float64 `yaml:"min_move" env:"EARN_MIN_MOVE"`
+}
+
+// Webhook configures how webhooks are authenticated. The secrets of single strategies
+// are in Strategies.
+type Webhook struct {
+	Tokens         []Secret      `yaml:"tokens" env:"TOKEN"`      // Secrets of all strategies without their own
+	Auth           string        `yaml:"auth" env:"WEBHOOK_AUTH"` // token or hmac
+	HMACWindow     time.Duration `yaml:"hmac_window" env:"HMAC_WINDOW"`
+	AllowedIPs     []string      `yaml:"allowed_ips" env:"WEBHOOK_ALLOWED_IPS"`
+	TrustedProxies []string      `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
+// Strategy holds the settings of one strategy. WEBHOOK_SECRETS, CONFIRM_STRATEGIES and
+// TELEGRAM_TOPICS override them for all strategies at once.
+type Strategy struct {
+	Secrets []Secret `yaml:"secrets"` // Webhook secrets of the strategy, replacing Webhook.Tokens
+	Confirm bool     `yaml:"confirm"` // Reloadable: orders need confirmation in Telegram
+	Topic   int64    `yaml:"topic"`   // Reloadable: forum topic of its Telegram messages
+}
+
+// Telegram configures the bot, its messages and the confirmation of orders.
+type Telegram struct {
+	BotToken       Secret        `yaml:"bot_token" env:"TELEGRAM_BOT_TOKEN"`
+	ChatID         int64         `yaml:"chat_id" env:"TELEGRAM_CHAT_ID"`
+	AllowedChats   []int64       `yaml:"allowed_chats" env:"TELEGRAM_ALLOWED_CHATS"` // Default ChatID
+	AllowedUsers   []int64       `yaml:"allowed_users" env:"TELEGRAM_ALLOWED_USERS"`
+	WebhookURL     string        `yaml:"webhook_url" env:"TELEGRAM_WEBHOOK_URL"`
+	WebhookSecret  Secret        `yaml:"webhook_secret" env:"TELEGRAM_WEBHOOK_SECRET"`
+	Threading      string        `yaml:"threading" env:"TELEGRAM_THREADING"` // reply, edit or off
+	Charts         bool          `yaml:"charts" env:"TELEGRAM_CHARTS"`
+	ChartInterval  int           `yaml:"chart_interval" env:"CHART_INTERVAL"` // Minutes
+	ChartCandles   int           `yaml:"chart_candles" env:"CHART_CANDLES"`
+	ConfirmTimeout time.Duration `yaml:"confirm_timeout" env:"CONFIRM_TIMEOUT"` // Reloadable
+}
+
+// Notify configures the notification channels and the rules routing events to them.
+type Notify struct {
+	Rules         string `yaml:"rules" env:"NOTIFY_RULES"` // Reloadable
+	DiscordURL    Secret `yaml:"discord_webhook_url" env:"DISCORD_WEBHOOK_URL"`
+	SlackURL      Secret `yaml:"slack_webhook_url" env:"SLACK_WEBHOOK_URL"`
+	WebhookURL    string `yaml:"webhook_url" env:"NOTIFY_WEBHOOK_URL"`
+	WebhookSecret Secret `yaml:"webhook_secret" env:"NOTIFY_WEBHOOK_SECRET"`
+	SMTP          SMTP   `yaml:"smtp"`
+	NtfyURL       string `yaml:"ntfy_url" env:"NTFY_URL"`
+	NtfyToken     Secret `yaml:"ntfy_token" env:"NTFY_TOKEN"`
+	GotifyURL     string `yaml:"gotify_url" env:"GOTIFY_URL"`
+	GotifyToken   Secret `yaml:"gotify_token" env:"GOTIFY_TOKEN"`
+}
+
+// SMTP configures email notifications.
+type SMTP struct {
+	Host     string   `yaml:"host" env:"SMTP_HOST"`
+	Port     string   `yaml:"port" env:"SMTP_PORT"`
+	Username string   `yaml:"username" env:"SMTP_USERNAME"`
+	Password Secret   `yaml:"password" env:"SMTP_PASSWORD"`
+	From     string   `yaml:"from" env:"SMTP_FROM"`
+	To       []string `yaml:"to" env:"SMTP_TO"`
+}
+
+// Reports configures the daily equity snapshot and summary.
+type Reports struct {
+	Currency string `yaml:"currency" env:"REPORT_CURRENCY"`
+	Hour     int    `yaml:"hour" env:"REPORT_HOUR"` // UTC
+}
+
+// Maintenance configures database backups and the retention of signal payloads.
+type Maintenance struct {
+	BackupDir      string        `yaml:"backup_dir" env:"BACKUP_DIR"`
+	BackupInterval time.Duration `yaml:"backup_interval" env:"BACKUP_INTERVAL"`
+	BackupKeep     int           `yaml:"backup_keep" env:"BACKUP_KEEP"`
+	RetentionDays  int           `yaml:"signal_retention_days" env:"SIGNAL_RETENTION_DAYS"`
+}
+
+// Default returns the settings used where neither the file nor the environment set them.
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Addr:            ":8081",
+			ReadTimeout:     30 * time.Second,
+			WriteTimeout:    60 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 30 * time.Second,
+			MaxBodyBytes:    1 << 20,
+		},
+		Database:    Database{URL: "./tvwh2k.db", PnLMethod: "fifo"},
+		Kraken:      Kraken{ReconcileInterval: time.Minute},
+		Webhook:     Webhook{Auth: "token", HMACWindow: 5 * time.Minute},
+		Telegram:    Telegram{Threading: "reply", ChartInterval: 15, ChartCandles: 96, ConfirmTimeout: 5 * time.Minute},
+		Notify:      Notify{SMTP: SMTP{Port: "587"}},
+		Reports:     Reports{Currency: "USD"},
+		Maintenance: Maintenance{BackupInterval: 24 * time.Hour, BackupKeep: 7},
+	}
+}
+
+// Load reads the file named by CONFIG_FILE, if set, applies the environment and validates
+// the result.
+func Load() (*Config, error) {
+	return LoadFile(os.Getenv(FileEnv))
+}
+
+// LoadFile reads the YAML (.yaml, .yml) or TOML (.toml) file at path over the defaults,
+// applies the environment and validates the result. Without a path only the environment
+// is used.
+func LoadFile(path string) (*Config, error) {
+	c := Default()
+	if path != "" {
+		if err := c.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+	case ".toml":
+		// TOML is converted to YAML so both formats share the yaml field names and the
+		// decoding of secrets and durations.
+		var doc map[string]any
+		if _, err := toml.Decode(string(data), &doc); err != nil {
+			return fmt.Errorf("config %s: %w", path, err)
+		}
+		if data, err = yaml.Marshal(doc); err != nil {
+			return fmt.Errorf("config %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("config %s: unknown format %q (want .yaml, .yml or .toml)", path, ext)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true) // Misspelled settings are errors rather than silently ignored
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	return nil
+}
+
+// ConfirmStrategies returns the names of the strategies whose orders need confirmation, sorted.
+func (c *Config) ConfirmStrategies() []string {
+	var names []string
+	for name, s := range c.Strategies {
+		if s.Confirm {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Topics returns the Telegram forum topics by strategy.
+func (c *Config) Topics() map[string]int64 {
+	topics := make(map[string]int64)
+	for name, s := range c.Strategies {
+		if s.Topic != 0 {
+			topics[name] = s.Topic
+		}
+	}
+	return topics
+}
+
+// RestartRequired lists the sections whose changes from old to c only take effect after
+// a restart; changes to the reloadable settings are ignored.
+func (c *Config) RestartRequired(old *Config) []string {
+	a, b := c.static(), old.static()
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	var sections []string
+	for i := 0; i < va.NumField(); i++ {
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			sections = append(sections, yamlName(va.Type().Field(i)))
+		}
+	}
+	return sections
+}
+
+// static returns a copy of c without the reloadable settings.
+func (c *Config) static() *Config {
+	s := *c
+	s.Kraken.TestMode, s.Kraken.MinMarginLevel = false, 0
+	s.Telegram.ConfirmTimeout = 0
+	s.Notify.Rules = ""
+	s.Strategies = make(map[string]Strategy, len(c.Strategies))
+	for name, st := range c.Strategies {
+		if len(st.Secrets) > 0 {
+			s.Strategies[name] = Strategy{Secrets: st.Secrets}
+		}
+	}
+	return &s
+}
+
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	return name
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	secret := writeFile(t, "kraken_secret", "c2VjcmV0\n")
+	files := map[string]string{
+		"config.yaml": `
+server:
+  addr: ":9000"
+  read_timeout: 10s
+kraken:
+  api_key: key
+  api_secret: {file: ` + secret + `}
+  min_margin_level: 150
+telegram:
+  bot_token: "123:abc"
+  chat_id: -100
+strategies:
+  trend:
+    secrets: [s1, s2]
+    confirm: true
+    topic: 12
+  "*":
+    topic: 56
+`,
+		"config.toml": `
+[server]
+addr = ":9000"
+read_timeout = "10s"
+
+[kraken]
+api_key = "key"
+api_secret = { file = "` + secret + `" }
+min_margin_level = 150
+
+[telegram]
+bot_token = "123:abc"
+chat_id = -100
+
+[strategies.trend]
+secrets = ["s1", "s2"]
+confirm = true
+topic = 12
+
+[strategies."*"]
+topic = 56
+`,
+	}
+	for name, content := range files {
+		c, err := LoadFile(writeFile(t, name, content))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if c.Server.Addr != ":9000" || c.Server.ReadTimeout != 10*time.Second || c.Server.WriteTimeout != 60*time.Second {
+			t.Errorf("%s: server = %+v", name, c.Server)
+		}
+		if c.Kraken.APIKey != "key" || c.Kraken.APISecret != "c2VjcmV0" || c.Kraken.MinMarginLevel != 150 {
+			t.Errorf("%s: kraken = %+v", name, c.Kraken)
+		}
+		if got := c.ConfirmStrategies(); !reflect.DeepEqual(got, []string{"trend"}) {
+			t.Errorf("%s: confirm = %v", name, got)
+		}
+		if got := c.Topics(); !reflect.DeepEqual(got, map[string]int64{"trend": 12, "*": 56}) {
+			t.Errorf("%s: topics = %v", name, got)
+		}
+		if got := c.Strategies["trend"].Secrets; !reflect.DeepEqual(got, []Secret{"s1", "s2"}) {
+			t.Errorf("%s: secrets = %v", name, got)
+		}
+	}
+}
+
+func TestEnvOverridesFile(t *testing.T) {
+	path := writeFile(t, "config.yml", `
+server:
+  addr: ":9000"
+telegram:
+  chat_id: 1
+strategies:
+  trend:
+    confirm: true
+    topic: 12
+`)
+	token := writeFile(t, "token", "a\nb\n")
+	t.Setenv("LISTEN_ADDR", ":9100")
+	t.Setenv("HTTP_IDLE_TIMEOUT", "") // Empty, as passed by Docker Compose: the file or default applies
+	t.Setenv("TOKEN_FILE", token)     // One secret per line
+	t.Setenv("TELEGRAM_BOT_TOKEN", "123:abc")
+	t.Setenv("TELEGRAM_ALLOWED_USERS", "7, 8")
+	t.Setenv("KRAKEN_TEST_MODE", "true")
+	t.Setenv("CONFIRM_STRATEGIES", "scalp")
+	t.Setenv("WEBHOOK_SECRETS", "scalp=s3")
+
+	c, err := LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Server.Addr != ":9100" || c.Server.IdleTimeout != 2*time.Minute {
+		t.Errorf("server = %+v", c.Server)
+	}
+	if !reflect.DeepEqual(c.Webhook.Tokens, []Secret{"a", "b"}) {
+		t.Errorf("tokens = %v", c.Webhook.Tokens)
+	}
+	if !reflect.DeepEqual(c.Telegram.AllowedUsers, []int64{7, 8}) || c.Telegram.ChatID != 1 || !c.Kraken.TestMode {
+		t.Errorf("telegram = %+v, test mode %v", c.Telegram, c.Kraken.TestMode)
+	}
+	// CONFIRM_STRATEGIES replaces the strategies of the file; their topics are kept.
+	if got := c.ConfirmStrategies(); !reflect.DeepEqual(got, []string{"scalp"}) {
+		t.Errorf("confirm = %v", got)
+	}
+	if c.Strategies["trend"].Topic != 12 || len(c.Strategies["scalp"].Secrets) != 1 {
+		t.Errorf("strategies = %#v", c.Strategies)
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  read_timeout: -1s
+telegram:
+  threading: thread
+  webhook_url: https://example.com/telegram/webhook
+`)
+	t.Setenv("REPORT_HOUR", "24")
+	t.Setenv("KRAKEN_API_KEY", "key")
+	t.Setenv("CONFIRM_STRATEGIES", "trend")
+
+	_, err := LoadFile(path)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		"server.read_timeout (HTTP_READ_TIMEOUT)",
+		"telegram.threading (TELEGRAM_THREADING)",
+		"telegram.webhook_secret (TELEGRAM_WEBHOOK_SECRET)",
+		"reports.hour (REPORT_HOUR)",
+		"kraken.api_key (KRAKEN_API_KEY)",
+		"strategies.confirm (CONFIRM_STRATEGIES)",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	tests := map[string]struct {
+		name, content, want string
+	}{
+		"unknown setting": {"config.yaml", "server:\n  adress: x\n", "field adress not found"},
+		"format":          {"config.json", "{}", "unknown format"},
+		"secret file":     {"config.yaml", "kraken:\n  api_key: {file: /nonexistent}\n", "reading secret"},
+	}
+	for name, tt := range tests {
+		_, err := LoadFile(writeFile(t, tt.name, tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", name, err, tt.want)
+		}
+	}
+
+	t.Setenv("CHART_CANDLES", "many")
+	if _, err := LoadFile(""); err == nil || !strings.Contains(err.Error(), `CHART_CANDLES: invalid integer "many"`) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	old := Default()
+	c := Default()
+	c.Kraken.TestMode = true
+	c.Kraken.MinMarginLevel = 200
+	c.Notify.Rules = "telegram: *"
+	c.Strategies = map[string]Strategy{"trend": {Confirm: true, Topic: 3}}
+	if got := c.RestartRequired(old); len(got) != 0 {
+		t.Errorf("reloadable changes need a restart: %v", got)
+	}
+
+	c.Server.Addr = ":9000"
+	c.Strategies["trend"] = Strategy{Secrets: []Secret{"s"}}
+	if got := c.RestartRequired(old); !reflect.DeepEqual(got, []string{"server", "strategies"}) {
+		t.Errorf("restart required for %v", got)
+	}
+}
+
+func TestSecretIsNotPrinted(t *testing.T) {
+	c := Default()
+	c.Kraken.APISecret = "hunter2"
+	for _, format := range []string{"%v", "%+v", "%#v"} {
+		if s := fmt.Sprintf(format, c.Kraken); strings.Contains(s, "hunter2") {
+			t.Errorf("%s prints the secret: %s", format, s)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	durationType    = reflect.TypeOf(time.Duration(0))
+	secretType      = reflect.TypeOf(Secret(""))
+	secretSliceType = reflect.TypeOf([]Secret(nil))
+)
+
+// applyEnv overrides the settings with the environment variables in their env tags.
+// Empty variables are ignored, like the unset ones Docker Compose passes as "". Secrets
+// are also read from the file named by NAME_FILE. Lists are comma separated.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	get := func(name string) string {
+		v, _ := lookup(name)
+		return strings.TrimSpace(v)
+	}
+
+	var errs []error
+	walkEnv(reflect.ValueOf(c).Elem(), func(name string, v reflect.Value) {
+		value := get(name)
+		if value == "" && (v.Type() == secretType || v.Type() == secretSliceType) {
+			if path := get(name + "_FILE"); path != "" {
+				s, err := readSecretFile(path)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s_FILE: %w", name, err))
+					return
+				}
+				value = s
+				if v.Type() == secretSliceType {
+					value = strings.ReplaceAll(strings.TrimSpace(s), "\n", ",") // One secret per line
+				}
+			}
+		}
+		if value == "" {
+			return
+		}
+		if err := setValue(v, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	})
+
+	if v := get("WEBHOOK_SECRETS"); v != "" {
+		errs = append(errs, c.applyStrategySecrets(v))
+	} else if path := get("WEBHOOK_SECRETS_FILE"); path != "" {
+		v, err := readSecretFile(path)
+		if err == nil {
+			err = c.applyStrategySecrets(strings.ReplaceAll(strings.TrimSpace(v), "\n", ";"))
+		}
+		errs = append(errs, err)
+	}
+	if v := get("CONFIRM_STRATEGIES"); v != "" {
+		c.updateStrategies(func(name string, s *Strategy) { s.Confirm = false })
+		for _, name := range splitList(v) {
+			s := c.Strategies[name]
+			s.Confirm = true
+			c.Strategies[name] = s
+		}
+	}
+	if v := get("TELEGRAM_TOPICS"); v != "" {
+		errs = append(errs, c.applyTopics(v))
+	}
+	return errors.Join(errs...)
+}
+
+// applyStrategySecrets replaces the secrets of all strategies with those in list,
+// e.g. "trend=new,old;scalp=s3".
+func (c *Config) applyStrategySecrets(list string) error {
+	c.updateStrategies(func(name string, s *Strategy) { s.Secrets = nil })
+	for _, entry := range strings.Split(list, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, secrets, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || len(splitList(secrets)) == 0 {
+			return fmt.Errorf("WEBHOOK_SECRETS: invalid entry for %q (want strategy=secret,...)", name)
+		}
+		s := c.Strategies[name]
+		for _, secret := range splitList(secrets) {
+			s.Secrets = append(s.Secrets, Secret(secret))
+		}
+		c.Strategies[name] = s
+	}
+	return nil
+}
+
+// applyTopics replaces the topics of all strategies with those in list, e.g. "trend=12,*=56".
+func (c *Config) applyTopics(list string) error {
+	c.updateStrategies(func(name string, s *Strategy) { s.Topic = 0 })
+	for _, entry := range splitList(list) {
+		name, topic, ok := strings.Cut(entry, "=")
+		id, err := strconv.ParseInt(strings.TrimSpace(topic), 10, 64)
+		if !ok || err != nil {
+			return fmt.Errorf("TELEGRAM_TOPICS: invalid entry %q (want strategy=topic id)", entry)
+		}
+		name = strings.TrimSpace(name)
+		s := c.Strategies[name]
+		s.Topic = id
+		c.Strategies[name] = s
+	}
+	return nil
+}
+
+// updateStrategies calls update for every strategy, creating the map if needed.
+func (c *Config) updateStrategies(update func(name string, s *Strategy)) {
+	if c.Strategies == nil {
+		c.Strategies = make(map[string]Strategy)
+	}
+	for name, s := range c.Strategies {
+		update(name, &s)
+		c.Strategies[name] = s
+	}
+}
+
+// walkEnv calls set for every field of v with an env tag, descending into nested structs.
+func walkEnv(v reflect.Value, set func(name string, field reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		if name := t.Field(i).Tag.Get("env"); name != "" {
+			set(name, field)
+		} else if field.Kind() == reflect.Struct && field.Type() != durationType {
+			walkEnv(field, set)
+		}
+	}
+}
+
+// setValue parses s into v according to its type.
+func setValue(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q (want true or false)", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(i)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		items := splitList(s)
+		list := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setValue(list.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(list)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// splitList splits a comma separated list, dropping empty entries.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Secret is a setting that must not be logged. In the file it is either the value itself
+// or a mapping {file: path} naming a file that holds it, e.g. a Docker secret in
+// /run/secrets; the environment variable NAME_FILE does the same for NAME.
+type Secret string
+
+// String hides the value, so printing a configuration does not leak it.
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "***"
+}
+
+// GoString hides the value from %#v.
+func (s Secret) GoString() string {
+	return `"` + s.String() + `"`
+}
+
+// UnmarshalYAML accepts a plain value or {file: path}.
+func (s *Secret) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = Secret(node.Value)
+		return nil
+	}
+	var ref struct {
+		File string `yaml:"file"`
+	}
+	if err := node.Decode(&ref); err != nil || ref.File == "" {
+		return fmt.Errorf("line %d: want a secret or {file: path}", node.Line)
+	}
+	v, err := readSecretFile(ref.File)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*s = Secret(v)
+	return nil
+}
+
+// readSecretFile returns the contents of a secret file without the trailing newline
+// most editors and `echo` add.
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading secret: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"tvwh2k/auth"
+	"tvwh2k/database"
+	"tvwh2k/notify"
+)
+
+// Validate checks the settings and reports all problems at once, each naming the setting
+// as in the file and the environment, e.g. "server.read_timeout (HTTP_READ_TIMEOUT)".
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, setting, env, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s (%s): %s", setting, env, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	s := c.Server
+	check(s.Addr != "", "server.addr", "LISTEN_ADDR", "must be set, e.g. :8081")
+	check(s.ReadTimeout > 0, "server.read_timeout", "HTTP_READ_TIMEOUT", "must be positive")
+	check(s.WriteTimeout > 0, "server.write_timeout", "HTTP_WRITE_TIMEOUT", "must be positive")
+	check(s.IdleTimeout > 0, "server.idle_timeout", "HTTP_IDLE_TIMEOUT", "must be positive")
+	check(s.ShutdownTimeout > 0, "server.shutdown_timeout", "SHUTDOWN_TIMEOUT", "must be positive")
+	check(s.MaxBodyBytes > 0, "server.max_body_bytes", "MAX_BODY_BYTES", "must be positive")
+
+	check(c.Database.URL != "", "database.url", "DATABASE_URL", "must be set")
+	_, err := database.ParseCostMethod(c.Database.PnLMethod)
+	check(err == nil, "database.pnl_method", "PNL_METHOD", "%v", err)
+
+	k := c.Kraken
+	check((k.APIKey == "") == (k.APISecret == ""), "kraken.api_key", "KRAKEN_API_KEY", "must be set together with kraken.api_secret (KRAKEN_API_SECRET)")
+	check(k.MinMarginLevel >= 0, "kraken.min_margin_level", "KRAKEN_MIN_MARGIN_LEVEL", "must not be negative")
+	check(k.ReconcileInterval > 0, "kraken.reconcile_interval", "RECONCILE_INTERVAL", "must be positive")
+	f := c.Futures
+	check((f.APIKey == "") == (f.APISecret == ""), "futures.api_key", "KRAKEN_FUTURES_API_KEY", "must be set together with futures.api_secret (KRAKEN_FUTURES_API_SECRET)")
+	check(c.Earn.StrategyID == "" || c.Earn.Asset != "", "earn.asset", "EARN_ASSET", "must be set when earn.strategy_id (EARN_STRATEGY_ID) is set")
+	check(c.Earn.Reserve >= 0 && c.Earn.MinMove >= 0, "earn.reserve", "EARN_RESERVE", "amounts must not be negative")
+
+	w := c.Webhook
+	check(w.Auth == "token" || w.Auth == "hmac", "webhook.auth", "WEBHOOK_AUTH", "unknown mode %q (want token or hmac)", w.Auth)
+	check(w.HMACWindow > 0, "webhook.hmac_window", "HMAC_WINDOW", "must be positive")
+	for _, p := range []struct {
+		list         []string
+		setting, env string
+	}{{w.AllowedIPs, "webhook.allowed_ips", "WEBHOOK_ALLOWED_IPS"}, {w.TrustedProxies, "webhook.trusted_proxies", "TRUSTED_PROXIES"}} {
+		_, err := auth.ParsePrefixes(strings.Join(p.list, ","))
+		check(err == nil, p.setting, p.env, "%v", err)
+	}
+	for name, st := range c.Strategies {
+		check(name != "", "strategies", "WEBHOOK_SECRETS", "strategy names must not be empty")
+		check(name != "*" || (len(st.Secrets) == 0 && !st.Confirm), "strategies.*", "TELEGRAM_TOPICS", "only sets the topic of other strategies")
+	}
+
+	t := c.Telegram
+	check(t.Threading == "reply" || t.Threading == "edit" || t.Threading == "off", "telegram.threading", "TELEGRAM_THREADING", "unknown mode %q (want reply, edit or off)", t.Threading)
+	check(t.ChartInterval > 0, "telegram.chart_interval", "CHART_INTERVAL", "must be positive")
+	check(t.ChartCandles > 0, "telegram.chart_candles", "CHART_CANDLES", "must be positive")
+	check(t.ConfirmTimeout > 0, "telegram.confirm_timeout", "CONFIRM_TIMEOUT", "must be positive")
+	if t.WebhookURL != "" {
+		check(validWebhookSecret(string(t.WebhookSecret)), "telegram.webhook_secret", "TELEGRAM_WEBHOOK_SECRET",
+			"must be set when telegram.webhook_url (TELEGRAM_WEBHOOK_URL) is set, using 1-256 of A-Z, a-z, 0-9, _ and -")
+	}
+	if confirm := c.ConfirmStrategies(); len(confirm) > 0 {
+		check(t.BotToken != "" && t.ChatID != 0, "strategies.confirm", "CONFIRM_STRATEGIES", "%v need telegram.bot_token (TELEGRAM_BOT_TOKEN) and telegram.chat_id (TELEGRAM_CHAT_ID) to confirm orders", confirm)
+	}
+
+	n := c.Notify
+	if n.SMTP.Host != "" {
+		check(n.SMTP.From != "" && len(n.SMTP.To) > 0, "notify.smtp", "SMTP_FROM, SMTP_TO", "from and to must be set when host is set")
+	}
+	_, err = notify.ParseRules(n.Rules)
+	check(err == nil, "notify.rules", "NOTIFY_RULES", "%v", err)
+
+	check(c.Reports.Currency != "", "reports.currency", "REPORT_CURRENCY", "must be set")
+	check(c.Reports.Hour >= 0 && c.Reports.Hour <= 23, "reports.hour", "REPORT_HOUR", "must be between 0 and 23, got %d", c.Reports.Hour)
+
+	m := c.Maintenance
+	check(m.BackupInterval > 0, "maintenance.backup_interval", "BACKUP_INTERVAL", "must be positive")
+	check(m.BackupKeep >= 0, "maintenance.backup_keep", "BACKUP_KEEP", "must not be negative")
+	check(m.RetentionDays >= 0, "maintenance.signal_retention_days", "SIGNAL_RETENTION_DAYS", "must not be negative")
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return nil
+}
+
+// validWebhookSecret reports whether s is a secret token Telegram accepts for webhooks.
+func validWebhookSecret(s string) bool {
+	if len(s) == 0 || len(s) > 256 {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
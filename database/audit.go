@@ -0,0 +1,82 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// SignalStep is one step in the processing of a signal, e.g. validation,
+// the risk decision, the order sent to Kraken or the Telegram delivery.
+type SignalStep struct {
+	ID        int64           `json:"id"`
+	SignalID  int64           `json:"signal_id"`
+	Step      string          `json:"step"`
+	Status    string          `json:"status"`
+	Detail    json.RawMessage `json:"detail,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// SaveSignalStep appends a step to the audit trail of a signal. Detail is stored as JSON.
+func (db *DB) SaveSignalStep(signalID int64, step, status string, detail interface{}) error {
+	var detailJSON []byte
+	if detail != nil {
+		var err error
+		if detailJSON, err = json.Marshal(detail); err != nil {
+			return err
+		}
+	}
+	_, err := db.Exec("INSERT INTO signal_steps (signal_id, step, status, detail, created_at) VALUES (?, ?, ?, ?, ?)",
+		signalID, step, status, string(detailJSON), time.Now().UTC())
+	return err
+}
+
+func (db *DB) UpdateSignalStatus(signalID int64, status string) error {
+	_, err := db.Exec("UPDATE signals SET status = ? WHERE id = ?", status, signalID)
+	return err
+}
+
+// GetSignal returns the signal with the given ID, or nil if there is none.
+func (db *DB) GetSignal(id int64) (*Signal, error) {
+	var s Signal
+	err := db.QueryRow("SELECT id, received_at, pair, type, strategy, status, payload FROM signals WHERE id = ?", id).
+		Scan(&s.ID, &s.ReceivedAt, &s.Pair, &s.Type, &s.Strategy, &s.Status, &s.Payload)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (db *DB) GetSignalSteps(signalID int64) ([]SignalStep, error) {
+	rows, err := db.Query("SELECT id, signal_id, step, status, detail, created_at FROM signal_steps WHERE signal_id = ? ORDER BY id", signalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	steps := []SignalStep{}
+	for rows.Next() {
+		var st SignalStep
+		var detail string
+		if err := rows.Scan(&st.ID, &st.SignalID, &st.Step, &st.Status, &detail, &st.CreatedAt); err != nil {
+			return nil, err
+		}
+		if detail != "" {
+			st.Detail = json.RawMessage(detail)
+		}
+		steps = append(steps, st)
+	}
+	return steps, rows.Err()
+}
+
+// GetTradesBySignal returns the trades (including linked close orders) created for a signal.
+func (db *DB) GetTradesBySignal(signalID int64) ([]Trade, error) {
+	trades, err := db.queryTrades("SELECT "+tradeColumns+" FROM trades WHERE signal_id = ? ORDER BY id", signalID)
+	if err != nil {
+		return nil, err
+	}
+	return trades, db.attachFills(trades)
+}
@@ -3,70 +3,87 @@ package database
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
 	"log"
 	"time"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// DB is the storage of signals, trades and reports. It wraps a SQLite or
+// PostgreSQL connection pool; Exec, Query and QueryRow accept ? placeholders
+// for either database.
 type DB struct {
 	*sql.DB
+	dialect Dialect
 }
 
-func InitDB(filepath string) (*DB, error) {
-	db, err := sql.Open("sqlite3", filepath)
+// InitDB opens the database and applies all pending schema migrations.
+func InitDB(dsn string) (*DB, error) {
+	db, err := Open(dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
+	if _, err := db.MigrateUp(0); err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	// Enable WAL mode for better concurrency
-	if _, err := db.Exec("PRAGMA journal_mode = WAL;"); err != nil {
-		log.Printf("Failed to enable WAL mode: %v", err)
+	return db, nil
+}
+
+// Open opens the database without applying migrations. The DSN is a SQLite file
+// path (optionally prefixed with sqlite://) or a postgres:// URL.
+func Open(dsn string) (*DB, error) {
+	dialect, driverDSN := dialectFor(dsn)
+	db, err := sql.Open(dialect.Name(), driverDSN)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := createTables(db); err != nil {
+	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	return &DB{db}, nil
-}
-
-func createTables(db *sql.DB) error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS signals (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			pair TEXT,
-			type TEXT,
-			payload TEXT
-		);`,
-		`CREATE TABLE IF NOT EXISTS trades (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			signal_id INTEGER,
-			pair TEXT,
-			type TEXT,
-			ordertype TEXT,
-			volume TEXT,
-			price TEXT,
-			txid TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			status TEXT DEFAULT 'open',
-			pnl REAL DEFAULT 0,
-			FOREIGN KEY(signal_id) REFERENCES signals(id)
-		);`,
+	if err := dialect.setup(db); err != nil {
+		log.Printf("Failed to set up %s database: %v", dialect.Name(), err)
 	}
 
-	for _, query := range queries {
-		if _, err := db.Exec(query); err != nil {
-			return fmt.Errorf("error creating table: %w", err)
-		}
+	return &DB{DB: db, dialect: dialect}, nil
+}
+
+// Dialect returns the SQL dialect of the database.
+func (db *DB) Dialect() Dialect {
+	return db.dialect
+}
+
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.DB.Exec(db.dialect.Rebind(query), args...)
+}
+
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.DB.Query(db.dialect.Rebind(query), args...)
+}
+
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.DB.QueryRow(db.dialect.Rebind(query), args...)
+}
+
+// insert executes an INSERT statement and returns the ID of the new row.
+func (db *DB) insert(query string, args ...interface{}) (int64, error) {
+	var id int64
+	err := db.QueryRow(query+" RETURNING id", args...).Scan(&id)
+	return id, err
+}
+
+// nullID stores 0 as NULL so optional references do not violate foreign keys.
+func nullID(id int64) interface{} {
+	if id == 0 {
+		return nil
 	}
-	return nil
+	return id
 }
 
 type Signal struct {
@@ -74,27 +91,18 @@ type Signal struct {
 	ReceivedAt time.Time
 	Pair       string
 	Type       string // buy/sell
+	Strategy   string
+	Status     string
 	Payload    string
 }
 
-func (db *DB) SaveSignal(pair, action string, payload interface{}) (int64, error) {
+func (db *DB) SaveSignal(pair, action, strategy string, payload interface{}) (int64, error) {
 	payloadBytes, _ := json.Marshal(payload)
-	res, err := db.Exec("INSERT INTO signals (pair, type, payload) VALUES (?, ?, ?)", pair, action, string(payloadBytes))
-	if err != nil {
-		return 0, err
-	}
-	return res.LastInsertId()
-}
-
-func (db *DB) SaveTrade(signalID int64, pair, action, orderType, volume, price, txid string) error {
-	_, err := db.Exec(`INSERT INTO trades (signal_id, pair, type, ordertype, volume, price, txid)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		signalID, pair, action, orderType, volume, price, txid)
-	return err
+	return db.insert("INSERT INTO signals (pair, type, strategy, payload) VALUES (?, ?, ?, ?)", pair, action, strategy, string(payloadBytes))
 }
 
 func (db *DB) GetRecentSignals(limit int) ([]Signal, error) {
-	rows, err := db.Query("SELECT id, received_at, pair, type, payload FROM signals ORDER BY received_at DESC LIMIT ?", limit)
+	rows, err := db.Query("SELECT id, received_at, pair, type, strategy, status, payload FROM signals ORDER BY received_at DESC LIMIT ?", limit)
 	if err != nil {
 		return nil, err
 	}
@@ -103,7 +111,7 @@ func (db *DB) GetRecentSignals(limit int) ([]Signal, error) {
 	var signals []Signal
 	for rows.Next() {
 		var s Signal
-		if err := rows.Scan(&s.ID, &s.ReceivedAt, &s.Pair, &s.Type, &s.Payload); err != nil {
+		if err := rows.Scan(&s.ID, &s.ReceivedAt, &s.Pair, &s.Type, &s.Strategy, &s.Status, &s.Payload); err != nil {
 			return nil, err
 		}
 		signals = append(signals, s)
@@ -111,34 +119,60 @@ func (db *DB) GetRecentSignals(limit int) ([]Signal, error) {
 	return signals, nil
 }
 
-type Trade struct {
+// FuturesOrder is an order placed on Kraken Futures. Futures orders are kept apart from
+// trades, which hold spot orders only.
+type FuturesOrder struct {
 	ID        int64     `json:"id"`
 	SignalID  int64     `json:"signal_id"`
-	Pair      string    `json:"pair"`
-	Type      string    `json:"type"`
+	Symbol    string    `json:"symbol"`
+	Side      string    `json:"side"`
 	OrderType string    `json:"ordertype"`
-	Volume    string    `json:"volume"`
+	Size      string    `json:"size"`
 	Price     string    `json:"price"`
-	TxID      string    `json:"txid"`
+	OrderID   string    `json:"order_id"`
 	CreatedAt time.Time `json:"created_at"`
-	Status    string    `json:"status"`
-	PnL       float64   `json:"pnl"`
 }
 
-func (db *DB) GetRecentTrades(limit int) ([]Trade, error) {
-	rows, err := db.Query("SELECT id, signal_id, pair, type, ordertype, volume, price, txid, created_at, status, pnl FROM trades ORDER BY created_at DESC LIMIT ?", limit)
+func (db *DB) SaveFuturesOrder(o FuturesOrder) error {
+	_, err := db.Exec(`INSERT INTO futures_orders (signal_id, symbol, side, ordertype, size, price, order_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		o.SignalID, o.Symbol, o.Side, o.OrderType, o.Size, o.Price, o.OrderID)
+	return err
+}
+
+type EarnMove struct {
+	ID         int64     `json:"id"`
+	SignalID   int64     `json:"signal_id"`
+	StrategyID string    `json:"strategy_id"`
+	Asset      string    `json:"asset"`
+	Direction  string    `json:"direction"` // allocate/deallocate
+	Amount     string    `json:"amount"`
+	Status     string    `json:"status"` // ok/failed
+	Error      string    `json:"error"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (db *DB) SaveEarnMove(m EarnMove) error {
+	_, err := db.Exec(`INSERT INTO earn_moves (signal_id, strategy_id, asset, direction, amount, status, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		m.SignalID, m.StrategyID, m.Asset, m.Direction, m.Amount, m.Status, m.Error)
+	return err
+}
+
+func (db *DB) GetRecentEarnMoves(limit int) ([]EarnMove, error) {
+	rows, err := db.Query("SELECT id, signal_id, strategy_id, asset, direction, amount, status, error, created_at FROM earn_moves ORDER BY created_at DESC LIMIT ?", limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var trades []Trade
+	var moves []EarnMove
 	for rows.Next() {
-		var t Trade
-		if err := rows.Scan(&t.ID, &t.SignalID, &t.Pair, &t.Type, &t.OrderType, &t.Volume, &t.Price, &t.TxID, &t.CreatedAt, &t.Status, &t.PnL); err != nil {
+		var m EarnMove
+		if err := rows.Scan(&m.ID, &m.SignalID, &m.StrategyID, &m.Asset, &m.Direction, &m.Amount, &m.Status, &m.Error, &m.CreatedAt); err != nil {
 			return nil, err
 		}
-		trades = append(trades, t)
+		moves = append(moves, m)
 	}
-	return trades, nil
+	return moves, nil
 }
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect hides the differences between the supported SQL databases. Queries in
+// this package are written for SQLite with ? placeholders; the dialect rewrites
+// them where needed.
+type Dialect interface {
+	// Name is the database/sql driver name.
+	Name() string
+	// Rebind converts ? placeholders to the placeholder style of the database.
+	Rebind(query string) string
+	// DDL converts a schema statement written for SQLite.
+	DDL(stmt string) string
+	// Time converts a time to a value comparable with timestamp columns.
+	Time(t time.Time) interface{}
+	// setup configures a newly opened connection pool.
+	setup(db *sql.DB) error
+	// lockMigrations serializes migrations of instances sharing the database.
+	lockMigrations(tx *sql.Tx) error
+}
+
+// dialectFor selects the dialect from a DSN and returns the DSN to pass to the driver.
+// postgres:// and postgresql:// URLs select PostgreSQL; anything else, optionally
+// prefixed with sqlite://, is a SQLite file.
+func dialectFor(dsn string) (Dialect, string) {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return postgres{}, dsn
+	case strings.HasPrefix(dsn, "sqlite://"):
+		return sqlite{}, strings.TrimPrefix(dsn, "sqlite://")
+	default:
+		return sqlite{}, dsn
+	}
+}
+
+type sqlite struct{}
+
+// sqliteTime is the layout SQLite uses for CURRENT_TIMESTAMP.
+const sqliteTime = "2006-01-02 15:04:05"
+
+func (sqlite) Name() string                 { return "sqlite3" }
+func (sqlite) Rebind(query string) string   { return query }
+func (sqlite) DDL(stmt string) string       { return stmt }
+func (sqlite) Time(t time.Time) interface{} { return t.UTC().Format(sqliteTime) }
+
+func (sqlite) setup(db *sql.DB) error {
+	// Enable WAL mode for better concurrency
+	if _, err := db.Exec("PRAGMA journal_mode = WAL;"); err != nil {
+		return fmt.Errorf("failed to enable WAL mode: %w", err)
+	}
+	return nil
+}
+
+// SQLite locks the whole file for writing, so concurrent migrations already wait for each other.
+func (sqlite) lockMigrations(tx *sql.Tx) error { return nil }
+
+type postgres struct{}
+
+// migrationLockID is the advisory lock key taken while migrating a PostgreSQL database.
+const migrationLockID = 7243620451
+
+// postgresTypes maps the SQLite column types used in the migrations. Longer names come first.
+var postgresTypes = strings.NewReplacer(
+	"INTEGER PRIMARY KEY AUTOINCREMENT", "BIGSERIAL PRIMARY KEY",
+	"INTEGER", "BIGINT",
+	"DATETIME", "TIMESTAMPTZ",
+	"REAL", "DOUBLE PRECISION",
+)
+
+func (postgres) Name() string                 { return "postgres" }
+func (postgres) DDL(stmt string) string       { return postgresTypes.Replace(stmt) }
+func (postgres) Time(t time.Time) interface{} { return t }
+func (postgres) setup(db *sql.DB) error       { return nil }
+
+// Rebind numbers the ? placeholders ($1, $2, ...), skipping quoted strings.
+func (postgres) Rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 10)
+	n := 0
+	inQuote := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inQuote = !inQuote
+		case r == '?' && !inQuote:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (postgres) lockMigrations(tx *sql.Tx) error {
+	_, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLockID)
+	return err
+}
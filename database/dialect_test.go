@@ -0,0 +1,81 @@
+package database
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDialectFor(t *testing.T) {
+	tests := []struct {
+		dsn, name, driverDSN string
+	}{
+		{"./tvwh2k.db", "sqlite3", "./tvwh2k.db"},
+		{"sqlite:///data/tvwh2k.db", "sqlite3", "/data/tvwh2k.db"},
+		{"postgres://bot:secret@db/tvwh2k?sslmode=disable", "postgres", "postgres://bot:secret@db/tvwh2k?sslmode=disable"},
+		{"postgresql://db/tvwh2k", "postgres", "postgresql://db/tvwh2k"},
+	}
+	for _, tt := range tests {
+		d, dsn := dialectFor(tt.dsn)
+		if d.Name() != tt.name || dsn != tt.driverDSN {
+			t.Errorf("dialectFor(%q) = %s, %q; want %s, %q", tt.dsn, d.Name(), dsn, tt.name, tt.driverDSN)
+		}
+	}
+}
+
+func TestPostgresRebind(t *testing.T) {
+	got := postgres{}.Rebind("SELECT * FROM signals WHERE status = '?' AND pair = ? AND id < ? LIMIT ?")
+	want := "SELECT * FROM signals WHERE status = '?' AND pair = $1 AND id < $2 LIMIT $3"
+	if got != want {
+		t.Errorf("Rebind = %q, want %q", got, want)
+	}
+
+	ddl := postgres{}.DDL("CREATE TABLE x (id INTEGER PRIMARY KEY AUTOINCREMENT, ref INTEGER, at DATETIME, v REAL);")
+	if ddl != "CREATE TABLE x (id BIGSERIAL PRIMARY KEY, ref BIGINT, at TIMESTAMPTZ, v DOUBLE PRECISION);" {
+		t.Errorf("DDL = %q", ddl)
+	}
+}
+
+// TestPostgres runs the migrations and a signal/trade round trip against the
+// PostgreSQL database in TEST_POSTGRES_DSN. It is skipped when that is not set.
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+
+	db, err := InitDB(dsn)
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	defer db.Close()
+	defer func() {
+		if _, err := db.MigrateDown(len(migrations)); err != nil {
+			t.Errorf("MigrateDown: %v", err)
+		}
+	}()
+
+	signalID, err := db.SaveSignal("XBTUSD", "buy", "trend", map[string]string{"text": "test"})
+	if err != nil {
+		t.Fatalf("SaveSignal: %v", err)
+	}
+	tradeID, err := db.SaveTrade(signalID, "XBTUSD", "buy", "market", "1", "", "OPGTEST")
+	if err != nil {
+		t.Fatalf("SaveTrade: %v", err)
+	}
+	fill := Fill{TradeID: tradeID, KrakenTradeID: "TPGTEST", Price: 100, Volume: 1, Cost: 100, Fee: 0.1, ExecutedAt: time.Now().UTC()}
+	for i, wantNew := range []bool{true, false} {
+		isNew, err := db.SaveFill(fill)
+		if err != nil || isNew != wantNew {
+			t.Fatalf("SaveFill #%d = %v, %v; want %v", i+1, isNew, err, wantNew)
+		}
+	}
+
+	trades, _, err := db.QueryTrades(Filter{Strategy: "trend", From: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatalf("QueryTrades: %v", err)
+	}
+	if len(trades) != 1 || trades[0].ExecutedVolume != 1 || len(trades[0].Fills) != 1 {
+		t.Fatalf("got %+v, want the trade with one fill", trades)
+	}
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// EquitySnapshot is the value of the account at a point in time.
+type EquitySnapshot struct {
+	ID            int64              `json:"id"`
+	TakenAt       time.Time          `json:"taken_at"`
+	Currency      string             `json:"currency"`
+	BalanceValue  float64            `json:"balance_value"`  // Sum of all balances valued at the ticker price
+	UnrealizedPnL float64            `json:"unrealized_pnl"` // Of open margin positions
+	Equity        float64            `json:"equity"`         // Balance value plus unrealized PnL
+	Balances      map[string]float64 `json:"balances,omitempty"`
+}
+
+const equityColumns = "id, taken_at, currency, balance_value, unrealized_pnl, equity, balances"
+
+func (db *DB) SaveEquitySnapshot(s EquitySnapshot) (int64, error) {
+	balances, err := json.Marshal(s.Balances)
+	if err != nil {
+		return 0, err
+	}
+	return db.insert(`INSERT INTO equity_snapshots (taken_at, currency, balance_value, unrealized_pnl, equity, balances)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		s.TakenAt.UTC(), s.Currency, s.BalanceValue, s.UnrealizedPnL, s.Equity, string(balances))
+}
+
+// GetEquitySnapshots returns the snapshots taken in [from, to), oldest first. Zero times are unbounded.
+func (db *DB) GetEquitySnapshots(from, to time.Time) ([]EquitySnapshot, error) {
+	if to.IsZero() {
+		to = time.Now().AddDate(100, 0, 0)
+	}
+	return db.queryEquitySnapshots("SELECT "+equityColumns+" FROM equity_snapshots WHERE taken_at >= ? AND taken_at < ? ORDER BY taken_at, id",
+		from.UTC(), to.UTC())
+}
+
+// GetEquityAt returns the latest snapshot taken at or before t, or nil if there is none.
+func (db *DB) GetEquityAt(t time.Time) (*EquitySnapshot, error) {
+	snapshots, err := db.queryEquitySnapshots("SELECT "+equityColumns+" FROM equity_snapshots WHERE taken_at <= ? ORDER BY taken_at DESC, id DESC LIMIT 1",
+		t.UTC())
+	if err != nil || len(snapshots) == 0 {
+		return nil, err
+	}
+	return &snapshots[0], nil
+}
+
+func (db *DB) queryEquitySnapshots(query string, args ...interface{}) ([]EquitySnapshot, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []EquitySnapshot{}
+	for rows.Next() {
+		var s EquitySnapshot
+		var balances string
+		if err := rows.Scan(&s.ID, &s.TakenAt, &s.Currency, &s.BalanceValue, &s.UnrealizedPnL, &s.Equity, &balances); err != nil {
+			return nil, err
+		}
+		if balances != "" {
+			if err := json.Unmarshal([]byte(balances), &s.Balances); err != nil {
+				return nil, err
+			}
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+// ActivityCounts are the numbers of signals and trades and the fill fees in a period.
+type ActivityCounts struct {
+	Signals int                `json:"signals"`
+	Trades  int                `json:"trades"`
+	Fees    map[string]float64 `json:"fees"` // Sum of fill fees per fee currency
+}
+
+// CountActivity counts the signals received, trades placed and fees paid in [from, to).
+func (db *DB) CountActivity(from, to time.Time) (ActivityCounts, error) {
+	var c ActivityCounts
+	f, t := db.dialect.Time(from), db.dialect.Time(to)
+	if err := db.QueryRow("SELECT COUNT(*) FROM signals WHERE received_at >= ? AND received_at < ?", f, t).Scan(&c.Signals); err != nil {
+		return c, err
+	}
+	if err := db.QueryRow("SELECT COUNT(*) FROM trades WHERE created_at >= ? AND created_at < ?", f, t).Scan(&c.Trades); err != nil {
+		return c, err
+	}
+	// Fill times are written by the driver rather than CURRENT_TIMESTAMP, so they compare with times as is.
+	// Fees are charged in the quote or the base currency, so they are only summed per currency.
+	rows, err := db.Query("SELECT COALESCE(fee_currency, ''), SUM(fee) FROM fills WHERE executed_at >= ? AND executed_at < ? GROUP BY fee_currency",
+		from.UTC(), to.UTC())
+	if err != nil {
+		return c, err
+	}
+	defer rows.Close()
+	c.Fees = make(map[string]float64)
+	for rows.Next() {
+		var currency string
+		var fee float64
+		if err := rows.Scan(&currency, &fee); err != nil {
+			return c, err
+		}
+		c.Fees[currency] += fee
+	}
+	return c, rows.Err()
+}
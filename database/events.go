@@ -0,0 +1,69 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Sources of order events.
+const (
+	SourceWebhook   = "webhook"   // The order was placed for a webhook signal
+	SourcePoll      = "poll"      // Seen by the reconciler polling Kraken
+	SourceWebSocket = "websocket" // Pushed by a Kraken WebSocket feed
+)
+
+// OrderEvent is a state change of a trade's order. Events are append-only;
+// trades.status is the status of the latest event.
+type OrderEvent struct {
+	ID        int64           `json:"id"`
+	TradeID   int64           `json:"trade_id"`
+	Status    string          `json:"status"`
+	Source    string          `json:"source"`
+	Data      json.RawMessage `json:"data,omitempty"` // Raw exchange data the change was derived from
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// RecordOrderEvent appends a state change to the history of a trade and updates
+// the trade's current status from it. Data is stored as JSON.
+func (db *DB) RecordOrderEvent(tradeID int64, status, source string, data interface{}) error {
+	var dataJSON []byte
+	if data != nil {
+		var err error
+		if dataJSON, err = json.Marshal(data); err != nil {
+			return err
+		}
+	}
+
+	return db.inTx(func(tx *Tx) error {
+		if _, err := tx.Exec("INSERT INTO order_events (trade_id, status, source, data, created_at) VALUES (?, ?, ?, ?, ?)",
+			tradeID, status, source, string(dataJSON), time.Now().UTC()); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`UPDATE trades SET status = (SELECT status FROM order_events WHERE trade_id = ? ORDER BY id DESC LIMIT 1)
+			WHERE id = ?`, tradeID, tradeID)
+		return err
+	})
+}
+
+// GetOrderEvents returns the state history of a trade, oldest first.
+func (db *DB) GetOrderEvents(tradeID int64) ([]OrderEvent, error) {
+	rows, err := db.Query("SELECT id, trade_id, status, source, data, created_at FROM order_events WHERE trade_id = ? ORDER BY id", tradeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []OrderEvent{}
+	for rows.Next() {
+		var e OrderEvent
+		var data string
+		if err := rows.Scan(&e.ID, &e.TradeID, &e.Status, &e.Source, &data, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if data != "" {
+			e.Data = json.RawMessage(data)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"strings"
+	"time"
+)
+
+// Fill is a single execution of a trade's order, as reported by Kraken.
+type Fill struct {
+	ID            int64     `json:"id"`
+	TradeID       int64     `json:"trade_id"`
+	KrakenTradeID string    `json:"kraken_trade_id"`
+	Price         float64   `json:"price"`
+	Volume        float64   `json:"volume"`
+	Cost          float64   `json:"cost"`
+	Fee           float64   `json:"fee"`
+	FeeCurrency   string    `json:"fee_currency"`
+	ExecutedAt    time.Time `json:"executed_at"`
+}
+
+// SaveFill stores a fill and recomputes the execution summary of its trade.
+// Fills are identified by their Kraken trade ID; saving a known fill is a no-op.
+// It reports whether the fill was new.
+func (db *DB) SaveFill(f Fill) (bool, error) {
+	res, err := db.Exec(`INSERT INTO fills (trade_id, kraken_trade_id, price, volume, cost, fee, fee_currency, executed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (kraken_trade_id) DO NOTHING`,
+		f.TradeID, f.KrakenTradeID, f.Price, f.Volume, f.Cost, f.Fee, f.FeeCurrency, f.ExecutedAt)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	return true, db.updateTradeExecution(f.TradeID)
+}
+
+// updateTradeExecution recomputes the average fill price, executed volume and fees of a trade.
+func (db *DB) updateTradeExecution(tradeID int64) error {
+	_, err := db.Exec(`UPDATE trades SET
+			executed_volume = COALESCE((SELECT SUM(volume) FROM fills WHERE trade_id = ?), 0),
+			avg_price = COALESCE((SELECT SUM(price * volume) / SUM(volume) FROM fills WHERE trade_id = ? AND volume > 0), 0),
+			fee = COALESCE((SELECT SUM(fee) FROM fills WHERE trade_id = ?), 0)
+		WHERE id = ?`,
+		tradeID, tradeID, tradeID, tradeID)
+	return err
+}
+
+func (db *DB) GetFills(tradeID int64) ([]Fill, error) {
+	fills, err := db.getFills([]int64{tradeID})
+	return fills[tradeID], err
+}
+
+// getFills returns the fills of the given trades keyed by trade ID.
+func (db *DB) getFills(tradeIDs []int64) (map[int64][]Fill, error) {
+	fills := make(map[int64][]Fill)
+	if len(tradeIDs) == 0 {
+		return fills, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(tradeIDs)), ",")
+	args := make([]interface{}, len(tradeIDs))
+	for i, id := range tradeIDs {
+		args[i] = id
+	}
+
+	rows, err := db.Query(`SELECT id, trade_id, kraken_trade_id, price, volume, cost, fee, fee_currency, executed_at
+		FROM fills WHERE trade_id IN (`+placeholders+`) ORDER BY executed_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f Fill
+		if err := rows.Scan(&f.ID, &f.TradeID, &f.KrakenTradeID, &f.Price, &f.Volume, &f.Cost, &f.Fee, &f.FeeCurrency, &f.ExecutedAt); err != nil {
+			return nil, err
+		}
+		fills[f.TradeID] = append(fills[f.TradeID], f)
+	}
+	return fills, rows.Err()
+}
+
+// attachFills loads the fills of each trade into its Fills field.
+func (db *DB) attachFills(trades []Trade) error {
+	ids := make([]int64, len(trades))
+	for i, t := range trades {
+		ids[i] = t.ID
+	}
+	fills, err := db.getFills(ids)
+	if err != nil {
+		return err
+	}
+	for i := range trades {
+		trades[i].Fills = fills[trades[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrBackupUnsupported is returned when online backups are requested for a database other than SQLite.
+var ErrBackupUnsupported = errors.New("online backups are only supported for SQLite, use pg_dump for PostgreSQL")
+
+// SQLiteFile returns the file of a SQLite DSN, or false if the DSN selects another database.
+func SQLiteFile(dsn string) (string, bool) {
+	d, file := dialectFor(dsn)
+	_, ok := d.(sqlite)
+	return file, ok
+}
+
+// Backup writes a consistent copy of the SQLite database to path while it stays in use.
+// The file must not exist yet.
+func (db *DB) Backup(path string) error {
+	if _, ok := db.dialect.(sqlite); !ok {
+		return ErrBackupUnsupported
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup %s already exists", path)
+	}
+	_, err := db.Exec("VACUUM INTO ?", path)
+	return err
+}
+
+// CheckIntegrity verifies a SQLite database file without modifying it.
+func CheckIntegrity(path string) error {
+	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var result string
+	if err := conn.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return fmt.Errorf("%s is not a readable SQLite database: %w", path, err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check of %s failed: %s", path, result)
+	}
+	return nil
+}
+
+// PruneSignals removes the raw payloads of signals received before the cutoff,
+// and the request/response details of their processing steps. The signal rows,
+// their status, trades, fills and PnL are kept.
+// It returns the number of pruned signals.
+func (db *DB) PruneSignals(before time.Time) (int64, error) {
+	var n int64
+	err := db.inTx(func(tx *Tx) error {
+		res, err := tx.Exec("UPDATE signals SET payload = '' WHERE received_at < ? AND payload <> ''", db.dialect.Time(before))
+		if err != nil {
+			return err
+		}
+		if n, err = res.RowsAffected(); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE signal_steps SET detail = '' WHERE detail <> ''
+			AND signal_id IN (SELECT id FROM signals WHERE received_at < ?)`, db.dialect.Time(before))
+		return err
+	})
+	return n, err
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// TelegramMessage is the Telegram notification of a signal. Later updates of the
+// signal's orders edit it or reply to it.
+type TelegramMessage struct {
+	SignalID  int64     `json:"signal_id"`
+	ChatID    int64     `json:"chat_id"`
+	MessageID int64     `json:"message_id"`
+	ThreadID  int64     `json:"thread_id,omitempty"` // Forum topic, 0 for the general chat
+	Text      string    `json:"text"`                // Current text, as sent with its formatting
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SaveTelegramMessage stores the message of a signal in a chat, replacing an earlier one.
+func (db *DB) SaveTelegramMessage(m TelegramMessage) error {
+	now := time.Now().UTC()
+	_, err := db.Exec(`INSERT INTO telegram_messages (signal_id, chat_id, message_id, thread_id, text, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (signal_id, chat_id) DO UPDATE SET message_id = excluded.message_id, thread_id = excluded.thread_id,
+			text = excluded.text, created_at = excluded.created_at, updated_at = excluded.updated_at`,
+		m.SignalID, m.ChatID, m.MessageID, m.ThreadID, m.Text, now, now)
+	return err
+}
+
+// SetTelegramMessageText records the text of an edited message.
+func (db *DB) SetTelegramMessageText(signalID, chatID int64, text string) error {
+	_, err := db.Exec("UPDATE telegram_messages SET text = ?, updated_at = ? WHERE signal_id = ? AND chat_id = ?",
+		text, time.Now().UTC(), signalID, chatID)
+	return err
+}
+
+// GetTelegramMessage returns the message of a signal in a chat, or nil if there is none.
+func (db *DB) GetTelegramMessage(signalID, chatID int64) (*TelegramMessage, error) {
+	var m TelegramMessage
+	err := db.QueryRow(`SELECT signal_id, chat_id, message_id, thread_id, text, created_at, updated_at
+		FROM telegram_messages WHERE signal_id = ? AND chat_id = ?`, signalID, chatID).
+		Scan(&m.SignalID, &m.ChatID, &m.MessageID, &m.ThreadID, &m.Text, &m.CreatedAt, &m.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
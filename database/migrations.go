@@ -0,0 +1,402 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// migration is a versioned schema change. Versions must be unique and increasing;
+// never edit a migration that has been released, add a new one instead.
+type migration struct {
+	version int
+	name    string
+	up      []string
+	down    []string
+}
+
+// migrations lists all schema changes in order. The first migrations use
+// IF NOT EXISTS so databases created before versioning are adopted as-is.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create signals, trades, sweeps and futures orders",
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS signals (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				received_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				pair TEXT,
+				type TEXT,
+				payload TEXT
+			);`,
+			`CREATE TABLE IF NOT EXISTS trades (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				signal_id INTEGER,
+				pair TEXT,
+				type TEXT,
+				ordertype TEXT,
+				volume TEXT,
+				price TEXT,
+				txid TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				status TEXT DEFAULT 'open',
+				pnl REAL DEFAULT 0,
+				FOREIGN KEY(signal_id) REFERENCES signals(id)
+			);`,
+			`CREATE TABLE IF NOT EXISTS sweeps (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				asset TEXT NOT NULL,
+				withdraw_key TEXT,
+				amount REAL,
+				refid TEXT,
+				created_at DATETIME
+			);`,
+			`CREATE TABLE IF NOT EXISTS futures_orders (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				signal_id INTEGER,
+				symbol TEXT,
+				side TEXT,
+				ordertype TEXT,
+				size TEXT,
+				price TEXT,
+				order_id TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY(signal_id) REFERENCES signals(id)
+			);`,
+		},
+		down: []string{
+			`DROP TABLE futures_orders;`,
+			`DROP TABLE sweeps;`,
+			`DROP TABLE trades;`,
+			`DROP TABLE signals;`,
+		},
+	},
+	{
+		version: 2,
+		name:    "create earn_moves",
+		up: []string{
+			`CREATE TABLE IF NOT EXISTS earn_moves (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				signal_id INTEGER,
+				strategy_id TEXT,
+				asset TEXT,
+				direction TEXT,
+				amount TEXT,
+				status TEXT,
+				error TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);`,
+		},
+		down: []string{
+			`DROP TABLE earn_moves;`,
+		},
+	},
+	{
+		version: 3,
+		name:    "add fills and trade execution",
+		up: []string{
+			`CREATE TABLE fills (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				trade_id INTEGER NOT NULL,
+				kraken_trade_id TEXT NOT NULL UNIQUE,
+				price REAL,
+				volume REAL,
+				cost REAL,
+				fee REAL,
+				fee_currency TEXT,
+				executed_at DATETIME,
+				FOREIGN KEY(trade_id) REFERENCES trades(id)
+			);`,
+			`CREATE INDEX idx_fills_trade_id ON fills(trade_id);`,
+			`ALTER TABLE trades ADD COLUMN avg_price REAL DEFAULT 0;`,
+			`ALTER TABLE trades ADD COLUMN executed_volume REAL DEFAULT 0;`,
+			`ALTER TABLE trades ADD COLUMN fee REAL DEFAULT 0;`,
+			`ALTER TABLE trades ADD COLUMN parent_trade_id INTEGER REFERENCES trades(id);`,
+		},
+		down: []string{
+			`ALTER TABLE trades DROP COLUMN parent_trade_id;`,
+			`ALTER TABLE trades DROP COLUMN fee;`,
+			`ALTER TABLE trades DROP COLUMN executed_volume;`,
+			`ALTER TABLE trades DROP COLUMN avg_price;`,
+			`DROP TABLE fills;`,
+		},
+	},
+	{
+		version: 4,
+		name:    "add signal strategy",
+		up: []string{
+			`ALTER TABLE signals ADD COLUMN strategy TEXT DEFAULT '';`,
+		},
+		down: []string{
+			`ALTER TABLE signals DROP COLUMN strategy;`,
+		},
+	},
+	{
+		version: 5,
+		name:    "add signal audit trail",
+		up: []string{
+			`ALTER TABLE signals ADD COLUMN status TEXT DEFAULT 'received';`,
+			`CREATE TABLE signal_steps (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				signal_id INTEGER NOT NULL,
+				step TEXT,
+				status TEXT,
+				detail TEXT,
+				created_at DATETIME,
+				FOREIGN KEY(signal_id) REFERENCES signals(id)
+			);`,
+			`CREATE INDEX idx_signal_steps_signal_id ON signal_steps(signal_id);`,
+		},
+		down: []string{
+			`DROP TABLE signal_steps;`,
+			`ALTER TABLE signals DROP COLUMN status;`,
+		},
+	},
+	{
+		version: 6,
+		name:    "add order events",
+		up: []string{
+			`CREATE TABLE order_events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				trade_id INTEGER NOT NULL,
+				status TEXT,
+				source TEXT,
+				data TEXT,
+				created_at DATETIME,
+				FOREIGN KEY(trade_id) REFERENCES trades(id)
+			);`,
+			`CREATE INDEX idx_order_events_trade_id ON order_events(trade_id);`,
+			// Start the history of existing trades with their current status.
+			`INSERT INTO order_events (trade_id, status, source, data, created_at)
+				SELECT id, status, 'migration', '', created_at FROM trades;`,
+		},
+		down: []string{
+			`DROP TABLE order_events;`,
+		},
+	},
+	{
+		version: 7,
+		name:    "add equity snapshots",
+		up: []string{
+			`CREATE TABLE equity_snapshots (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				taken_at DATETIME,
+				currency TEXT,
+				balance_value REAL,
+				unrealized_pnl REAL,
+				equity REAL,
+				balances TEXT
+			);`,
+			`CREATE INDEX idx_equity_snapshots_taken_at ON equity_snapshots(taken_at);`,
+		},
+		down: []string{
+			`DROP TABLE equity_snapshots;`,
+		},
+	},
+	{
+		version: 8,
+		name:    "add pending orders",
+		up: []string{
+			`CREATE TABLE pending_orders (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				signal_id INTEGER,
+				request TEXT,
+				status TEXT DEFAULT 'pending',
+				chat_id INTEGER DEFAULT 0,
+				message_id INTEGER DEFAULT 0,
+				created_at DATETIME,
+				expires_at DATETIME,
+				decided_at DATETIME,
+				decided_by TEXT DEFAULT '',
+				FOREIGN KEY(signal_id) REFERENCES signals(id)
+			);`,
+			`CREATE INDEX idx_pending_orders_status ON pending_orders(status, expires_at);`,
+		},
+		down: []string{
+			`DROP TABLE pending_orders;`,
+		},
+	},
+	{
+		version: 9,
+		name:    "add telegram messages",
+		up: []string{
+			`CREATE TABLE telegram_messages (
+				signal_id INTEGER NOT NULL,
+				chat_id INTEGER NOT NULL,
+				message_id INTEGER NOT NULL,
+				thread_id INTEGER DEFAULT 0,
+				text TEXT DEFAULT '',
+				created_at DATETIME,
+				updated_at DATETIME,
+				PRIMARY KEY (signal_id, chat_id),
+				FOREIGN KEY(signal_id) REFERENCES signals(id)
+			);`,
+		},
+		down: []string{
+			`DROP TABLE telegram_messages;`,
+		},
+	},
+}
+
+// MigrationStatus describes whether a migration has been applied.
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"` // nil if pending
+}
+
+// querier is implemented by DB and Tx.
+type querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (db *DB) ensureMigrationsTable(q querier) error {
+	_, err := q.Exec(db.dialect.DDL(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`))
+	return err
+}
+
+func (db *DB) appliedMigrations(q querier) (map[int]time.Time, error) {
+	if err := db.ensureMigrationsTable(q); err != nil {
+		return nil, fmt.Errorf("error creating schema_migrations: %w", err)
+	}
+
+	rows, err := q.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// MigrationStatuses lists all known migrations and when they were applied.
+func (db *DB) MigrationStatuses() ([]MigrationStatus, error) {
+	applied, err := db.appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Version: m.version, Name: m.name}
+		if at, ok := applied[m.version]; ok {
+			s.AppliedAt = &at
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
+
+// MigrateUp applies all pending migrations up to and including target
+// (0 means the latest version) in a single transaction.
+// It returns the versions that were applied.
+func (db *DB) MigrateUp(target int) ([]int, error) {
+	var versions []int
+	err := db.inTx(func(tx *Tx) error {
+		applied, err := db.lockedMigrations(tx)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if _, ok := applied[m.version]; ok || (target != 0 && m.version > target) {
+				continue
+			}
+			for _, query := range m.up {
+				if _, err := tx.Exec(db.dialect.DDL(query)); err != nil {
+					return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+				}
+			}
+			if _, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.version, m.name); err != nil {
+				return fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+			}
+			versions = append(versions, m.version)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// MigrateDown reverts the latest steps applied migrations in a single transaction.
+// It returns the versions that were reverted.
+func (db *DB) MigrateDown(steps int) ([]int, error) {
+	var versions []int
+	err := db.inTx(func(tx *Tx) error {
+		applied, err := db.lockedMigrations(tx)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && len(versions) < steps; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.version]; !ok {
+				continue
+			}
+			for _, query := range m.down {
+				if _, err := tx.Exec(db.dialect.DDL(query)); err != nil {
+					return fmt.Errorf("reverting migration %d (%s): %w", m.version, m.name, err)
+				}
+			}
+			if _, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.version); err != nil {
+				return fmt.Errorf("reverting migration %d (%s): %w", m.version, m.name, err)
+			}
+			versions = append(versions, m.version)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// lockedMigrations takes the migration lock and returns the applied migrations.
+// Reading them under the lock keeps instances sharing a database from applying a migration twice.
+func (db *DB) lockedMigrations(tx *Tx) (map[int]time.Time, error) {
+	if err := db.dialect.lockMigrations(tx.Tx); err != nil {
+		return nil, fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	return db.appliedMigrations(tx)
+}
+
+// Tx is a transaction that accepts ? placeholders like DB.
+type Tx struct {
+	*sql.Tx
+	dialect Dialect
+}
+
+func (tx *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.Tx.Exec(tx.dialect.Rebind(query), args...)
+}
+
+func (tx *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.Tx.Query(tx.dialect.Rebind(query), args...)
+}
+
+// inTx runs fn in a transaction, committing if it returns nil and rolling back otherwise.
+func (db *DB) inTx(fn func(tx *Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(&Tx{Tx: tx, dialect: db.dialect}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
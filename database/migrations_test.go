@@ -0,0 +1,61 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	db, err := InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	defer db.Close()
+
+	statuses, err := db.MigrationStatuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			t.Fatalf("migration %d not applied after InitDB", s.Version)
+		}
+	}
+
+	// Revert the latest migration and apply it again.
+	latest := migrations[len(migrations)-1].version
+	reverted, err := db.MigrateDown(1)
+	if err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if len(reverted) != 1 || reverted[0] != latest {
+		t.Fatalf("reverted %v, want [%d]", reverted, latest)
+	}
+	applied, err := db.MigrateUp(0)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if len(applied) != 1 || applied[0] != latest {
+		t.Fatalf("applied %v, want [%d]", applied, latest)
+	}
+
+	// Reverting everything leaves only schema_migrations.
+	if _, err := db.MigrateDown(len(migrations)); err != nil {
+		t.Fatalf("MigrateDown all: %v", err)
+	}
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_migrations', 'sqlite_sequence')").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Fatalf("%d tables left after reverting all migrations", tables)
+	}
+}
+
+func TestMigrationVersionsIncrease(t *testing.T) {
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version <= migrations[i-1].version {
+			t.Fatalf("migration %d is not after %d", migrations[i].version, migrations[i-1].version)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Statuses of a pending order.
+const (
+	PendingStatusPending  = "pending"  // Waiting for a decision
+	PendingStatusApproved = "approved" // Approved and sent to the exchange
+	PendingStatusRejected = "rejected"
+	PendingStatusExpired  = "expired" // No decision before the timeout
+)
+
+// PendingOrder is the order of a signal waiting for manual confirmation.
+type PendingOrder struct {
+	ID        int64           `json:"id"`
+	SignalID  int64           `json:"signal_id"`
+	Request   json.RawMessage `json:"request"` // The webhook request, including later modifications
+	Status    string          `json:"status"`
+	ChatID    int64           `json:"chat_id,omitempty"`
+	MessageID int64           `json:"message_id,omitempty"` // Telegram message with the confirmation buttons
+	CreatedAt time.Time       `json:"created_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	DecidedAt *time.Time      `json:"decided_at,omitempty"`
+	DecidedBy string          `json:"decided_by,omitempty"`
+}
+
+const pendingColumns = "id, signal_id, request, status, chat_id, message_id, created_at, expires_at, decided_at, decided_by"
+
+// SavePendingOrder stores the order of a signal until it is approved, rejected or expires.
+// Request is stored as JSON.
+func (db *DB) SavePendingOrder(signalID int64, request interface{}, expiresAt time.Time) (int64, error) {
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return 0, err
+	}
+	return db.insert("INSERT INTO pending_orders (signal_id, request, status, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		nullID(signalID), string(requestJSON), PendingStatusPending, time.Now().UTC(), expiresAt.UTC())
+}
+
+// SetPendingMessage records the Telegram message that asks for the decision.
+func (db *DB) SetPendingMessage(id, chatID, messageID int64) error {
+	_, err := db.Exec("UPDATE pending_orders SET chat_id = ?, message_id = ? WHERE id = ?", chatID, messageID, id)
+	return err
+}
+
+// UpdatePendingRequest replaces the request of an order that is still pending.
+// It reports false if the order is no longer pending.
+func (db *DB) UpdatePendingRequest(id int64, request interface{}) (bool, error) {
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return false, err
+	}
+	res, err := db.Exec("UPDATE pending_orders SET request = ? WHERE id = ? AND status = ?", string(requestJSON), id, PendingStatusPending)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// DecidePendingOrder moves a pending order to status. It reports false if the order
+// was already decided, so concurrent decisions execute an order at most once.
+func (db *DB) DecidePendingOrder(id int64, status, decidedBy string) (bool, error) {
+	res, err := db.Exec("UPDATE pending_orders SET status = ?, decided_at = ?, decided_by = ? WHERE id = ? AND status = ?",
+		status, time.Now().UTC(), decidedBy, id, PendingStatusPending)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// GetPendingOrder returns the pending order with the given ID, or nil if there is none.
+func (db *DB) GetPendingOrder(id int64) (*PendingOrder, error) {
+	orders, err := db.queryPendingOrders("SELECT "+pendingColumns+" FROM pending_orders WHERE id = ?", id)
+	if err != nil || len(orders) == 0 {
+		return nil, err
+	}
+	return &orders[0], nil
+}
+
+// GetPendingOrderBySignal returns the pending order of a signal, or nil if it had none.
+func (db *DB) GetPendingOrderBySignal(signalID int64) (*PendingOrder, error) {
+	orders, err := db.queryPendingOrders("SELECT "+pendingColumns+" FROM pending_orders WHERE signal_id = ? ORDER BY id DESC LIMIT 1", signalID)
+	if err != nil || len(orders) == 0 {
+		return nil, err
+	}
+	return &orders[0], nil
+}
+
+// GetExpiredPendingOrders returns the orders still waiting for a decision after their expiry time.
+func (db *DB) GetExpiredPendingOrders(now time.Time) ([]PendingOrder, error) {
+	return db.queryPendingOrders("SELECT "+pendingColumns+" FROM pending_orders WHERE status = ? AND expires_at <= ? ORDER BY id",
+		PendingStatusPending, now.UTC())
+}
+
+func (db *DB) queryPendingOrders(query string, args ...interface{}) ([]PendingOrder, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []PendingOrder{}
+	for rows.Next() {
+		var o PendingOrder
+		var signalID sql.NullInt64
+		var request string
+		var decidedAt sql.NullTime
+		if err := rows.Scan(&o.ID, &signalID, &request, &o.Status, &o.ChatID, &o.MessageID, &o.CreatedAt, &o.ExpiresAt, &decidedAt, &o.DecidedBy); err != nil {
+			return nil, err
+		}
+		o.SignalID = signalID.Int64
+		o.Request = json.RawMessage(request)
+		if decidedAt.Valid {
+			o.DecidedAt = &decidedAt.Time
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
@@ -0,0 +1,379 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CostMethod selects how sells are matched against earlier buys (and vice versa for shorts).
+type CostMethod string
+
+const (
+	FIFO        CostMethod = "fifo"    // First in, first out
+	LIFO        CostMethod = "lifo"    // Last in, first out
+	AverageCost CostMethod = "average" // Weighted average cost of the open position
+)
+
+// ParseCostMethod validates a cost method name. An empty name selects FIFO.
+func ParseCostMethod(name string) (CostMethod, error) {
+	switch m := CostMethod(name); m {
+	case "":
+		return FIFO, nil
+	case FIFO, LIFO, AverageCost:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown cost method %q (want fifo, lifo or average)", name)
+	}
+}
+
+// execution is a single buy or sell that changes a position.
+type execution struct {
+	TradeID  int64
+	Strategy string
+	Pair     string
+	Side     string
+	Volume   float64
+	Price    float64
+	Fee      float64
+	Time     time.Time
+}
+
+// lot is an open part of a position. UnitCost includes the opening fee:
+// for long lots it is the price paid per unit, for short lots the price received per unit.
+type lot struct {
+	Side     string
+	Volume   float64
+	UnitCost float64
+	Opened   time.Time
+}
+
+// RealizedPnL is the profit or loss realized by a closing execution.
+type RealizedPnL struct {
+	TradeID   int64     `json:"trade_id"`
+	Pair      string    `json:"pair"`
+	Strategy  string    `json:"strategy"`
+	Side      string    `json:"side"`       // Side of the closed position: buy (long) or sell (short)
+	Volume    float64   `json:"volume"`     // Closed volume
+	Fee       float64   `json:"fee"`        // Fee of the closing execution
+	CostBasis float64   `json:"cost_basis"` // Paid to acquire the volume, including fees
+	Proceeds  float64   `json:"proceeds"`   // Received for disposing of the volume, net of fees
+	PnL       float64   `json:"pnl"`        // Proceeds minus cost basis
+	Opened    time.Time `json:"opened"`
+	Closed    time.Time `json:"closed"`
+}
+
+// Position is the open position of a strategy in a pair after matching all executions.
+type Position struct {
+	Pair          string  `json:"pair"`
+	Strategy      string  `json:"strategy,omitempty"`
+	Side          string  `json:"side"` // buy (long) or sell (short)
+	Volume        float64 `json:"volume"`
+	AvgCost       float64 `json:"avg_cost"` // Per unit, including opening fees
+	Price         float64 `json:"price,omitempty"`
+	UnrealizedPnL float64 `json:"unrealized_pnl"`
+}
+
+// PnLReport is the result of matching all executions with a cost method.
+type PnLReport struct {
+	Method    CostMethod    `json:"method"`
+	Realized  []RealizedPnL `json:"realized"`
+	Positions []Position    `json:"positions"`
+}
+
+// ComputePnL matches all executed buys and sells per pair and strategy using the cost method.
+// Trades with fills use their fills; closed trades without fills fall back to the
+// requested volume and price.
+func (db *DB) ComputePnL(method CostMethod) (*PnLReport, error) {
+	return db.ComputePairPnL(method, "")
+}
+
+// ComputePairPnL is ComputePnL limited to the executions of one pair; an empty pair
+// selects all pairs.
+func (db *DB) ComputePairPnL(method CostMethod, pair string) (*PnLReport, error) {
+	execs, err := db.executions(pair)
+	if err != nil {
+		return nil, err
+	}
+	return matchExecutions(execs, method), nil
+}
+
+// UpdateRealizedPnL recomputes the realized PnL of every trade and stores it in trades.pnl.
+func (db *DB) UpdateRealizedPnL(method CostMethod) error {
+	report, err := db.ComputePnL(method)
+	if err != nil {
+		return err
+	}
+
+	perTrade := make(map[int64]float64)
+	for _, r := range report.Realized {
+		perTrade[r.TradeID] += r.PnL
+	}
+
+	return db.inTx(func(tx *Tx) error {
+		if _, err := tx.Exec("UPDATE trades SET pnl = 0"); err != nil {
+			return err
+		}
+		for id, pnl := range perTrade {
+			if _, err := tx.Exec("UPDATE trades SET pnl = ? WHERE id = ?", pnl, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// executions loads the executions of pair, or of all pairs if it is empty, ordered by time.
+func (db *DB) executions(pair string) ([]execution, error) {
+	rows, err := db.Query(`SELECT t.id, COALESCE(s.strategy, ''), t.pair, t.type, f.volume, f.price, f.fee, COALESCE(f.fee_currency, ''), f.executed_at
+		FROM fills f
+		JOIN trades t ON t.id = f.trade_id
+		LEFT JOIN signals s ON s.id = t.signal_id
+		WHERE ? = '' OR t.pair = ?`, pair, pair)
+	if err != nil {
+		return nil, err
+	}
+	var execs []execution
+	for rows.Next() {
+		var e execution
+		var feeCurrency string
+		if err := rows.Scan(&e.TradeID, &e.Strategy, &e.Pair, &e.Side, &e.Volume, &e.Price, &e.Fee, &feeCurrency, &e.Time); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if feeInBase(e.Pair, feeCurrency) {
+			e.Fee *= e.Price
+		}
+		execs = append(execs, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Closed trades recorded before fills were tracked.
+	rows, err = db.Query(`SELECT t.id, COALESCE(s.strategy, ''), t.pair, t.type, t.volume, t.price, t.created_at
+		FROM trades t
+		LEFT JOIN signals s ON s.id = t.signal_id
+		WHERE t.status = 'closed' AND NOT EXISTS (SELECT 1 FROM fills f WHERE f.trade_id = t.id)
+			AND (? = '' OR t.pair = ?)`, pair, pair)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var e execution
+		var volume, price string
+		if err := rows.Scan(&e.TradeID, &e.Strategy, &e.Pair, &e.Side, &volume, &price, &e.Time); err != nil {
+			return nil, err
+		}
+		var errV, errP error
+		e.Volume, errV = strconv.ParseFloat(volume, 64)
+		e.Price, errP = strconv.ParseFloat(price, 64)
+		if errV != nil || errP != nil || e.Volume <= 0 || e.Price <= 0 {
+			continue // e.g. market orders without a known price
+		}
+		execs = append(execs, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(execs, func(i, j int) bool { return execs[i].Time.Before(execs[j].Time) })
+	return execs, nil
+}
+
+// feeInBase reports whether a fee was charged in the base currency of pair, as for orders
+// placed with the "fcib" flag. Fees are in the quote currency when the pair ends with the
+// fee currency, with or without Kraken's "X"/"Z" prefix (e.g. "ZUSD" for "XBTUSD").
+func feeInBase(pair, feeCurrency string) bool {
+	if feeCurrency == "" {
+		return false
+	}
+	quote := feeCurrency
+	if len(quote) == 4 && (quote[0] == 'X' || quote[0] == 'Z') {
+		quote = quote[1:]
+	}
+	return !strings.HasSuffix(pair, feeCurrency) && !strings.HasSuffix(pair, quote)
+}
+
+// positionKey identifies the lots of a strategy in a pair.
+type positionKey struct {
+	Pair     string
+	Strategy string
+}
+
+// matchExecutions runs the lot matching for all pairs, keeping the positions of each
+// strategy apart so one strategy's sells never close another strategy's buys.
+func matchExecutions(execs []execution, method CostMethod) *PnLReport {
+	report := &PnLReport{Method: method, Realized: []RealizedPnL{}, Positions: []Position{}}
+	lots := make(map[positionKey][]lot)
+
+	for _, e := range execs {
+		if e.Volume <= 0 {
+			continue
+		}
+		feePerUnit := e.Fee / e.Volume
+		remaining := e.Volume
+		key := positionKey{Pair: e.Pair, Strategy: e.Strategy}
+		open := lots[key]
+
+		// Close lots of the opposite side first.
+		for remaining > 1e-12 && len(open) > 0 && open[0].Side != e.Side {
+			idx := 0
+			if method == LIFO {
+				idx = len(open) - 1
+			}
+			l := &open[idx]
+			qty := min(remaining, l.Volume)
+
+			// A long is acquired when the lot opened, a short when it is bought back.
+			var costBasis, proceeds float64
+			if l.Side == "buy" {
+				costBasis = l.UnitCost * qty
+				proceeds = (e.Price - feePerUnit) * qty
+			} else {
+				costBasis = (e.Price + feePerUnit) * qty
+				proceeds = l.UnitCost * qty
+			}
+			report.Realized = append(report.Realized, RealizedPnL{
+				TradeID:   e.TradeID,
+				Pair:      e.Pair,
+				Strategy:  e.Strategy,
+				Side:      l.Side,
+				Volume:    qty,
+				Fee:       feePerUnit * qty,
+				CostBasis: costBasis,
+				Proceeds:  proceeds,
+				PnL:       proceeds - costBasis,
+				Opened:    l.Opened,
+				Closed:    e.Time,
+			})
+
+			l.Volume -= qty
+			remaining -= qty
+			if l.Volume <= 1e-12 {
+				open = append(open[:idx], open[idx+1:]...)
+			}
+		}
+
+		// Whatever is left opens (or adds to) a position.
+		if remaining > 1e-12 {
+			unitCost := e.Price + feePerUnit
+			if e.Side == "sell" {
+				unitCost = e.Price - feePerUnit
+			}
+			newLot := lot{Side: e.Side, Volume: remaining, UnitCost: unitCost, Opened: e.Time}
+
+			if method == AverageCost && len(open) > 0 {
+				l := &open[0]
+				total := l.Volume + newLot.Volume
+				l.UnitCost = (l.UnitCost*l.Volume + newLot.UnitCost*newLot.Volume) / total
+				l.Volume = total
+			} else {
+				open = append(open, newLot)
+			}
+		}
+		lots[key] = open
+	}
+
+	keys := make([]positionKey, 0, len(lots))
+	for key := range lots {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Pair != keys[j].Pair {
+			return keys[i].Pair < keys[j].Pair
+		}
+		return keys[i].Strategy < keys[j].Strategy
+	})
+	for _, key := range keys {
+		open := lots[key]
+		if len(open) == 0 {
+			continue
+		}
+		pos := Position{Pair: key.Pair, Strategy: key.Strategy, Side: open[0].Side}
+		var cost float64
+		for _, l := range open {
+			pos.Volume += l.Volume
+			cost += l.UnitCost * l.Volume
+		}
+		pos.AvgCost = cost / pos.Volume
+		report.Positions = append(report.Positions, pos)
+	}
+	return report
+}
+
+// ApplyPrices values the open positions at the given prices (keyed by pair)
+// and sets their unrealized PnL. Positions without a price are left at zero.
+func (r *PnLReport) ApplyPrices(prices map[string]float64) {
+	for i := range r.Positions {
+		p := &r.Positions[i]
+		price, ok := prices[p.Pair]
+		if !ok {
+			continue
+		}
+		p.Price = price
+		if p.Side == "buy" {
+			p.UnrealizedPnL = (price - p.AvgCost) * p.Volume
+		} else {
+			p.UnrealizedPnL = (p.AvgCost - price) * p.Volume
+		}
+	}
+}
+
+// PnLBucket aggregates realized PnL for one group (a day, pair or strategy).
+type PnLBucket struct {
+	Key    string  `json:"key"`
+	PnL    float64 `json:"pnl"`
+	Fees   float64 `json:"fees"`
+	Closes int     `json:"closes"` // Number of closing executions
+	Wins   int     `json:"wins"`
+	Losses int     `json:"losses"`
+	Volume float64 `json:"volume"`
+}
+
+// Aggregate groups the realized PnL by "day" (UTC, YYYY-MM-DD), "pair" or "strategy".
+func (r *PnLReport) Aggregate(groupBy string) ([]PnLBucket, error) {
+	var keyOf func(RealizedPnL) string
+	switch groupBy {
+	case "day":
+		keyOf = func(p RealizedPnL) string { return p.Closed.UTC().Format("2006-01-02") }
+	case "pair":
+		keyOf = func(p RealizedPnL) string { return p.Pair }
+	case "strategy":
+		keyOf = func(p RealizedPnL) string { return p.Strategy }
+	default:
+		return nil, fmt.Errorf("unknown group %q (want day, pair or strategy)", groupBy)
+	}
+
+	buckets := make(map[string]*PnLBucket)
+	var keys []string
+	for _, p := range r.Realized {
+		key := keyOf(p)
+		b, ok := buckets[key]
+		if !ok {
+			b = &PnLBucket{Key: key}
+			buckets[key] = b
+			keys = append(keys, key)
+		}
+		b.PnL += p.PnL
+		b.Fees += p.Fee
+		b.Volume += p.Volume
+		b.Closes++
+		if p.PnL >= 0 {
+			b.Wins++
+		} else {
+			b.Losses++
+		}
+	}
+
+	sort.Strings(keys)
+	result := make([]PnLBucket, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, *buckets[key])
+	}
+	return result, nil
+}
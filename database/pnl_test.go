@@ -0,0 +1,142 @@
+package database
+
+import (
+	"math"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMatchExecutions(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	execs := []execution{
+		{TradeID: 1, Strategy: "trend", Pair: "XBTUSD", Side: "buy", Volume: 1, Price: 100, Fee: 1, Time: t0},
+		{TradeID: 2, Strategy: "trend", Pair: "XBTUSD", Side: "buy", Volume: 1, Price: 120, Fee: 1, Time: t0.Add(time.Hour)},
+		{TradeID: 3, Strategy: "trend", Pair: "XBTUSD", Side: "sell", Volume: 1, Price: 130, Fee: 1, Time: t0.Add(24 * time.Hour)},
+	}
+
+	tests := []struct {
+		method   CostMethod
+		realized float64
+		avgCost  float64
+	}{
+		{FIFO, 130 - 1 - 101, 121},
+		{LIFO, 130 - 1 - 121, 101},
+		{AverageCost, 130 - 1 - 111, 111},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.method), func(t *testing.T) {
+			report := matchExecutions(execs, tt.method)
+			if len(report.Realized) != 1 || math.Abs(report.Realized[0].PnL-tt.realized) > 1e-9 {
+				t.Fatalf("realized = %+v, want %v", report.Realized, tt.realized)
+			}
+			if len(report.Positions) != 1 || math.Abs(report.Positions[0].AvgCost-tt.avgCost) > 1e-9 {
+				t.Fatalf("positions = %+v, want avg cost %v", report.Positions, tt.avgCost)
+			}
+
+			report.ApplyPrices(map[string]float64{"XBTUSD": 140})
+			if want := 140 - tt.avgCost; math.Abs(report.Positions[0].UnrealizedPnL-want) > 1e-9 {
+				t.Fatalf("unrealized = %v, want %v", report.Positions[0].UnrealizedPnL, want)
+			}
+		})
+	}
+}
+
+func TestMatchExecutionsShort(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	execs := []execution{
+		{TradeID: 1, Pair: "ETHUSD", Side: "sell", Volume: 2, Price: 50, Time: t0},
+		{TradeID: 2, Pair: "ETHUSD", Side: "buy", Volume: 3, Price: 40, Time: t0.Add(time.Hour)},
+	}
+
+	report := matchExecutions(execs, FIFO)
+	if len(report.Realized) != 1 || report.Realized[0].PnL != 20 {
+		t.Fatalf("realized = %+v, want 20", report.Realized)
+	}
+	// The remaining buy volume flips the position to long.
+	if len(report.Positions) != 1 || report.Positions[0].Side != "buy" || report.Positions[0].Volume != 1 {
+		t.Fatalf("positions = %+v", report.Positions)
+	}
+
+	buckets, err := report.Aggregate("day")
+	if err != nil || len(buckets) != 1 || buckets[0].Key != "2026-01-01" || buckets[0].Wins != 1 {
+		t.Fatalf("buckets = %+v, err %v", buckets, err)
+	}
+}
+
+func TestMatchExecutionsPerStrategy(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	execs := []execution{
+		{TradeID: 1, Strategy: "trend", Pair: "XBTUSD", Side: "buy", Volume: 1, Price: 100, Time: t0},
+		{TradeID: 2, Strategy: "scalp", Pair: "XBTUSD", Side: "sell", Volume: 1, Price: 110, Time: t0.Add(time.Hour)},
+	}
+
+	// The scalp short does not close the trend long.
+	report := matchExecutions(execs, FIFO)
+	if len(report.Realized) != 0 || len(report.Positions) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	if p := report.Positions[0]; p.Strategy != "scalp" || p.Side != "sell" {
+		t.Fatalf("positions = %+v", report.Positions)
+	}
+}
+
+func TestFeeInBase(t *testing.T) {
+	tests := []struct {
+		pair, feeCurrency string
+		want              bool
+	}{
+		{"XBTUSD", "ZUSD", false},
+		{"XXBTZUSD", "ZUSD", false},
+		{"XBTUSD", "XXBT", true},
+		{"ETHXBT", "XXBT", false},
+		{"USDTUSD", "ZUSD", false},
+		{"XBTUSD", "", false},
+	}
+	for _, tt := range tests {
+		if got := feeInBase(tt.pair, tt.feeCurrency); got != tt.want {
+			t.Errorf("feeInBase(%q, %q) = %v, want %v", tt.pair, tt.feeCurrency, got, tt.want)
+		}
+	}
+}
+
+func TestComputePairPnL(t *testing.T) {
+	db, err := InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	defer db.Close()
+
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	signalID, _ := db.SaveSignal("XBTUSD", "buy", "trend", nil)
+	for i, f := range []struct {
+		pair, side, feeCurrency string
+		price, fee              float64
+	}{
+		{"XBTUSD", "buy", "ZUSD", 100, 1},
+		{"XBTUSD", "sell", "XXBT", 110, 0.01}, // fcib: 0.01 XBT at 110 is 1.1 USD
+		{"ETHUSD", "buy", "ZUSD", 10, 0},
+	} {
+		tradeID, err := db.SaveTrade(signalID, f.pair, f.side, "market", "1", "", "O"+strconv.Itoa(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fill := Fill{TradeID: tradeID, KrakenTradeID: "T" + strconv.Itoa(i), Price: f.price, Volume: 1, Cost: f.price,
+			Fee: f.fee, FeeCurrency: f.feeCurrency, ExecutedAt: t0.Add(time.Duration(i) * time.Hour)}
+		if _, err := db.SaveFill(fill); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := db.ComputePairPnL(FIFO, "XBTUSD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Positions) != 0 || len(report.Realized) != 1 || math.Abs(report.Realized[0].PnL-(110-1.1-101)) > 1e-9 {
+		t.Fatalf("report = %+v", report)
+	}
+	if report, err := db.ComputePnL(FIFO); err != nil || len(report.Positions) != 1 || report.Positions[0].Pair != "ETHUSD" {
+		t.Fatalf("report = %+v, err %v", report, err)
+	}
+}
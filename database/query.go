@@ -0,0 +1,152 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Filter selects and paginates signals or trades. Empty fields are not filtered on.
+// Pagination is keyset based: Cursor is the ID of the last row of the previous page.
+type Filter struct {
+	Pair      string
+	Type      string // buy/sell
+	Status    string
+	Strategy  string
+	From      time.Time // Inclusive
+	To        time.Time // Exclusive
+	Cursor    int64
+	Limit     int
+	Ascending bool // Oldest first; newest first by default
+}
+
+// where builds the WHERE and ORDER BY clauses for the filter. Columns are
+// qualified with alias; timeColumn is the timestamp column to filter From/To on
+// and strategyColumn the (possibly joined) strategy column.
+func (f Filter) where(d Dialect, alias, timeColumn, strategyColumn string) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		conds = append(conds, cond)
+		args = append(args, arg)
+	}
+
+	if f.Pair != "" {
+		add(alias+".pair = ?", f.Pair)
+	}
+	if f.Type != "" {
+		add(alias+".type = ?", f.Type)
+	}
+	if f.Status != "" {
+		add(alias+".status = ?", f.Status)
+	}
+	if f.Strategy != "" {
+		add(strategyColumn+" = ?", f.Strategy)
+	}
+	if !f.From.IsZero() {
+		add(alias+"."+timeColumn+" >= ?", d.Time(f.From))
+	}
+	if !f.To.IsZero() {
+		add(alias+"."+timeColumn+" < ?", d.Time(f.To))
+	}
+
+	order := "DESC"
+	if f.Ascending {
+		order = "ASC"
+		if f.Cursor > 0 {
+			add(alias+".id > ?", f.Cursor)
+		}
+	} else if f.Cursor > 0 {
+		add(alias+".id < ?", f.Cursor)
+	}
+
+	query := ""
+	if len(conds) > 0 {
+		query = " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY " + alias + ".id " + order + " LIMIT ?"
+	args = append(args, f.limit())
+	return query, args
+}
+
+func (f Filter) limit() int {
+	if f.Limit <= 0 {
+		return 50
+	}
+	return f.Limit
+}
+
+// nextCursor returns the cursor for the page after one that ended with lastID,
+// or 0 if the page was not full and there are no more rows.
+func (f Filter) nextCursor(n int, lastID int64) int64 {
+	if n < f.limit() {
+		return 0
+	}
+	return lastID
+}
+
+// QuerySignals returns the signals matching the filter and the cursor of the next page (0 if none).
+func (db *DB) QuerySignals(f Filter) ([]Signal, int64, error) {
+	where, args := f.where(db.dialect, "s", "received_at", "s.strategy")
+	rows, err := db.Query("SELECT s.id, s.received_at, s.pair, s.type, s.strategy, s.status, s.payload FROM signals s"+where, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	signals := []Signal{}
+	for rows.Next() {
+		var s Signal
+		if err := rows.Scan(&s.ID, &s.ReceivedAt, &s.Pair, &s.Type, &s.Strategy, &s.Status, &s.Payload); err != nil {
+			return nil, 0, err
+		}
+		signals = append(signals, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var next int64
+	if len(signals) > 0 {
+		next = f.nextCursor(len(signals), signals[len(signals)-1].ID)
+	}
+	return signals, next, nil
+}
+
+// QueryTrades returns the trades (with fills) matching the filter and the cursor of the next page (0 if none).
+// Trades are filtered on strategy through the signal that created them.
+func (db *DB) QueryTrades(f Filter) ([]Trade, int64, error) {
+	where, args := f.where(db.dialect, "t", "created_at", "s.strategy")
+	columns := "t." + strings.ReplaceAll(tradeColumns, ", ", ", t.")
+	trades, err := db.queryTrades("SELECT "+columns+" FROM trades t LEFT JOIN signals s ON s.id = t.signal_id"+where, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	if trades == nil {
+		trades = []Trade{}
+	}
+	if err := db.attachFills(trades); err != nil {
+		return nil, 0, err
+	}
+
+	var next int64
+	if len(trades) > 0 {
+		next = f.nextCursor(len(trades), trades[len(trades)-1].ID)
+	}
+	return trades, next, nil
+}
+
+// GetTrade returns the trade with the given ID including its fills, or nil if there is none.
+func (db *DB) GetTrade(id int64) (*Trade, error) {
+	t, err := scanTrade(db.QueryRow("SELECT "+tradeColumns+" FROM trades WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if t.Fills, err = db.GetFills(t.ID); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
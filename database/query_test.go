@@ -0,0 +1,72 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestQueryTradesPagination(t *testing.T) {
+	db, err := InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	defer db.Close()
+
+	trend, _ := db.SaveSignal("XBTUSD", "buy", "trend", nil)
+	scalp, _ := db.SaveSignal("ETHUSD", "buy", "scalp", nil)
+	for i := 0; i < 5; i++ {
+		if _, err := db.SaveTrade(trend, "XBTUSD", "buy", "market", "1", "", "OXBT"+string(rune('A'+i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.SaveTrade(scalp, "ETHUSD", "buy", "market", "1", "", "OETH"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Page through the trend trades, newest first.
+	var ids []int64
+	f := Filter{Strategy: "trend", Limit: 2}
+	for page := 0; ; page++ {
+		trades, next, err := db.QueryTrades(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tr := range trades {
+			if tr.Pair != "XBTUSD" {
+				t.Fatalf("trade %d has pair %s, want XBTUSD", tr.ID, tr.Pair)
+			}
+			ids = append(ids, tr.ID)
+		}
+		if next == 0 {
+			break
+		}
+		if page > 5 {
+			t.Fatal("pagination does not terminate")
+		}
+		f.Cursor = next
+	}
+	if len(ids) != 5 {
+		t.Fatalf("got %d trades, want 5", len(ids))
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] >= ids[i-1] {
+			t.Fatalf("ids not descending: %v", ids)
+		}
+	}
+
+	trades, _, err := db.QueryTrades(Filter{Pair: "ETHUSD", Ascending: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 1 || trades[0].TxID != "OETH" {
+		t.Fatalf("got %+v, want the ETHUSD trade", trades)
+	}
+
+	signals, next, err := db.QuerySignals(Filter{Strategy: "scalp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signals) != 1 || signals[0].ID != scalp || next != 0 {
+		t.Fatalf("got %+v (next %d), want the scalp signal", signals, next)
+	}
+}
@@ -0,0 +1,17 @@
+package database
+
+import "time"
+
+// SaveSweep records a withdrawal of realized profit, so the profit is not swept again.
+func (db *DB) SaveSweep(asset, key string, amount float64, refID string) error {
+	_, err := db.Exec("INSERT INTO sweeps (asset, withdraw_key, amount, refid, created_at) VALUES (?, ?, ?, ?, ?)",
+		asset, key, amount, refID, time.Now().UTC())
+	return err
+}
+
+// SweptAmount returns the total profit of asset swept so far.
+func (db *DB) SweptAmount(asset string) (float64, error) {
+	var total float64
+	err := db.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM sweeps WHERE asset = ?", asset).Scan(&total)
+	return total, err
+}
@@ -0,0 +1,118 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type Trade struct {
+	ID             int64     `json:"id"`
+	SignalID       int64     `json:"signal_id"`
+	ParentTradeID  *int64    `json:"parent_trade_id,omitempty"` // Set for conditional close orders
+	Pair           string    `json:"pair"`
+	Type           string    `json:"type"`
+	OrderType      string    `json:"ordertype"`
+	Volume         string    `json:"volume"` // Requested volume
+	Price          string    `json:"price"`  // Requested price
+	TxID           string    `json:"txid"`
+	CreatedAt      time.Time `json:"created_at"`
+	Status         string    `json:"status"`
+	AvgPrice       float64   `json:"avg_price"`       // Volume weighted average fill price
+	ExecutedVolume float64   `json:"executed_volume"` // Sum of fill volumes
+	Fee            float64   `json:"fee"`             // Sum of fill fees
+	PnL            float64   `json:"pnl"`
+	Fills          []Fill    `json:"fills,omitempty"`
+
+	// Events is the status history, only set when looking up a single trade.
+	Events []OrderEvent `json:"events,omitempty"`
+}
+
+// tradeColumns lists the columns scanned by scanTrade, in order.
+const tradeColumns = "id, signal_id, parent_trade_id, pair, type, ordertype, volume, price, txid, created_at, status, avg_price, executed_volume, fee, pnl"
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTrade(row scanner) (Trade, error) {
+	var t Trade
+	var signalID, parentID sql.NullInt64
+	err := row.Scan(&t.ID, &signalID, &parentID, &t.Pair, &t.Type, &t.OrderType, &t.Volume, &t.Price, &t.TxID, &t.CreatedAt, &t.Status, &t.AvgPrice, &t.ExecutedVolume, &t.Fee, &t.PnL)
+	if err != nil {
+		return t, err
+	}
+	t.SignalID = signalID.Int64
+	if parentID.Valid {
+		t.ParentTradeID = &parentID.Int64
+	}
+	return t, nil
+}
+
+func (db *DB) queryTrades(query string, args ...interface{}) ([]Trade, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trades []Trade
+	for rows.Next() {
+		t, err := scanTrade(rows)
+		if err != nil {
+			return nil, err
+		}
+		trades = append(trades, t)
+	}
+	return trades, rows.Err()
+}
+
+func (db *DB) SaveTrade(signalID int64, pair, action, orderType, volume, price, txid string) (int64, error) {
+	return db.insert(`INSERT INTO trades (signal_id, pair, type, ordertype, volume, price, txid)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		nullID(signalID), pair, action, orderType, volume, price, txid)
+}
+
+// SaveCloseTrade records a conditional close order created by Kraken when its parent trade filled.
+func (db *DB) SaveCloseTrade(parent Trade, orderType, volume, price, txid, status string) (int64, error) {
+	return db.insert(`INSERT INTO trades (signal_id, parent_trade_id, pair, type, ordertype, volume, price, txid, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		nullID(parent.SignalID), parent.ID, parent.Pair, oppositeSide(parent.Type), orderType, volume, price, txid, status)
+}
+
+func oppositeSide(side string) string {
+	if side == "buy" {
+		return "sell"
+	}
+	return "buy"
+}
+
+func (db *DB) GetRecentTrades(limit int) ([]Trade, error) {
+	trades, err := db.queryTrades("SELECT "+tradeColumns+" FROM trades ORDER BY created_at DESC LIMIT ?", limit)
+	if err != nil {
+		return nil, err
+	}
+	return trades, db.attachFills(trades)
+}
+
+// GetTradesByStatus returns all trades with a txid whose status is one of statuses.
+func (db *DB) GetTradesByStatus(statuses ...string) ([]Trade, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(statuses)), ",")
+	args := make([]interface{}, len(statuses))
+	for i, s := range statuses {
+		args[i] = s
+	}
+	return db.queryTrades("SELECT "+tradeColumns+" FROM trades WHERE txid != '' AND status IN ("+placeholders+") ORDER BY id", args...)
+}
+
+// GetTradeByTxID returns the trade with the given Kraken transaction ID, or nil if there is none.
+func (db *DB) GetTradeByTxID(txid string) (*Trade, error) {
+	t, err := scanTrade(db.QueryRow("SELECT "+tradeColumns+" FROM trades WHERE txid = ?", txid))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
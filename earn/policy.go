@@ -0,0 +1,201 @@
+// Package earn keeps idle quote balances allocated to a Kraken Earn strategy,
+// deallocating just enough before a webhook order needs the funds and
+// reallocating the idle balance afterwards.
+package earn
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"tvwh2k/database"
+	"tvwh2k/kraken"
+)
+
+// feeBuffer is added on top of the order notional to cover fees and slippage.
+const feeBuffer = 1.01
+
+// Policy moves funds of a single asset between the spot balance and an Earn strategy.
+type Policy struct {
+	client *kraken.Kraken
+	db     *database.DB
+	mu     sync.Mutex // Serializes balance moves of concurrent webhooks.
+
+	StrategyID  string        // Earn strategy idle funds are allocated to.
+	Asset       string        // Balance asset as named by Kraken (e.g. "ZUSD").
+	Quote       string        // Quote currency of the pairs the policy covers (e.g. "USD").
+	Reserve     float64       // Balance kept liquid outside Earn.
+	MinMove     float64       // Smallest amount worth allocating or deallocating.
+	WaitTimeout time.Duration // How long to wait for a deallocation to complete.
+
+	pollInterval time.Duration
+}
+
+// NewPolicy creates a policy for the given strategy and asset. The quote currency
+// defaults to the asset name without Kraken's "Z"/"X" prefix (e.g. "ZUSD" -> "USD").
+func NewPolicy(k *kraken.Kraken, db *database.DB, strategyID, asset string) *Policy {
+	quote := asset
+	if len(asset) == 4 && (asset[0] == 'Z' || asset[0] == 'X') {
+		quote = asset[1:]
+	}
+	return &Policy{
+		client:       k,
+		db:           db,
+		StrategyID:   strategyID,
+		Asset:        asset,
+		Quote:        quote,
+		WaitTimeout:  30 * time.Second,
+		pollInterval: time.Second,
+	}
+}
+
+// Covers reports whether orders on the pair are paid in the policy's quote currency.
+func (p *Policy) Covers(pair string) bool {
+	return strings.HasSuffix(strings.ToUpper(pair), strings.ToUpper(p.Quote))
+}
+
+// Hold makes sure enough of the asset is available for a buy order, deallocating
+// the shortfall from the Earn strategy and waiting until the deallocation has completed.
+// The policy stays locked until release is called with the outcome of the order, so
+// concurrent webhooks cannot allocate the funds back before the order has used them.
+// Release allocates the balance above the reserve again only if funds were deallocated
+// for an order that was placed. Orders the policy does not cover hold nothing.
+func (p *Policy) Hold(signalID int64, order kraken.OrderInput) (release func(placed bool), err error) {
+	if order.Type != "buy" || !p.Covers(order.Pair) {
+		return func(bool) {}, nil
+	}
+
+	p.mu.Lock()
+	moved, err := p.deallocate(signalID, order)
+	return func(placed bool) {
+		defer p.mu.Unlock()
+		if !moved || !placed {
+			return
+		}
+		if err := p.allocate(signalID); err != nil {
+			fmt.Printf("Earn allocation failed: %v\n", err)
+		}
+	}, err
+}
+
+// deallocate moves the shortfall of a buy order out of the Earn strategy and reports
+// whether funds were moved.
+func (p *Policy) deallocate(signalID int64, order kraken.OrderInput) (bool, error) {
+	needed, err := p.notional(order)
+	if err != nil {
+		return false, err
+	}
+	available, err := p.available()
+	if err != nil {
+		return false, err
+	}
+
+	shortfall := needed*feeBuffer - available
+	if shortfall <= 0 {
+		return false, nil
+	}
+	amount := formatAmount(math.Max(shortfall, p.MinMove), math.Ceil)
+
+	err = p.client.EarnDeallocate(p.StrategyID, amount)
+	p.record(signalID, "deallocate", amount, err)
+	if err != nil {
+		return false, fmt.Errorf("earn deallocate %s %s: %w", amount, p.Asset, err)
+	}
+	return true, p.waitDeallocated()
+}
+
+// allocate allocates the available balance above the reserve to the Earn strategy.
+func (p *Policy) allocate(signalID int64) error {
+	available, err := p.available()
+	if err != nil {
+		return err
+	}
+	idle := available - p.Reserve
+	if idle <= 0 || idle < p.MinMove {
+		return nil
+	}
+	amount := formatAmount(idle, math.Floor)
+
+	err = p.client.EarnAllocate(p.StrategyID, amount)
+	p.record(signalID, "allocate", amount, err)
+	if err != nil {
+		return fmt.Errorf("earn allocate %s %s: %w", amount, p.Asset, err)
+	}
+	return nil
+}
+
+// notional estimates the quote amount a buy order needs, using the order price
+// or the last traded price for market orders.
+func (p *Policy) notional(order kraken.OrderInput) (float64, error) {
+	volume, err := strconv.ParseFloat(order.Volume, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid volume %q: %w", order.Volume, err)
+	}
+
+	price, err := strconv.ParseFloat(order.Price, 64)
+	if err != nil || order.OrderType == "market" {
+		price, err = p.client.LastPrice(order.Pair)
+		if err != nil {
+			return 0, fmt.Errorf("price for %s: %w", order.Pair, err)
+		}
+	}
+	return volume * price, nil
+}
+
+func (p *Policy) available() (float64, error) {
+	balances, err := p.client.GetExtendedBalance()
+	if err != nil {
+		return 0, err
+	}
+	return balances[p.Asset].Available(), nil
+}
+
+func (p *Policy) waitDeallocated() error {
+	deadline := time.Now().Add(p.WaitTimeout)
+	for {
+		status, err := p.client.EarnDeallocateStatus(p.StrategyID)
+		if err != nil {
+			return err
+		}
+		if !status.Pending {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("earn deallocation still pending after %s", p.WaitTimeout)
+		}
+		time.Sleep(p.pollInterval)
+	}
+}
+
+// record logs a balance move to the database.
+func (p *Policy) record(signalID int64, direction, amount string, moveErr error) {
+	fmt.Printf("Earn %s %s %s (strategy %s)\n", direction, amount, p.Asset, p.StrategyID)
+	if p.db == nil {
+		return
+	}
+
+	move := database.EarnMove{
+		SignalID:   signalID,
+		StrategyID: p.StrategyID,
+		Asset:      p.Asset,
+		Direction:  direction,
+		Amount:     amount,
+		Status:     "ok",
+	}
+	if moveErr != nil {
+		move.Status = "failed"
+		move.Error = moveErr.Error()
+	}
+	if err := p.db.SaveEarnMove(move); err != nil {
+		fmt.Printf("Failed to save earn move: %v\n", err)
+	}
+}
+
+// formatAmount rounds to 8 decimals, the precision Kraken accepts for most assets.
+// Deallocations round up so the order is covered, allocations round down so
+// they never exceed the available balance.
+func formatAmount(amount float64, round func(float64) float64) string {
+	return strconv.FormatFloat(round(amount*1e8)/1e8, 'f', -1, 64)
+}
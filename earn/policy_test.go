@@ -0,0 +1,90 @@
+package earn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"tvwh2k/database"
+	"tvwh2k/kraken"
+)
+
+func TestPolicyMovesFunds(t *testing.T) {
+	var deallocated, allocated string
+	balance := `{"ZUSD":{"balance":"100","hold_trade":"0"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/0/private/BalanceEx":
+			w.Write([]byte(`{"error":[],"result":` + balance + `}`))
+		case "/0/private/Earn/Deallocate":
+			deallocated = r.PostForm.Get("amount")
+			w.Write([]byte(`{"error":[],"result":true}`))
+		case "/0/private/Earn/DeallocateStatus":
+			w.Write([]byte(`{"error":[],"result":{"pending":false}}`))
+		case "/0/private/Earn/Allocate":
+			allocated = r.PostForm.Get("amount")
+			w.Write([]byte(`{"error":[],"result":true}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	k, err := kraken.NewClient("key", "c2VjcmV0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.SetBaseURL(server.URL)
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	p := NewPolicy(k, db, "ESRFUO3-Q62XD-WIOIL7", "ZUSD")
+	p.Reserve = 50
+
+	// Buying 1 @ 1000 needs 1010 USD including the fee buffer, 100 is available.
+	order := kraken.OrderInput{Pair: "XBTUSD", Type: "buy", OrderType: "limit", Volume: "1", Price: "1000"}
+	release, err := p.Hold(1, order)
+	if err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	if deallocated != "910" {
+		t.Fatalf("deallocated %q, want 910", deallocated)
+	}
+
+	balance = `{"ZUSD":{"balance":"250.5","hold_trade":"0.5"}}`
+	release(true)
+	if allocated != "200" {
+		t.Fatalf("allocated %q, want 200", allocated)
+	}
+
+	moves, err := db.GetRecentEarnMoves(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 2 {
+		t.Fatalf("recorded %d moves, want 2", len(moves))
+	}
+
+	// A failed order and a sell leave the funds where they are.
+	allocated = ""
+	balance = `{"ZUSD":{"balance":"100","hold_trade":"0"}}`
+	release, err = p.Hold(2, order)
+	if err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	balance = `{"ZUSD":{"balance":"1060","hold_trade":"0"}}`
+	release(false)
+	order.Type = "sell"
+	release, err = p.Hold(3, order)
+	if err != nil {
+		t.Fatalf("Hold: %v", err)
+	}
+	release(true)
+	if allocated != "" {
+		t.Fatalf("allocated %q, want nothing", allocated)
+	}
+}
@@ -0,0 +1,85 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"strconv"
+	"time"
+	"tvwh2k/database"
+)
+
+// timeLayout is used for timestamps in the plain trades, fills and gains reports.
+const timeLayout = time.RFC3339
+
+// formatFloat formats amounts with at most 8 decimals, Kraken's finest precision.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1e8)/1e8, 'f', -1, 64)
+}
+
+// writeTrades writes one row per trade created in the period.
+func (e *Exporter) writeTrades(w io.Writer, opts Options) error {
+	trades, err := e.loadTrades(opts)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "signal_id", "parent_trade_id", "created_at", "pair", "type", "ordertype", "volume", "price",
+		"txid", "status", "executed_volume", "avg_price", "fee", "pnl"})
+	for _, t := range trades {
+		if !opts.contains(t.CreatedAt) {
+			continue
+		}
+		parent := ""
+		if t.ParentTradeID != nil {
+			parent = strconv.FormatInt(*t.ParentTradeID, 10)
+		}
+		cw.Write([]string{
+			strconv.FormatInt(t.ID, 10), strconv.FormatInt(t.SignalID, 10), parent, t.CreatedAt.UTC().Format(timeLayout),
+			t.Pair, t.Type, t.OrderType, t.Volume, t.Price, t.TxID, t.Status,
+			formatFloat(t.ExecutedVolume), formatFloat(t.AvgPrice), formatFloat(t.Fee), formatFloat(t.PnL),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeFills writes one row per fill executed in the period.
+func (e *Exporter) writeFills(w io.Writer, opts Options) error {
+	trades, err := e.loadTrades(opts)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"executed_at", "trade_id", "txid", "kraken_trade_id", "pair", "type", "price", "volume", "cost", "fee", "fee_currency"})
+	for _, f := range fillsInPeriod(trades, opts) {
+		t := f.trade
+		cw.Write([]string{
+			f.ExecutedAt.UTC().Format(timeLayout), strconv.FormatInt(t.ID, 10), t.TxID, f.KrakenTradeID, t.Pair, t.Type,
+			formatFloat(f.Price), formatFloat(f.Volume), formatFloat(f.Cost), formatFloat(f.Fee), f.FeeCurrency,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// tradeFill is a fill together with the trade it belongs to.
+type tradeFill struct {
+	database.Fill
+	trade *database.Trade
+}
+
+// fillsInPeriod returns the fills of the trades executed in the period, in trade order.
+func fillsInPeriod(trades []database.Trade, opts Options) []tradeFill {
+	var fills []tradeFill
+	for i := range trades {
+		for _, f := range trades[i].Fills {
+			if opts.contains(f.ExecutedAt) {
+				fills = append(fills, tradeFill{Fill: f, trade: &trades[i]})
+			}
+		}
+	}
+	return fills
+}
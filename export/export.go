@@ -0,0 +1,92 @@
+// Package export writes trades, fills and tax reports as CSV files.
+package export
+
+import (
+	"fmt"
+	"io"
+	"time"
+	"tvwh2k/database"
+	"tvwh2k/kraken"
+)
+
+// Reports lists the available report names.
+var Reports = []string{"trades", "fills", "gains", "koinly", "cointracking"}
+
+// Options selects the period and valuation of a report.
+type Options struct {
+	From   time.Time           // Inclusive, zero for no lower bound
+	To     time.Time           // Exclusive, zero for no upper bound
+	Fiat   string              // Currency of the gains report (e.g. "EUR"); empty keeps the quote currency of each pair
+	Method database.CostMethod // Cost method of the gains report
+}
+
+// Year returns options covering the calendar year in UTC.
+func Year(year int) Options {
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return Options{From: from, To: from.AddDate(1, 0, 0), Method: database.FIFO}
+}
+
+// contains reports whether t falls in the period of the options.
+func (o Options) contains(t time.Time) bool {
+	return (o.From.IsZero() || !t.Before(o.From)) && (o.To.IsZero() || t.Before(o.To))
+}
+
+// Exporter builds reports from the trades table and, when a Kraken client is
+// configured, the Kraken ledger and market data.
+type Exporter struct {
+	db    *database.DB
+	k     *kraken.Kraken
+	rates RateSource
+	pairs map[string]pairAssets
+}
+
+// New creates an exporter. The Kraken client is optional; without it ledger
+// entries are not exported and gains can only be reported in the quote currency.
+func New(db *database.DB, k *kraken.Kraken) *Exporter {
+	e := &Exporter{db: db, k: k}
+	if k != nil {
+		e.rates = NewKrakenRates(k)
+	}
+	return e
+}
+
+// SetRateSource replaces the source of fiat exchange rates.
+func (e *Exporter) SetRateSource(r RateSource) {
+	e.rates = r
+}
+
+// Write writes the named report as CSV to w.
+func (e *Exporter) Write(w io.Writer, report string, opts Options) error {
+	switch report {
+	case "trades":
+		return e.writeTrades(w, opts)
+	case "fills":
+		return e.writeFills(w, opts)
+	case "gains":
+		return e.writeGains(w, opts)
+	case "koinly":
+		return e.writeTransactions(w, opts, koinlyLayout)
+	case "cointracking":
+		return e.writeTransactions(w, opts, coinTrackingLayout)
+	default:
+		return fmt.Errorf("unknown report %q (want one of %v)", report, Reports)
+	}
+}
+
+// loadTrades returns all trades created before the end of the period, oldest first, with their fills.
+// Trades created earlier are included because their fills may fall into the period.
+func (e *Exporter) loadTrades(opts Options) ([]database.Trade, error) {
+	f := database.Filter{To: opts.To, Ascending: true, Limit: 500}
+	var all []database.Trade
+	for {
+		trades, next, err := e.db.QueryTrades(f)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, trades...)
+		if next == 0 {
+			return all, nil
+		}
+		f.Cursor = next
+	}
+}
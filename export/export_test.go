@@ -0,0 +1,146 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path/filepath"
+	"testing"
+	"time"
+	"tvwh2k/database"
+)
+
+// fixedRates converts every currency at the same rate.
+type fixedRates float64
+
+func (r fixedRates) Rate(from, to string, at time.Time) (float64, error) {
+	return float64(r), nil
+}
+
+func newTestExporter(t *testing.T) *Exporter {
+	t.Helper()
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	signalID, _ := db.SaveSignal("XBTUSD", "buy", "trend", nil)
+	fills := []struct {
+		side  string
+		price float64
+		at    time.Time
+	}{
+		{"buy", 100, time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{"sell", 150, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, f := range fills {
+		tradeID, err := db.SaveTrade(signalID, "XBTUSD", f.side, "market", "1", "", "OTX"+f.side)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.SaveFill(database.Fill{TradeID: tradeID, KrakenTradeID: "T" + f.side, Price: f.price, Volume: 1,
+			Cost: f.price, Fee: 1, FeeCurrency: "ZUSD", ExecutedAt: f.at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return New(db, nil)
+}
+
+func readCSV(t *testing.T, e *Exporter, report string, opts Options) [][]string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := e.Write(&buf, report, opts); err != nil {
+		t.Fatalf("Write(%s): %v", report, err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestGainsReport(t *testing.T) {
+	e := newTestExporter(t)
+
+	rows := readCSV(t, e, "gains", Year(2024))
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want header and one disposal: %v", len(rows), rows)
+	}
+	// Bought at 100 + 1 fee, sold at 150 - 1 fee.
+	want := []string{"BTC", "1", "2023-06-01T12:00:00Z", "2024-03-01T12:00:00Z", "274", "101", "149", "48", "USD", "XBTUSD", "trend"}
+	for i, w := range want {
+		if rows[1][i] != w {
+			t.Errorf("column %s = %q, want %q", rows[0][i], rows[1][i], w)
+		}
+	}
+
+	if rows := readCSV(t, e, "gains", Year(2023)); len(rows) != 1 {
+		t.Errorf("2023 has %d disposals, want none", len(rows)-1)
+	}
+
+	// Converting to another currency needs exchange rates.
+	opts := Year(2024)
+	opts.Fiat = "EUR"
+	var buf bytes.Buffer
+	if err := e.Write(&buf, "gains", opts); err == nil {
+		t.Fatal("expected an error converting without a rate source")
+	}
+	e.SetRateSource(fixedRates(0.5))
+	rows = readCSV(t, e, "gains", opts)
+	if rows[1][5] != "50.5" || rows[1][6] != "74.5" || rows[1][8] != "EUR" {
+		t.Errorf("got cost basis %s, proceeds %s %s, want 50.5, 74.5 EUR", rows[1][5], rows[1][6], rows[1][8])
+	}
+}
+
+func TestGainsReportShortDisposedWhenOpened(t *testing.T) {
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	defer db.Close()
+
+	// Sold short in 2023 and bought back in 2024.
+	signalID, _ := db.SaveSignal("XBTUSD", "sell", "trend", nil)
+	for _, f := range []struct {
+		side string
+		at   time.Time
+	}{
+		{"sell", time.Date(2023, 12, 1, 12, 0, 0, 0, time.UTC)},
+		{"buy", time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC)},
+	} {
+		tradeID, err := db.SaveTrade(signalID, "XBTUSD", f.side, "market", "1", "", "OTX"+f.side)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.SaveFill(database.Fill{TradeID: tradeID, KrakenTradeID: "T" + f.side, Price: 100, Volume: 1,
+			Cost: 100, FeeCurrency: "ZUSD", ExecutedAt: f.at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	e := New(db, nil)
+
+	rows := readCSV(t, e, "gains", Year(2023))
+	if len(rows) != 2 || rows[1][3] != "2023-12-01T12:00:00Z" {
+		t.Fatalf("2023 rows = %v, want the short disposed on 2023-12-01", rows)
+	}
+	if rows := readCSV(t, e, "gains", Year(2024)); len(rows) != 1 {
+		t.Errorf("2024 has %d disposals, want none", len(rows)-1)
+	}
+}
+
+func TestKoinlyLayout(t *testing.T) {
+	e := newTestExporter(t)
+
+	rows := readCSV(t, e, "koinly", Options{})
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want header and two trades", len(rows))
+	}
+	buy := rows[1]
+	if buy[0] != "2023-06-01 12:00 UTC" || buy[1] != "100" || buy[2] != "USD" || buy[3] != "1" || buy[4] != "BTC" || buy[5] != "1" || buy[6] != "USD" {
+		t.Errorf("unexpected buy row %v", buy)
+	}
+	sell := rows[2]
+	if sell[1] != "1" || sell[2] != "BTC" || sell[3] != "150" || sell[4] != "USD" {
+		t.Errorf("unexpected sell row %v", sell)
+	}
+}
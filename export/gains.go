@@ -0,0 +1,71 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"tvwh2k/database"
+)
+
+// writeGains writes one row per disposal realized in the period with its acquisition
+// and disposal date, cost basis and proceeds. Amounts are converted to opts.Fiat with
+// the rate on the acquisition date (cost basis) and the disposal date (proceeds).
+func (e *Exporter) writeGains(w io.Writer, opts Options) error {
+	method := opts.Method
+	if method == "" {
+		method = database.FIFO
+	}
+	// Lots are matched over the full history so the cost basis of earlier buys is known.
+	report, err := e.db.ComputePnL(method)
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"asset", "amount", "date_acquired", "date_disposed", "holding_days", "cost_basis", "proceeds", "gain",
+		"currency", "pair", "strategy", "trade_id"})
+	for _, r := range report.Realized {
+		// A short is disposed of when opened and acquired when bought back.
+		acquired, disposed := r.Opened, r.Closed
+		if r.Side == "sell" {
+			acquired, disposed = r.Closed, r.Opened
+		}
+		if !opts.contains(disposed) {
+			continue
+		}
+		assets, err := e.pairAssets(r.Pair)
+		if err != nil {
+			return err
+		}
+
+		currency := assets.Quote
+		costBasis, proceeds := r.CostBasis, r.Proceeds
+		if opts.Fiat != "" && opts.Fiat != assets.Quote {
+			if e.rates == nil {
+				return fmt.Errorf("converting %s to %s requires Kraken exchange rates", assets.Quote, opts.Fiat)
+			}
+			buyRate, err := e.rates.Rate(assets.Quote, opts.Fiat, acquired)
+			if err != nil {
+				return err
+			}
+			sellRate, err := e.rates.Rate(assets.Quote, opts.Fiat, disposed)
+			if err != nil {
+				return err
+			}
+			costBasis *= buyRate
+			proceeds *= sellRate
+			currency = opts.Fiat
+		}
+
+		cw.Write([]string{
+			displayCode(assets.Base), formatFloat(r.Volume),
+			acquired.UTC().Format(timeLayout), disposed.UTC().Format(timeLayout),
+			strconv.Itoa(int(r.Closed.Sub(r.Opened).Hours() / 24)),
+			formatFloat(costBasis), formatFloat(proceeds), formatFloat(proceeds - costBasis),
+			displayCode(currency), r.Pair, r.Strategy, strconv.FormatInt(r.TradeID, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
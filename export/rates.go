@@ -0,0 +1,120 @@
+package export
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"tvwh2k/kraken"
+)
+
+// RateSource provides exchange rates between two currencies at a point in time.
+type RateSource interface {
+	Rate(from, to string, at time.Time) (float64, error)
+}
+
+// KrakenRates uses the daily close of Kraken's OHLC data as exchange rate.
+// Kraken only serves the last 720 daily candles, so rates reach back about two years.
+type KrakenRates struct {
+	k       *kraken.Kraken
+	candles map[string][]kraken.Candle
+	errs    map[string]error
+}
+
+func NewKrakenRates(k *kraken.Kraken) *KrakenRates {
+	return &KrakenRates{
+		k:       k,
+		candles: make(map[string][]kraken.Candle),
+		errs:    make(map[string]error),
+	}
+}
+
+// Rate returns the amount of to per unit of from, using the pair from+to or its inverse.
+func (r *KrakenRates) Rate(from, to string, at time.Time) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	if rate, err := r.close(from+to, at); err == nil {
+		return rate, nil
+	}
+	rate, err := r.close(to+from, at)
+	if err != nil {
+		return 0, fmt.Errorf("no %s/%s rate: %w", from, to, err)
+	}
+	return 1 / rate, nil
+}
+
+// close returns the close of the daily candle of pair containing at.
+func (r *KrakenRates) close(pair string, at time.Time) (float64, error) {
+	if err := r.errs[pair]; err != nil {
+		return 0, err
+	}
+	candles, ok := r.candles[pair]
+	if !ok {
+		var err error
+		if candles, err = r.k.OHLC(pair, 1440, time.Time{}); err != nil {
+			r.errs[pair] = err
+			return 0, err
+		}
+		r.candles[pair] = candles
+	}
+
+	for i := len(candles) - 1; i >= 0; i-- {
+		if !candles[i].Time.After(at) {
+			return candles[i].Close, nil
+		}
+	}
+	return 0, fmt.Errorf("no %s candle on %s", pair, at.UTC().Format("2006-01-02"))
+}
+
+// displayCode converts a Kraken asset code to the ticker used by tax tools.
+func displayCode(code string) string {
+	switch code {
+	case "XBT":
+		return "BTC"
+	case "XDG":
+		return "DOGE"
+	}
+	return code
+}
+
+// pairAssets holds the base and quote asset codes of a pair.
+type pairAssets struct {
+	Base  string
+	Quote string
+}
+
+// pairAssets resolves the base and quote of a pair name from Kraken's asset pairs,
+// falling back on splitting "XBT/USD", "XBTUSD" and "XXBTZUSD" style names.
+func (e *Exporter) pairAssets(pair string) (pairAssets, error) {
+	if e.pairs == nil {
+		e.pairs = make(map[string]pairAssets)
+		if e.k != nil {
+			info, err := e.k.AssetPairs()
+			if err != nil {
+				return pairAssets{}, fmt.Errorf("failed to load asset pairs: %w", err)
+			}
+			for name, p := range info {
+				a := pairAssets{Base: kraken.AssetCode(p.Base), Quote: kraken.AssetCode(p.Quote)}
+				e.pairs[name] = a
+				e.pairs[p.AltName] = a
+				if p.WSName != "" {
+					e.pairs[p.WSName] = a
+				}
+			}
+		}
+	}
+	if a, ok := e.pairs[pair]; ok {
+		return a, nil
+	}
+
+	if base, quote, ok := strings.Cut(pair, "/"); ok {
+		return pairAssets{Base: kraken.AssetCode(base), Quote: kraken.AssetCode(quote)}, nil
+	}
+	switch len(pair) {
+	case 6:
+		return pairAssets{Base: pair[:3], Quote: pair[3:]}, nil
+	case 8:
+		return pairAssets{Base: kraken.AssetCode(pair[:4]), Quote: kraken.AssetCode(pair[4:])}, nil
+	}
+	return pairAssets{}, fmt.Errorf("unknown pair %q", pair)
+}
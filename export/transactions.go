@@ -0,0 +1,194 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+	"tvwh2k/kraken"
+)
+
+// transaction is a single balance change in the generic form used by tax tools.
+type transaction struct {
+	Time             time.Time
+	Kind             string // trade, deposit, withdrawal or reward
+	SentAmount       float64
+	SentCurrency     string
+	ReceivedAmount   float64
+	ReceivedCurrency string
+	FeeAmount        float64
+	FeeCurrency      string
+	ID               string
+	Description      string
+}
+
+// layout is a CSV layout of a tax tool.
+type layout struct {
+	header []string
+	row    func(tx transaction) []string
+}
+
+// amount formats an amount, leaving it empty if there is no currency.
+func amount(a float64, currency string) (string, string) {
+	if currency == "" {
+		return "", ""
+	}
+	return formatFloat(a), displayCode(currency)
+}
+
+// koinlyLayout is Koinly's universal CSV format.
+var koinlyLayout = layout{
+	header: []string{"Date", "Sent Amount", "Sent Currency", "Received Amount", "Received Currency", "Fee Amount", "Fee Currency",
+		"Net Worth Amount", "Net Worth Currency", "Label", "Description", "TxHash"},
+	row: func(tx transaction) []string {
+		sent, sentCur := amount(tx.SentAmount, tx.SentCurrency)
+		received, receivedCur := amount(tx.ReceivedAmount, tx.ReceivedCurrency)
+		fee, feeCur := amount(tx.FeeAmount, tx.FeeCurrency)
+		label := ""
+		if tx.Kind == "reward" {
+			label = "reward"
+		}
+		return []string{tx.Time.UTC().Format("2006-01-02 15:04 UTC"), sent, sentCur, received, receivedCur, fee, feeCur,
+			"", "", label, tx.Description, tx.ID}
+	},
+}
+
+// coinTrackingLayout is CoinTracking's CSV import format.
+var coinTrackingLayout = layout{
+	header: []string{"Type", "Buy Amount", "Buy Currency", "Sell Amount", "Sell Currency", "Fee", "Fee Currency",
+		"Exchange", "Trade-Group", "Comment", "Date", "Tx-ID"},
+	row: func(tx transaction) []string {
+		buy, buyCur := amount(tx.ReceivedAmount, tx.ReceivedCurrency)
+		sell, sellCur := amount(tx.SentAmount, tx.SentCurrency)
+		fee, feeCur := amount(tx.FeeAmount, tx.FeeCurrency)
+		kind := map[string]string{"trade": "Trade", "deposit": "Deposit", "withdrawal": "Withdrawal", "reward": "Staking"}[tx.Kind]
+		return []string{kind, buy, buyCur, sell, sellCur, fee, feeCur,
+			"Kraken", "", tx.Description, tx.Time.UTC().Format("02.01.2006 15:04:05"), tx.ID}
+	},
+}
+
+// writeTransactions writes the trades and ledger entries of the period in a tax tool layout.
+func (e *Exporter) writeTransactions(w io.Writer, opts Options, l layout) error {
+	txs, err := e.tradeTransactions(opts)
+	if err != nil {
+		return err
+	}
+	if e.k != nil {
+		ledger, err := e.ledgerTransactions(opts)
+		if err != nil {
+			return err
+		}
+		txs = append(txs, ledger...)
+	}
+	sort.SliceStable(txs, func(i, j int) bool { return txs[i].Time.Before(txs[j].Time) })
+
+	cw := csv.NewWriter(w)
+	cw.Write(l.header)
+	for _, tx := range txs {
+		cw.Write(l.row(tx))
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// tradeTransactions converts the fills of the period to transactions. Closed trades
+// recorded before fills were tracked are exported with their requested volume and price.
+func (e *Exporter) tradeTransactions(opts Options) ([]transaction, error) {
+	trades, err := e.loadTrades(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var txs []transaction
+	add := func(pair, side string, volume, cost, fee float64, feeCurrency string, at time.Time, id, description string) error {
+		assets, err := e.pairAssets(pair)
+		if err != nil {
+			return err
+		}
+		tx := transaction{Time: at, Kind: "trade", FeeAmount: fee, FeeCurrency: feeCurrency, ID: id, Description: description}
+		if fee == 0 {
+			tx.FeeCurrency = ""
+		}
+		if side == "buy" {
+			tx.SentAmount, tx.SentCurrency = cost, assets.Quote
+			tx.ReceivedAmount, tx.ReceivedCurrency = volume, assets.Base
+		} else {
+			tx.SentAmount, tx.SentCurrency = volume, assets.Base
+			tx.ReceivedAmount, tx.ReceivedCurrency = cost, assets.Quote
+		}
+		txs = append(txs, tx)
+		return nil
+	}
+
+	for _, t := range trades {
+		description := fmt.Sprintf("%s %s order %s", t.Type, t.Pair, t.TxID)
+		if len(t.Fills) == 0 && t.Status == "closed" && opts.contains(t.CreatedAt) {
+			volume, errV := strconv.ParseFloat(t.Volume, 64)
+			price, errP := strconv.ParseFloat(t.Price, 64)
+			if errV != nil || errP != nil {
+				continue // e.g. market orders without a known price
+			}
+			if err := add(t.Pair, t.Type, volume, volume*price, 0, "", t.CreatedAt, t.TxID, description); err != nil {
+				return nil, err
+			}
+		}
+		for _, f := range t.Fills {
+			if !opts.contains(f.ExecutedAt) {
+				continue
+			}
+			if err := add(t.Pair, t.Type, f.Volume, f.Cost, f.Fee, kraken.AssetCode(f.FeeCurrency), f.ExecutedAt, f.KrakenTradeID, description); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return txs, nil
+}
+
+// ledgerTransactions converts the deposits, withdrawals and rewards in the Kraken ledger
+// to transactions. Trades are taken from the trades table and internal moves are skipped.
+func (e *Exporter) ledgerTransactions(opts Options) ([]transaction, error) {
+	in := kraken.LedgersInput{End: opts.To}
+	if !opts.From.IsZero() {
+		in.Start = opts.From.Add(-time.Second) // Start is exclusive
+	}
+	entries, err := e.k.AllLedgers(in)
+	if err != nil {
+		return nil, err
+	}
+
+	var txs []transaction
+	for id, entry := range entries {
+		at := kraken.Timestamp(entry.Time)
+		if !opts.contains(at) {
+			continue
+		}
+		amount, err := strconv.ParseFloat(entry.Amount, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ledger entry %s: invalid amount %q", id, entry.Amount)
+		}
+		fee, _ := strconv.ParseFloat(entry.Fee, 64)
+		asset := kraken.AssetCode(entry.Asset)
+
+		tx := transaction{Time: at, ID: entry.RefID, Description: fmt.Sprintf("Kraken %s %s", entry.Type, id)}
+		if fee != 0 {
+			tx.FeeAmount, tx.FeeCurrency = fee, asset
+		}
+		switch {
+		case entry.Type == "deposit":
+			tx.Kind = "deposit"
+			tx.ReceivedAmount, tx.ReceivedCurrency = amount, asset
+		case entry.Type == "withdrawal":
+			tx.Kind = "withdrawal"
+			tx.SentAmount, tx.SentCurrency = -amount, asset
+		case (entry.Type == "staking" || entry.Type == "earn" && entry.SubType == "reward") && amount > 0:
+			tx.Kind = "reward"
+			tx.ReceivedAmount, tx.ReceivedCurrency = amount, asset
+		default:
+			continue
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
@@ -2,4 +2,13 @@ module tvwh2k
 
 go 1.23.2
 
-require github.com/mattn/go-sqlite3 v1.14.33 // indirect
+require github.com/mattn/go-sqlite3 v1.14.33
+
+require github.com/lib/pq v1.10.9
+
+require golang.org/x/image v0.24.0
+
+require (
+	github.com/BurntSushi/toml v1.4.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"tvwh2k/api"
+	"tvwh2k/database"
+	"tvwh2k/notify"
+)
+
+// Steps recorded in the audit trail of a signal.
+const (
+	stepValidation    = "validation"
+	stepRisk          = "risk"
+	stepOrderRequest  = "order_request"
+	stepOrderResponse = "order_response"
+	stepNotification  = "notification"
+	stepConfirmation  = "confirmation"
+)
+
+// Final statuses of a signal.
+const (
+	signalNotified  = "notified"  // No order fields, only notified
+	signalRejected  = "rejected"  // Failed validation
+	signalRefused   = "refused"   // Refused by a risk check
+	signalSkipped   = "skipped"   // No client for the requested exchange
+	signalValidated = "validated" // Test mode, order validated but not placed
+	signalPlaced    = "placed"
+	signalFailed    = "failed"
+	signalPending   = "pending"  // Waiting for confirmation in Telegram
+	signalDeclined  = "declined" // Rejected in Telegram
+	signalExpired   = "expired"  // Not confirmed in time
+)
+
+// recordStep appends a step to the audit trail of the signal. Failures are only logged.
+func (h *WebhookHandler) recordStep(signalID int64, step, status string, detail interface{}) {
+	if h.db == nil || signalID == 0 {
+		return
+	}
+	if err := h.db.SaveSignalStep(signalID, step, status, detail); err != nil {
+		fmt.Printf("Failed to save %s step for signal %d: %v\n", step, signalID, err)
+	}
+}
+
+func (h *WebhookHandler) setSignalStatus(signalID int64, status string) {
+	if h.db == nil || signalID == 0 {
+		return
+	}
+	if err := h.db.UpdateSignalStatus(signalID, status); err != nil {
+		fmt.Printf("Failed to update status of signal %d: %v\n", signalID, err)
+	}
+}
+
+// notify sends an event to the notification channels and records each delivery with the event's signal.
+func (h *WebhookHandler) notify(e notify.Event) {
+	if h.notifier == nil {
+		return
+	}
+	for _, d := range h.notifier.Send(context.Background(), e) {
+		detail := map[string]string{"channel": d.Channel, "event": string(e.Type), "message": e.Body()}
+		if d.Err != nil {
+			detail["error"] = d.Err.Error()
+			h.recordStep(e.SignalID, stepNotification, "error", detail)
+			continue
+		}
+		h.recordStep(e.SignalID, stepNotification, "ok", detail)
+	}
+}
+
+// resultEvent returns the notification event and severity for a final signal status.
+func resultEvent(status string) (notify.EventType, notify.Severity) {
+	switch status {
+	case signalPlaced, signalValidated:
+		return notify.EventOrderPlaced, notify.Info
+	case signalSkipped:
+		return notify.EventOrderSkipped, notify.Info
+	case signalRefused:
+		return notify.EventRiskBreach, notify.Critical
+	default:
+		return notify.EventOrderFailed, notify.Warning
+	}
+}
+
+// SignalDetail is the full processing record of a signal.
+type SignalDetail struct {
+	Signal *database.Signal      `json:"signal"`
+	Steps  []database.SignalStep `json:"steps"`
+	Trades []database.Trade      `json:"trades"`
+
+	PendingOrder *database.PendingOrder `json:"pending_order,omitempty"` // Set for signals that needed confirmation
+}
+
+// HandleGetSignal returns a signal with its audit trail and the trades it created.
+func (h *WebhookHandler) HandleGetSignal(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		api.WriteError(w, r, http.StatusServiceUnavailable, "Database not initialized")
+		return
+	}
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		api.WriteError(w, r, http.StatusBadRequest, "Invalid signal id")
+		return
+	}
+
+	signal, err := h.db.GetSignal(id)
+	if err != nil {
+		api.WriteInternalError(w, r, "Failed to fetch signal", err)
+		return
+	}
+	if signal == nil {
+		api.WriteError(w, r, http.StatusNotFound, "Signal not found")
+		return
+	}
+
+	detail := SignalDetail{Signal: signal}
+	if detail.Steps, err = h.db.GetSignalSteps(id); err != nil {
+		api.WriteInternalError(w, r, "Failed to fetch signal steps", err)
+		return
+	}
+	if detail.Trades, err = h.db.GetTradesBySignal(id); err != nil {
+		api.WriteInternalError(w, r, "Failed to fetch trades", err)
+		return
+	}
+	if detail.PendingOrder, err = h.db.GetPendingOrderBySignal(id); err != nil {
+		api.WriteInternalError(w, r, "Failed to fetch pending order", err)
+		return
+	}
+	if detail.Trades == nil {
+		detail.Trades = []database.Trade{}
+	}
+
+	api.WriteJSON(w, http.StatusOK, detail)
+}
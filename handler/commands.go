@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"tvwh2k/kraken"
+	"tvwh2k/telegram"
+)
+
+// RegisterCommands adds the trading commands to the Telegram bot.
+func (h *WebhookHandler) RegisterCommands(b *telegram.Bot) {
+	b.Handle("status", "Show whether trading is paused and what is configured", h.cmdStatus)
+	b.Handle("balance", "Show the Kraken balances", h.cmdBalance)
+	b.Handle("positions", "Show open positions", h.cmdPositions)
+	b.Handle("orders", "Show open Kraken orders", h.cmdOrders)
+	b.Handle("pnl", "Show realized PnL per strategy", h.cmdPnL)
+	b.Handle("cancel", "Cancel an order: /cancel <txid>", h.cmdCancel)
+	b.Handle("closeall", "Close all open margin positions at market", h.cmdCloseAll)
+	b.Handle("pause", "Stop placing orders for incoming signals", h.cmdPause)
+	b.Handle("resume", "Place orders for incoming signals again", h.cmdResume)
+	h.registerConfirmation(b)
+}
+
+// Paused reports whether order placement is paused.
+func (h *WebhookHandler) Paused() bool {
+	return h.paused.Load()
+}
+
+// SetPaused pauses or resumes order placement. Signals are still recorded and notified while paused.
+func (h *WebhookHandler) SetPaused(paused bool) {
+	h.paused.Store(paused)
+}
+
+// errNoKraken is returned by commands that need the Kraken spot client.
+var errNoKraken = fmt.Errorf("Kraken client not initialized")
+
+func (h *WebhookHandler) cmdStatus(args []string) (string, error) {
+	var b strings.Builder
+	if h.Paused() {
+		b.WriteString("⏸ Trading paused")
+	} else {
+		b.WriteString("▶️ Trading active")
+	}
+	if h.TestMode() {
+		b.WriteString(" (test mode)")
+	}
+	fmt.Fprintf(&b, "\nKraken spot: %s", enabled(h.krakenClient != nil))
+	fmt.Fprintf(&b, "\nKraken Futures: %s", enabled(h.futuresClient != nil))
+	fmt.Fprintf(&b, "\nEarn policy: %s", enabled(h.earnPolicy != nil))
+
+	if h.db != nil {
+		if signals, err := h.db.GetRecentSignals(1); err == nil && len(signals) > 0 {
+			s := signals[0]
+			fmt.Fprintf(&b, "\nLast signal: #%d %s %s (%s) at %s", s.ID, s.Type, s.Pair, s.Status, s.ReceivedAt.UTC().Format("2006-01-02 15:04 UTC"))
+		}
+		if trades, err := h.db.GetTradesByStatus("pending", "open", "partial"); err == nil {
+			fmt.Fprintf(&b, "\nOpen trades: %d", len(trades))
+		}
+	}
+	if h.krakenClient != nil {
+		if tb, err := h.krakenClient.GetTradeBalance(""); err == nil && tb.MarginLevel != "" {
+			fmt.Fprintf(&b, "\nMargin level: %s%%", tb.MarginLevel)
+		}
+	}
+	return b.String(), nil
+}
+
+func enabled(ok bool) string {
+	if ok {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+func (h *WebhookHandler) cmdBalance(args []string) (string, error) {
+	if h.krakenClient == nil {
+		return "", errNoKraken
+	}
+	balances, err := h.krakenClient.GetBalance()
+	if err != nil {
+		return "", err
+	}
+
+	assets := make([]string, 0, len(*balances))
+	for asset, amount := range *balances {
+		if v, err := strconv.ParseFloat(amount, 64); err == nil && v != 0 {
+			assets = append(assets, asset)
+		}
+	}
+	if len(assets) == 0 {
+		return "No balances.", nil
+	}
+	sort.Strings(assets)
+
+	var b strings.Builder
+	b.WriteString("💰 Balances")
+	for _, asset := range assets {
+		fmt.Fprintf(&b, "\n%s: %s", asset, (*balances)[asset])
+	}
+	return b.String(), nil
+}
+
+func (h *WebhookHandler) cmdPositions(args []string) (string, error) {
+	var b strings.Builder
+
+	if h.db != nil {
+		report, err := h.db.ComputePnL(h.pnlMethod)
+		if err != nil {
+			return "", err
+		}
+		for _, p := range report.Positions {
+			if b.Len() == 0 {
+				b.WriteString("📈 Positions")
+			}
+			fmt.Fprintf(&b, "\n%s %s %g @ %.2f", sideName(p.Side), p.Pair, p.Volume, p.AvgCost)
+			if p.Strategy != "" {
+				fmt.Fprintf(&b, " (%s)", p.Strategy)
+			}
+			if p.Price != 0 {
+				fmt.Fprintf(&b, ", now %.2f (%+.2f)", p.Price, p.UnrealizedPnL)
+			}
+		}
+	}
+
+	if h.krakenClient != nil {
+		positions, err := h.krakenClient.OpenPositions(nil, true)
+		if err != nil {
+			return "", err
+		}
+		if len(positions) > 0 {
+			b.WriteString("\n\n⚖️ Margin positions")
+			for id, p := range positions {
+				leverage, err := p.Leverage()
+				if err != nil {
+					leverage = "?"
+				}
+				fmt.Fprintf(&b, "\n%s %s %s (%sx), net %s [%s]", sideName(p.Type), p.Pair, p.Vol, leverage, p.Net, id)
+			}
+		}
+	}
+
+	if b.Len() == 0 {
+		return "No open positions.", nil
+	}
+	return strings.TrimLeft(b.String(), "\n"), nil
+}
+
+func sideName(side string) string {
+	if side == "sell" {
+		return "Short"
+	}
+	return "Long"
+}
+
+func (h *WebhookHandler) cmdOrders(args []string) (string, error) {
+	if h.krakenClient == nil {
+		return "", errNoKraken
+	}
+	orders, err := h.krakenClient.OpenOrders(false)
+	if err != nil {
+		return "", err
+	}
+	if len(orders) == 0 {
+		return "No open orders.", nil
+	}
+
+	txids := make([]string, 0, len(orders))
+	for txid := range orders {
+		txids = append(txids, txid)
+	}
+	sort.Strings(txids)
+
+	var b strings.Builder
+	b.WriteString("📋 Open orders")
+	for _, txid := range txids {
+		o := orders[txid]
+		fmt.Fprintf(&b, "\n%s: %s", txid, o.Descr.Order)
+		if o.VolExec != "" && o.VolExec != "0" && o.VolExec != "0.00000000" {
+			fmt.Fprintf(&b, " (filled %s)", o.VolExec)
+		}
+	}
+	return b.String(), nil
+}
+
+func (h *WebhookHandler) cmdPnL(args []string) (string, error) {
+	if h.db == nil {
+		return "", fmt.Errorf("database not initialized")
+	}
+	report, err := h.db.ComputePnL(h.pnlMethod)
+	if err != nil {
+		return "", err
+	}
+	buckets, err := report.Aggregate("strategy")
+	if err != nil {
+		return "", err
+	}
+	if len(buckets) == 0 {
+		return "No realized PnL yet.", nil
+	}
+
+	var b strings.Builder
+	var total, fees float64
+	for _, bucket := range buckets {
+		name := bucket.Key
+		if name == "" {
+			name = "(no strategy)"
+		}
+		fmt.Fprintf(&b, "\n%s: %+.2f (%d/%d wins)", name, bucket.PnL, bucket.Wins, bucket.Closes)
+		total += bucket.PnL
+		fees += bucket.Fees
+	}
+	return fmt.Sprintf("💵 Realized PnL (%s): %+.2f, fees %.2f%s", report.Method, total, fees, b.String()), nil
+}
+
+func (h *WebhookHandler) cmdCancel(args []string) (string, error) {
+	if h.krakenClient == nil {
+		return "", errNoKraken
+	}
+	if len(args) != 1 {
+		return "Usage: /cancel <txid>", nil
+	}
+	resp, err := h.krakenClient.CancelOrder(args[0])
+	if err != nil {
+		return "", err
+	}
+	if resp.Pending {
+		return fmt.Sprintf("⏳ Cancellation of %s pending.", args[0]), nil
+	}
+	return fmt.Sprintf("✅ Cancelled %d order(s).", resp.Count), nil
+}
+
+func (h *WebhookHandler) cmdCloseAll(args []string) (string, error) {
+	if h.krakenClient == nil {
+		return "", errNoKraken
+	}
+	if !h.beginOrder() {
+		return "", errShuttingDown
+	}
+	defer h.inflight.Done()
+	positions, err := h.krakenClient.OpenPositions(nil, false)
+	if err != nil {
+		return "", err
+	}
+	if len(positions) == 0 {
+		return "No open margin positions.", nil
+	}
+
+	validate := h.TestMode()
+	var b strings.Builder
+	b.WriteString("Closing all margin positions")
+	if validate {
+		b.WriteString(" (test mode, validating only)")
+	}
+	for id, p := range positions {
+		order, err := p.CloseOrder()
+		if err == nil {
+			order.Validate = validate
+			var resp *kraken.AddOrderResponse
+			if resp, err = h.krakenClient.AddOrder(order); err == nil {
+				fmt.Fprintf(&b, "\n✅ %s: %s", id, resp.Description.Order)
+				continue
+			}
+		}
+		fmt.Fprintf(&b, "\n❌ %s: %v", id, err)
+	}
+	return b.String(), nil
+}
+
+func (h *WebhookHandler) cmdPause(args []string) (string, error) {
+	h.SetPaused(true)
+	return "⏸ Trading paused. Signals are still recorded but no orders are placed. Use /resume to continue.", nil
+}
+
+func (h *WebhookHandler) cmdResume(args []string) (string, error) {
+	h.SetPaused(false)
+	return "▶️ Trading resumed.", nil
+}
@@ -0,0 +1,334 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"tvwh2k/database"
+	"tvwh2k/telegram"
+)
+
+// defaultConfirmTimeout is how long a pending order waits for a decision by default.
+const defaultConfirmTimeout = 5 * time.Minute
+
+// SetConfirmation requires manual confirmation in the Telegram chat for the orders of the
+// given strategies. Pending orders expire after timeout (5 minutes if zero). The buttons are
+// handled by the bot passed to RegisterCommands. It may be called again while webhooks are
+// handled; orders already pending keep their expiry.
+func (h *WebhookHandler) SetConfirmation(chatID int64, strategies []string, timeout time.Duration) {
+	confirm := make(map[string]bool)
+	for _, s := range strategies {
+		if s = strings.TrimSpace(s); s != "" {
+			confirm[s] = true
+		}
+	}
+	if timeout <= 0 {
+		timeout = defaultConfirmTimeout
+	}
+
+	h.confirmMu.Lock()
+	defer h.confirmMu.Unlock()
+	h.confirmChatID = chatID
+	h.confirmStrategies = confirm
+	h.confirmTimeout = timeout
+}
+
+// needsConfirmation reports whether the orders of strategy wait for confirmation.
+func (h *WebhookHandler) needsConfirmation(strategy string) bool {
+	h.confirmMu.RLock()
+	defer h.confirmMu.RUnlock()
+	return h.confirmStrategies[strategy]
+}
+
+// registerConfirmation adds the confirmation buttons and the /modify command to the bot.
+func (h *WebhookHandler) registerConfirmation(b *telegram.Bot) {
+	h.bot = b
+	b.HandleCallback("approve", h.onApprove)
+	b.HandleCallback("reject", h.onReject)
+	b.HandleCallback("modify", h.onModify)
+	b.Handle("modify", "Change a pending order: /modify <id> volume=<v> price=<p> price2=<p>", h.cmdModify)
+}
+
+// requestConfirmation stores the order as pending and asks for a decision in Telegram.
+func (h *WebhookHandler) requestConfirmation(signalID int64, req *WebhookRequest) orderResult {
+	h.confirmMu.RLock()
+	chatID, timeout := h.confirmChatID, h.confirmTimeout
+	h.confirmMu.RUnlock()
+	if h.bot == nil || h.db == nil || signalID == 0 || chatID == 0 {
+		fmt.Println("Confirmation required but the Telegram bot or database is not available, skipping order.")
+		h.recordStep(signalID, stepConfirmation, "skipped", map[string]string{"reason": "telegram bot or database not available"})
+		return orderResult{Status: signalSkipped, Message: "❌ Order needs confirmation but the Telegram bot is not available, order skipped."}
+	}
+
+	pending := *req
+	pending.Token = ""
+	expiresAt := time.Now().Add(timeout)
+	id, err := h.db.SavePendingOrder(signalID, pending, expiresAt)
+	if err != nil {
+		h.recordStep(signalID, stepConfirmation, "error", map[string]string{"error": err.Error()})
+		return orderResult{Status: signalFailed, Message: fmt.Sprintf("❌ Failed to store pending order: %v", err)}
+	}
+
+	text := confirmationText(id, &pending, expiresAt)
+	sent, err := h.bot.SendMessage(chatID, text, &telegram.SendOptions{Keyboard: confirmationKeyboard(id)})
+	if err != nil {
+		if _, err := h.db.DecidePendingOrder(id, database.PendingStatusRejected, ""); err != nil {
+			fmt.Printf("Failed to reject pending order %d: %v\n", id, err)
+		}
+		h.recordStep(signalID, stepConfirmation, "error", map[string]interface{}{"pending_order_id": id, "error": err.Error()})
+		msg := fmt.Sprintf("❌ Order Failed: could not send the confirmation request for %s, order rejected: %v", orderSummary(&pending), err)
+		fmt.Println(msg)
+		return orderResult{Status: signalFailed, Message: msg}
+	}
+	if err := h.db.SetPendingMessage(id, chatID, sent[0].MessageID); err != nil {
+		fmt.Printf("Failed to store confirmation message of pending order %d: %v\n", id, err)
+	}
+
+	h.recordStep(signalID, stepConfirmation, database.PendingStatusPending, map[string]interface{}{"pending_order_id": id, "expires_at": expiresAt.UTC()})
+	return orderResult{Status: signalPending}
+}
+
+func confirmationText(id int64, req *WebhookRequest, expiresAt time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔔 Confirm order #%d", id)
+	if req.Strategy != "" {
+		fmt.Fprintf(&b, " (%s)", req.Strategy)
+	}
+	b.WriteString("\n" + orderSummary(req))
+	fmt.Fprintf(&b, "\nExpires at %s", expiresAt.UTC().Format("15:04:05 UTC"))
+	return b.String()
+}
+
+// orderSummary describes the order of a request on one line, e.g. "buy 0.1 XBTUSD limit @ 95000".
+func orderSummary(req *WebhookRequest) string {
+	orderType := req.OrderType
+	if orderType == "" {
+		orderType = "market"
+	}
+	s := fmt.Sprintf("%s %s %s %s", req.Type, req.Volume, req.Pair, orderType)
+	if req.Price != "" {
+		s += " @ " + req.Price
+	}
+	if req.Price2 != "" {
+		s += ", price2 " + req.Price2
+	}
+	if req.Leverage != "" {
+		s += ", leverage " + req.Leverage
+	}
+	if req.CloseOrderType != "" {
+		s += fmt.Sprintf(", close %s @ %s", req.CloseOrderType, req.ClosePrice)
+	}
+	return s
+}
+
+func confirmationKeyboard(id int64) telegram.InlineKeyboard {
+	arg := strconv.FormatInt(id, 10)
+	return telegram.InlineKeyboard{{
+		{Text: "✅ Approve", Data: "approve:" + arg},
+		{Text: "❌ Reject", Data: "reject:" + arg},
+		{Text: "✏️ Modify", Data: "modify:" + arg},
+	}}
+}
+
+// pendingOrder loads the pending order with the ID in a button or command argument.
+// Orders past their expiry time are expired first.
+func (h *WebhookHandler) pendingOrder(arg string) (*database.PendingOrder, *WebhookRequest, error) {
+	if h.db == nil {
+		return nil, nil, fmt.Errorf("database not initialized")
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(arg, "#"), 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid order id %q", arg)
+	}
+	order, err := h.db.GetPendingOrder(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if order == nil {
+		return nil, nil, fmt.Errorf("order #%d not found", id)
+	}
+	if order.Status == database.PendingStatusPending && !time.Now().Before(order.ExpiresAt) {
+		h.expire(*order)
+		order.Status = database.PendingStatusExpired
+	}
+	if order.Status != database.PendingStatusPending {
+		return nil, nil, fmt.Errorf("order #%d is %s", id, order.Status)
+	}
+
+	var req WebhookRequest
+	if err := json.Unmarshal(order.Request, &req); err != nil {
+		return nil, nil, err
+	}
+	return order, &req, nil
+}
+
+func (h *WebhookHandler) onApprove(q *telegram.CallbackQuery, arg string) (string, error) {
+	order, req, err := h.pendingOrder(arg)
+	if err != nil {
+		return "", err
+	}
+	if !h.beginOrder() {
+		return "", errShuttingDown
+	}
+	defer h.inflight.Done()
+	by := userName(q.From)
+	if ok, err := h.db.DecidePendingOrder(order.ID, database.PendingStatusApproved, by); err != nil || !ok {
+		return "", decideError(order.ID, err)
+	}
+	h.recordStep(order.SignalID, stepConfirmation, database.PendingStatusApproved, map[string]string{"by": by})
+	h.closeConfirmation(order, req, "✅ Approved by "+by)
+
+	result := h.placeOrder(order.SignalID, req)
+	h.completeOrder(order.SignalID, req, result)
+	return "Approved", nil
+}
+
+func (h *WebhookHandler) onReject(q *telegram.CallbackQuery, arg string) (string, error) {
+	order, req, err := h.pendingOrder(arg)
+	if err != nil {
+		return "", err
+	}
+	by := userName(q.From)
+	if ok, err := h.db.DecidePendingOrder(order.ID, database.PendingStatusRejected, by); err != nil || !ok {
+		return "", decideError(order.ID, err)
+	}
+	h.recordStep(order.SignalID, stepConfirmation, database.PendingStatusRejected, map[string]string{"by": by})
+	h.setSignalStatus(order.SignalID, signalDeclined)
+	h.closeConfirmation(order, req, "❌ Rejected by "+by)
+	return "Rejected", nil
+}
+
+func (h *WebhookHandler) onModify(q *telegram.CallbackQuery, arg string) (string, error) {
+	order, _, err := h.pendingOrder(arg)
+	if err != nil {
+		return "", err
+	}
+	usage := fmt.Sprintf("Send /modify %d volume=<v> price=<p> price2=<p> to change order #%d, then approve it.", order.ID, order.ID)
+	if err := h.bot.Reply(order.ChatID, usage); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+// modifiableFields are the order fields /modify can change.
+var modifiableFields = map[string]func(*WebhookRequest) *string{
+	"volume":       func(r *WebhookRequest) *string { return &r.Volume },
+	"price":        func(r *WebhookRequest) *string { return &r.Price },
+	"price2":       func(r *WebhookRequest) *string { return &r.Price2 },
+	"ordertype":    func(r *WebhookRequest) *string { return &r.OrderType },
+	"leverage":     func(r *WebhookRequest) *string { return &r.Leverage },
+	"close_price":  func(r *WebhookRequest) *string { return &r.ClosePrice },
+	"close_price2": func(r *WebhookRequest) *string { return &r.ClosePrice2 },
+}
+
+func (h *WebhookHandler) cmdModify(args []string) (string, error) {
+	if len(args) < 2 {
+		return "Usage: /modify <id> volume=<v> price=<p> price2=<p>", nil
+	}
+	order, req, err := h.pendingOrder(args[0])
+	if err != nil {
+		return "", err
+	}
+
+	changes := make(map[string]string)
+	for _, arg := range args[1:] {
+		key, value, ok := strings.Cut(arg, "=")
+		field, known := modifiableFields[strings.ToLower(key)]
+		if !ok || !known {
+			return "", fmt.Errorf("cannot modify %q", arg)
+		}
+		*field(req) = value
+		changes[strings.ToLower(key)] = value
+	}
+	if err := validateOrder(req); err != nil {
+		return "", err
+	}
+
+	if ok, err := h.db.UpdatePendingRequest(order.ID, req); err != nil || !ok {
+		return "", decideError(order.ID, err)
+	}
+	h.recordStep(order.SignalID, stepConfirmation, "modified", changes)
+
+	if order.MessageID != 0 {
+		if err := h.bot.EditMessage(order.ChatID, order.MessageID, confirmationText(order.ID, req, order.ExpiresAt),
+			&telegram.SendOptions{Keyboard: confirmationKeyboard(order.ID)}); err != nil {
+			fmt.Printf("Failed to update confirmation message of pending order %d: %v\n", order.ID, err)
+		}
+	}
+	return fmt.Sprintf("✏️ Order #%d is now: %s", order.ID, orderSummary(req)), nil
+}
+
+// ExpirePendingOrders expires the pending orders that were not decided in time.
+func (h *WebhookHandler) ExpirePendingOrders() error {
+	if h.db == nil {
+		return nil
+	}
+	orders, err := h.db.GetExpiredPendingOrders(time.Now())
+	if err != nil {
+		return err
+	}
+	for _, order := range orders {
+		h.expire(order)
+	}
+	return nil
+}
+
+// RunPendingExpiry expires pending orders every interval until ctx is cancelled.
+func (h *WebhookHandler) RunPendingExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := h.ExpirePendingOrders(); err != nil {
+				fmt.Printf("Failed to expire pending orders: %v\n", err)
+			}
+		}
+	}
+}
+
+func (h *WebhookHandler) expire(order database.PendingOrder) {
+	if ok, err := h.db.DecidePendingOrder(order.ID, database.PendingStatusExpired, ""); err != nil || !ok {
+		return
+	}
+	h.recordStep(order.SignalID, stepConfirmation, database.PendingStatusExpired, nil)
+	h.setSignalStatus(order.SignalID, signalExpired)
+
+	var req WebhookRequest
+	if err := json.Unmarshal(order.Request, &req); err == nil {
+		h.closeConfirmation(&order, &req, "⌛ Expired without confirmation")
+	}
+}
+
+// closeConfirmation replaces the buttons of the confirmation message with the decision.
+func (h *WebhookHandler) closeConfirmation(order *database.PendingOrder, req *WebhookRequest, decision string) {
+	if h.bot == nil || order.MessageID == 0 {
+		return
+	}
+	text := fmt.Sprintf("Order #%d: %s\n%s", order.ID, orderSummary(req), decision)
+	if err := h.bot.EditMessage(order.ChatID, order.MessageID, text, nil); err != nil {
+		fmt.Printf("Failed to update confirmation message of pending order %d: %v\n", order.ID, err)
+	}
+}
+
+func decideError(id int64, err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("order #%d was already decided", id)
+}
+
+func userName(u *telegram.User) string {
+	if u == nil {
+		return "unknown"
+	}
+	if u.Username != "" {
+		return "@" + u.Username
+	}
+	return strconv.FormatInt(u.ID, 10)
+}
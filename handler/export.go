@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"tvwh2k/api"
+	"tvwh2k/database"
+	"tvwh2k/export"
+)
+
+// HandleExport serves a CSV report (trades, fills, gains, koinly or cointracking).
+// The period is selected with year or with from/to; gains accept fiat and method.
+func (h *WebhookHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		api.WriteError(w, r, http.StatusServiceUnavailable, "Database not initialized")
+		return
+	}
+
+	report := strings.TrimSuffix(r.PathValue("report"), ".csv")
+	opts, name, err := parseExportOptions(r, h.pnlMethod)
+	if err != nil {
+		api.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Render to a buffer first so errors can still be reported with a proper status.
+	var buf bytes.Buffer
+	if err := export.New(h.db, h.krakenClient).Write(&buf, report, opts); err != nil {
+		api.WriteInternalError(w, r, "Failed to export "+report, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", report+name+".csv"))
+	w.Write(buf.Bytes())
+}
+
+// parseExportOptions reads year, from, to, fiat and method. It also returns a
+// file name suffix describing the period.
+func parseExportOptions(r *http.Request, method database.CostMethod) (export.Options, string, error) {
+	q := r.URL.Query()
+	opts := export.Options{Method: method}
+	name := ""
+
+	if v := q.Get("year"); v != "" {
+		year, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, "", fmt.Errorf("invalid year %q", v)
+		}
+		opts = export.Year(year)
+		opts.Method = method
+		name = "-" + v
+	} else {
+		var err error
+		if opts.From, err = parseTime(q.Get("from")); err != nil {
+			return opts, "", fmt.Errorf("invalid from: %w", err)
+		}
+		if opts.To, err = parseTime(q.Get("to")); err != nil {
+			return opts, "", fmt.Errorf("invalid to: %w", err)
+		}
+	}
+
+	opts.Fiat = strings.ToUpper(q.Get("fiat"))
+	if v := q.Get("method"); v != "" {
+		m, err := database.ParseCostMethod(v)
+		if err != nil {
+			return opts, "", err
+		}
+		opts.Method = m
+	}
+	return opts, name, nil
+}
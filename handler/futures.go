@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"fmt"
+	"tvwh2k/krakenfutures"
+)
+
+// futuresOrderTypes maps the spot order type names used in webhooks to their Kraken Futures equivalents.
+var futuresOrderTypes = map[string]string{
+	"market":      "mkt",
+	"limit":       "lmt",
+	"stop-loss":   "stp",
+	"take-profit": "take_profit",
+}
+
+// placeFuturesOrder places the order described by req on Kraken Futures.
+// The webhook fields map as follows: pair -> symbol, type -> side, volume -> size,
+// price -> limit price (or stop price for stop orders), price2 -> stop price.
+func (h *WebhookHandler) placeFuturesOrder(signalID int64, req *WebhookRequest) orderResult {
+	if req.OrderType == "" {
+		req.OrderType = "market"
+	}
+	orderType, ok := futuresOrderTypes[req.OrderType]
+	if !ok {
+		// Allow native futures order types (e.g. "post", "ioc") to pass through.
+		orderType = req.OrderType
+	}
+
+	orderInput := krakenfutures.OrderInput{
+		OrderType:  orderType,
+		Symbol:     req.Pair,
+		Side:       req.Type,
+		Size:       req.Volume,
+		ReduceOnly: req.ReduceOnly,
+	}
+	switch orderType {
+	case "stp", "take_profit":
+		orderInput.StopPrice = req.Price
+		orderInput.LimitPrice = req.Price2 // Optional: turns it into a stop-limit order
+	default:
+		orderInput.LimitPrice = req.Price
+		orderInput.StopPrice = req.Price2
+	}
+
+	h.recordStep(signalID, stepRisk, "allowed", nil)
+
+	// Kraken Futures has no validate-only mode, so test mode skips submission entirely.
+	if h.TestMode() {
+		h.recordStep(signalID, stepOrderRequest, "skipped", orderInput)
+		resultMsg := fmt.Sprintf("✅ Futures order not submitted (test mode): %s %s %s %s", orderInput.Side, orderInput.Size, orderInput.Symbol, orderInput.OrderType)
+		fmt.Println(resultMsg)
+		return orderResult{Status: signalValidated, Message: resultMsg}
+	}
+
+	h.recordStep(signalID, stepOrderRequest, "sent", orderInput)
+	resp, err := h.futuresClient.SendOrder(orderInput)
+	if err != nil {
+		// Rejected orders come with a response that explains the rejection.
+		detail := map[string]interface{}{"error": err.Error()}
+		if resp != nil {
+			detail["response"] = resp
+		}
+		h.recordStep(signalID, stepOrderResponse, "error", detail)
+		resultMsg := fmt.Sprintf("❌ Futures Order Failed: %v", err)
+		fmt.Println(resultMsg)
+		return orderResult{Status: signalFailed, Message: resultMsg}
+	}
+	h.recordStep(signalID, stepOrderResponse, "ok", resp)
+
+	orderID := resp.SendStatus.OrderID
+	resultMsg := fmt.Sprintf("✅ Futures Order Placed: %s %s %s %s\nOrderID: %s", orderInput.Side, orderInput.Size, orderInput.Symbol, orderInput.OrderType, orderID)
+	fmt.Println(resultMsg)
+	return orderResult{Status: signalPlaced, Message: resultMsg, TxID: orderID, Response: resp}
+}
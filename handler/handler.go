@@ -3,28 +3,128 @@ package handler
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"tvwh2k/api"
+	"tvwh2k/auth"
 	"tvwh2k/database"
+	"tvwh2k/earn"
 	"tvwh2k/kraken"
+	"tvwh2k/krakenfutures"
+	"tvwh2k/notify"
+	"tvwh2k/report"
 	"tvwh2k/telegram"
 )
 
 type WebhookHandler struct {
-	krakenClient *kraken.Kraken
-	db           *database.DB
+	krakenClient  *kraken.Kraken
+	futuresClient *krakenfutures.Client
+	notifier      *notify.Router
+	notes         *report.Notes
+	earnPolicy    *earn.Policy
+	db            *database.DB
+	pnlMethod     database.CostMethod
+	paused        atomic.Bool // Set by the /pause bot command
+	testMode      atomic.Bool // Orders are only validated, see SetTestMode
+
+	authMu         sync.RWMutex
+	authenticators map[string]auth.Authenticator // By strategy, "" for all others
+
+	// Orders being processed, see shutdown.go
+	lifecycleMu sync.Mutex
+	closing     bool
+	inflight    sync.WaitGroup
+
+	// Confirmation mode, see confirm.go
+	bot               *telegram.Bot
+	confirmMu         sync.RWMutex // Guards the settings below, which may change on reload
+	confirmChatID     int64
+	confirmStrategies map[string]bool
+	confirmTimeout    time.Duration
 }
 
 func NewWebhookHandler(k *kraken.Kraken, db *database.DB) *WebhookHandler {
 	return &WebhookHandler{
 		krakenClient: k,
 		db:           db,
+		pnlMethod:    database.FIFO,
+	}
+}
+
+// SetPnLMethod sets the default cost method used by the PnL endpoints.
+func (h *WebhookHandler) SetPnLMethod(m database.CostMethod) {
+	h.pnlMethod = m
+}
+
+// SetTestMode makes spot orders validate-only and skips the submission of futures
+// orders. It may be changed while webhooks are handled.
+func (h *WebhookHandler) SetTestMode(on bool) {
+	h.testMode.Store(on)
+}
+
+// TestMode reports whether orders are only validated.
+func (h *WebhookHandler) TestMode() bool {
+	return h.testMode.Load()
+}
+
+// SetEarnPolicy enables moving funds between Kraken Earn and the spot balance around spot orders.
+func (h *WebhookHandler) SetEarnPolicy(p *earn.Policy) {
+	h.earnPolicy = p
+}
+
+// SetNotifier enables the notifications for received signals and order results.
+func (h *WebhookHandler) SetNotifier(r *notify.Router) {
+	h.notifier = r
+}
+
+// SetTradeNotes adds the order details and charts of n to the notifications of placed spot orders.
+func (h *WebhookHandler) SetTradeNotes(n *report.Notes) {
+	h.notes = n
+}
+
+// SetAuthenticator sets how the webhooks of strategy are authenticated, replacing
+// the previous authenticator, so the secrets of each strategy can be rotated on their own.
+// The authenticator of strategy "" applies to strategies without their own; a nil
+// authenticator removes the strategy's own. Without authenticators all webhooks are rejected.
+func (h *WebhookHandler) SetAuthenticator(strategy string, a auth.Authenticator) {
+	h.authMu.Lock()
+	defer h.authMu.Unlock()
+	if h.authenticators == nil {
+		h.authenticators = make(map[string]auth.Authenticator)
+	}
+	if a == nil {
+		delete(h.authenticators, strategy)
+		return
 	}
+	h.authenticators[strategy] = a
+}
+
+func (h *WebhookHandler) authenticator(strategy string) auth.Authenticator {
+	h.authMu.RLock()
+	defer h.authMu.RUnlock()
+	if a, ok := h.authenticators[strategy]; ok {
+		return a
+	}
+	if a, ok := h.authenticators[""]; ok {
+		return a
+	}
+	return auth.All{}
+}
+
+// SetFuturesClient enables routing of webhook orders with "exchange": "futures" to Kraken Futures.
+func (h *WebhookHandler) SetFuturesClient(f *krakenfutures.Client) {
+	h.futuresClient = f
 }
 
 type WebhookRequest struct {
-	Token     string `json:"token"`
+	Token     string `json:"token,omitempty"` // Shared secret, see auth.Token
+	Exchange  string `json:"exchange"`        // spot (default) or futures
+	Strategy  string `json:"strategy"`        // Optional strategy name used for reporting
 	Text      string `json:"text"`
 	Pair      string `json:"pair"`
 	Type      string `json:"type"`      // buy/sell
@@ -33,33 +133,74 @@ type WebhookRequest struct {
 	Price     string `json:"price"`
 	Price2    string `json:"price2"` // Secondary price
 
+	// Margin and scheduling options
+	Leverage   string `json:"leverage"`
+	ReduceOnly bool   `json:"reduce_only"`
+	StartTm    string `json:"starttm"`
+	ExpireTm   string `json:"expiretm"`
+	Deadline   string `json:"deadline"`
+
 	// Conditional Close definitions (e.g. for TP/SL)
 	CloseOrderType string `json:"close_ordertype"`
 	ClosePrice     string `json:"close_price"`
 	ClosePrice2    string `json:"close_price2"`
 }
 
+// maxWebhookBody limits the size of webhook requests, also when the handler is
+// served without the API router's limit.
+const maxWebhookBody = 1 << 20
+
+// WebhookResponse is the JSON result of a webhook.
+type WebhookResponse struct {
+	SignalID int64  `json:"signal_id,omitempty"` // 0 if the signal could not be stored
+	Status   string `json:"status"`              // The decision, e.g. placed, validated, skipped, pending or rejected
+	TxID     string `json:"txid,omitempty"`      // Set if an order was placed
+	Message  string `json:"message,omitempty"`
+}
+
+// ServeHTTP handles a signal posted to /webhooks or to /webhooks/{strategy}. The
+// strategy in the URL takes precedence over the one in the body.
 func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		api.WriteBodyError(w, r, err)
+		return
+	}
+
+	// The parse error is only logged: the request has not been authenticated yet.
 	var req WebhookRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		fmt.Printf("[%s] Invalid JSON from %s: %v\n", api.RequestID(r.Context()), r.RemoteAddr, err)
+		api.WriteError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if strategy := r.PathValue("strategy"); strategy != "" {
+		if req.Strategy != "" && req.Strategy != strategy {
+			api.WriteError(w, r, http.StatusBadRequest, "strategy in the body does not match the URL")
+			return
+		}
+		req.Strategy = strategy
+	}
 
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := h.authenticator(req.Strategy).Authenticate(r, body); err != nil {
+		fmt.Printf("[%s] Rejected webhook from %s: %v\n", api.RequestID(r.Context()), r.RemoteAddr, err)
+		api.WriteError(w, r, http.StatusUnauthorized, "Unauthorized")
 		return
 	}
+	req.Token = "" // Not stored with the signal
 
-	if req.Token != os.Getenv("TOKEN") {
-		fmt.Printf("Invalid token: %s\n", req.Token)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if !h.beginOrder() {
+		api.WriteError(w, r, http.StatusServiceUnavailable, "Shutting down")
 		return
 	}
+	defer h.inflight.Done()
 
 	fmt.Printf("Received valid webhook for %s %s\n", req.Type, req.Pair)
 
 	// Save signal to DB
 	var signalID int64
 	if h.db != nil {
-		id, err := h.db.SaveSignal(req.Pair, req.Type, req)
+		id, err := h.db.SaveSignal(req.Pair, req.Type, req.Strategy, req)
 		if err != nil {
 			fmt.Printf("Failed to save signal: %v\n", err)
 		} else {
@@ -68,119 +209,280 @@ func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Send initial notification
-	chatIdStr := os.Getenv("TELEGRAM_CHAT_ID")
-	chatId, err := strconv.Atoi(chatIdStr)
-	if err != nil {
-		fmt.Printf("Error parsing chat id: %v\n", err)
-	} else {
-		msg := fmt.Sprintf("Received Signal: %s", req.Text)
-		if req.Pair != "" {
-			msg += fmt.Sprintf("\nAction: %s %s %s", req.Type, req.Volume, req.Pair)
+	msg := fmt.Sprintf("Received Signal: %s", req.Text)
+	if req.Pair != "" {
+		msg += fmt.Sprintf("\nAction: %s %s %s", req.Type, req.Volume, req.Pair)
+	}
+	h.notify(notify.Event{Type: notify.EventSignal, Severity: notify.Info, Text: msg, SignalID: signalID, Strategy: req.Strategy})
+
+	result := h.processOrder(signalID, &req)
+	h.completeOrder(signalID, &req, result)
+
+	api.WriteJSON(w, http.StatusOK, WebhookResponse{SignalID: signalID, Status: result.Status, TxID: result.TxID, Message: result.Message})
+}
+
+// completeOrder stores the final status of a signal and the trade of a placed order
+// and sends the result message.
+func (h *WebhookHandler) completeOrder(signalID int64, req *WebhookRequest, result orderResult) {
+	h.setSignalStatus(signalID, result.Status)
+
+	// Save Trade Result to DB; futures orders are kept apart from the spot trades
+	if h.db != nil && signalID != 0 && result.TxID != "" {
+		if req.Exchange == "futures" {
+			order := database.FuturesOrder{SignalID: signalID, Symbol: req.Pair, Side: req.Type, OrderType: req.OrderType, Size: req.Volume, Price: req.Price, OrderID: result.TxID}
+			if err := h.db.SaveFuturesOrder(order); err != nil {
+				fmt.Printf("Failed to save futures order: %v\n", err)
+			}
+		} else if tradeID, err := h.db.SaveTrade(signalID, req.Pair, req.Type, req.OrderType, req.Volume, req.Price, result.TxID); err != nil {
+			fmt.Printf("Failed to save trade: %v\n", err)
+		} else if err := h.db.RecordOrderEvent(tradeID, "open", database.SourceWebhook, result.Response); err != nil {
+			fmt.Printf("Failed to record order event: %v\n", err)
 		}
-		telegram.SendMessage(msg, int64(chatId))
 	}
 
-	// Execute Kraken Order if critical fields are present
-	if h.krakenClient != nil && req.Pair != "" && req.Type != "" && req.Volume != "" {
-		// Default to market if not specified
-		if req.OrderType == "" {
-			req.OrderType = "market"
+	if result.Message != "" {
+		event, severity := resultEvent(result.Status)
+		e := notify.Event{Type: event, Severity: severity, Text: result.Message, SignalID: signalID, Strategy: req.Strategy}
+		if event == notify.EventOrderPlaced && h.notes != nil && (req.Exchange == "" || req.Exchange == "spot") {
+			e.Title, e.Text, _ = strings.Cut(result.Message, "\n")
+			order := report.Order{
+				Strategy:       req.Strategy,
+				Pair:           req.Pair,
+				Side:           req.Type,
+				OrderType:      req.OrderType,
+				Volume:         req.Volume,
+				Price:          req.Price,
+				CloseOrderType: req.CloseOrderType,
+				ClosePrice:     req.ClosePrice,
+			}
+			// The notes query Kraken and may render a chart: add them without holding up the
+			// response. Shutdown waits for the notification like for the order.
+			h.inflight.Add(1)
+			go func() {
+				defer h.inflight.Done()
+				h.notes.OrderPlaced(&e, order)
+				h.notify(e)
+			}()
+			return
 		}
+		h.notify(e)
+	}
+}
 
-		orderInput := kraken.OrderInput{
-			Pair:      req.Pair,
-			Type:      req.Type,
-			OrderType: req.OrderType,
-			Volume:    req.Volume,
-			Price:     req.Price,
-			Price2:    req.Price2,
+// orderResult describes the outcome of processing the order part of a signal.
+type orderResult struct {
+	Status  string // Final signal status, one of the signalStatus constants
+	Message string // Result message to notify, empty if there is nothing to report
+	TxID    string // Transaction or order ID, empty if no order was placed
+
+	Response interface{} // Exchange response to the placed order, recorded as the first order event
+}
+
+// processOrder validates the order fields of a signal and routes the order to the requested exchange.
+func (h *WebhookHandler) processOrder(signalID int64, req *WebhookRequest) orderResult {
+	// Signals without order fields are notifications only
+	if req.Pair == "" && req.Type == "" && req.Volume == "" {
+		h.recordStep(signalID, stepValidation, "skipped", map[string]string{"reason": "no order fields"})
+		return orderResult{Status: signalNotified}
+	}
+
+	if err := validateOrder(req); err != nil {
+		h.recordStep(signalID, stepValidation, "failed", map[string]string{"error": err.Error()})
+		msg := fmt.Sprintf("❌ Order Rejected: %v", err)
+		fmt.Println(msg)
+		return orderResult{Status: signalRejected, Message: msg}
+	}
+	h.recordStep(signalID, stepValidation, "ok", nil)
+
+	if h.needsConfirmation(req.Strategy) {
+		return h.requestConfirmation(signalID, req)
+	}
+	return h.placeOrder(signalID, req)
+}
+
+// placeOrder routes a validated order to the requested exchange.
+func (h *WebhookHandler) placeOrder(signalID int64, req *WebhookRequest) orderResult {
+	if h.Paused() {
+		fmt.Println("Trading paused, skipping order.")
+		h.recordStep(signalID, stepOrderRequest, "skipped", map[string]string{"reason": "trading paused"})
+		return orderResult{Status: signalSkipped, Message: "⏸ Trading paused, order skipped."}
+	}
+
+	switch req.Exchange {
+	case "", "spot":
+		if h.krakenClient == nil {
+			fmt.Println("Kraken client not initialized, skipping order.")
+			h.recordStep(signalID, stepOrderRequest, "skipped", map[string]string{"reason": "kraken client not initialized"})
+			return orderResult{Status: signalSkipped}
+		}
+		return h.placeSpotOrder(signalID, req)
+	case "futures":
+		if h.futuresClient == nil {
+			fmt.Println("Kraken Futures client not initialized, skipping order.")
+			h.recordStep(signalID, stepOrderRequest, "skipped", map[string]string{"reason": "kraken futures client not initialized"})
+			return orderResult{Status: signalSkipped}
 		}
+		return h.placeFuturesOrder(signalID, req)
+	default:
+		msg := fmt.Sprintf("❌ Order Failed: unknown exchange %q", req.Exchange)
+		fmt.Println(msg)
+		h.recordStep(signalID, stepValidation, "failed", map[string]string{"error": msg})
+		return orderResult{Status: signalRejected, Message: msg}
+	}
+}
 
-		// Handle Conditional Close (Profit/Stop Loss)
-		if req.CloseOrderType != "" {
-			closeParams := make(map[string]string)
-			closeParams["ordertype"] = req.CloseOrderType
-			if req.ClosePrice != "" {
-				closeParams["price"] = req.ClosePrice
-			}
-			if req.ClosePrice2 != "" {
-				closeParams["price2"] = req.ClosePrice2
-			}
-			orderInput.Close = closeParams
-			fmt.Println("Attached conditional close order (TP/SL).")
+// validateOrder checks the order fields of a signal before anything is sent to an exchange.
+func validateOrder(req *WebhookRequest) error {
+	if req.Pair == "" || req.Type == "" || req.Volume == "" {
+		return fmt.Errorf("pair, type and volume are required")
+	}
+	if req.Type != "buy" && req.Type != "sell" {
+		return fmt.Errorf("invalid type %q, want buy or sell", req.Type)
+	}
+	if v, err := strconv.ParseFloat(req.Volume, 64); err != nil || v <= 0 {
+		return fmt.Errorf("invalid volume %q", req.Volume)
+	}
+	if req.Leverage != "" {
+		if err := kraken.CheckLeverage(req.Leverage); err != nil {
+			return err
 		}
+	}
+	return nil
+}
+
+// placeSpotOrder places the order described by req on Kraken spot.
+func (h *WebhookHandler) placeSpotOrder(signalID int64, req *WebhookRequest) orderResult {
+	// Default to market if not specified
+	if req.OrderType == "" {
+		req.OrderType = "market"
+	}
+
+	orderInput := kraken.OrderInput{
+		Pair:       req.Pair,
+		Type:       req.Type,
+		OrderType:  req.OrderType,
+		Volume:     req.Volume,
+		Price:      req.Price,
+		Price2:     req.Price2,
+		Leverage:   req.Leverage,
+		ReduceOnly: req.ReduceOnly,
+		StartTm:    req.StartTm,
+		ExpireTm:   req.ExpireTm,
+		Deadline:   req.Deadline,
+	}
 
-		// Check if we are in test mode via env (or could be in payload)
-		if os.Getenv("KRAKEN_TEST_MODE") == "true" {
-			orderInput.Validate = true
-			fmt.Println("Test mode enabled, validating order only.")
+	// Handle Conditional Close (Profit/Stop Loss)
+	if req.CloseOrderType != "" {
+		closeParams := make(map[string]string)
+		closeParams["ordertype"] = req.CloseOrderType
+		if req.ClosePrice != "" {
+			closeParams["price"] = req.ClosePrice
 		}
+		if req.ClosePrice2 != "" {
+			closeParams["price2"] = req.ClosePrice2
+		}
+		orderInput.Close = closeParams
+		fmt.Println("Attached conditional close order (TP/SL).")
+	}
 
-		resp, err := h.krakenClient.AddOrder(orderInput)
+	if h.TestMode() {
+		orderInput.Validate = true
+		fmt.Println("Test mode enabled, validating order only.")
+	}
 
-		var resultMsg string
-		var txid string
+	// Risk checks: refuse orders that add exposure while the margin level is too low
+	if !orderInput.ReduceOnly {
+		if err := h.krakenClient.CheckMarginLevel(); err != nil {
+			h.recordStep(signalID, stepRisk, "refused", map[string]string{"error": err.Error()})
+			msg := fmt.Sprintf("❌ Order Refused: %v", err)
+			fmt.Println(msg)
+			return orderResult{Status: signalRefused, Message: msg}
+		}
+	}
+	h.recordStep(signalID, stepRisk, "allowed", nil)
 
+	// Free up funds held in Kraken Earn (skipped for validate-only orders)
+	release := func(placed bool) {}
+	if h.earnPolicy != nil && !orderInput.Validate {
+		var err error
+		release, err = h.earnPolicy.Hold(signalID, orderInput)
 		if err != nil {
-			resultMsg = fmt.Sprintf("❌ Order Failed: %v", err)
-			fmt.Println(resultMsg)
-		} else {
-			resultMsg = fmt.Sprintf("✅ Order Placed: %s", resp.Description.Order)
-			if len(resp.TxID) > 0 {
-				txid = resp.TxID[0]
-				resultMsg += fmt.Sprintf("\nTxID: %s", txid)
-			}
-			if resp.Description.Close != "" {
-				resultMsg += fmt.Sprintf("\nClose: %s", resp.Description.Close)
-			}
-			fmt.Println(resultMsg)
+			fmt.Printf("Earn deallocation failed: %v\n", err)
 		}
+	}
 
-		// Save Trade Result to DB
-		if h.db != nil && signalID != 0 && txid != "" {
-			err := h.db.SaveTrade(signalID, req.Pair, req.Type, req.OrderType, req.Volume, req.Price, txid)
-			if err != nil {
-				fmt.Printf("Failed to save trade: %v\n", err)
-			}
-		}
+	h.recordStep(signalID, stepOrderRequest, "sent", orderInput)
+	resp, err := h.krakenClient.AddOrder(orderInput)
+	release(err == nil)
+
+	var result orderResult
 
-		// Send result to Telegram
-		if chatId != 0 {
-			telegram.SendMessage(resultMsg, int64(chatId))
+	if err != nil {
+		h.recordStep(signalID, stepOrderResponse, "error", map[string]string{"error": err.Error()})
+		result.Status = signalFailed
+		result.Message = fmt.Sprintf("❌ Order Failed: %v", err)
+		fmt.Println(result.Message)
+	} else {
+		h.recordStep(signalID, stepOrderResponse, "ok", resp)
+		result.Status = signalPlaced
+		if orderInput.Validate {
+			result.Status = signalValidated
+		}
+		result.Message = fmt.Sprintf("✅ Order Placed: %s", resp.Description.Order)
+		if len(resp.TxID) > 0 {
+			result.TxID = resp.TxID[0]
+			result.Response = resp
+			result.Message += fmt.Sprintf("\nTxID: %s", result.TxID)
+		}
+		if resp.Description.Close != "" {
+			result.Message += fmt.Sprintf("\nClose: %s", resp.Description.Close)
 		}
-	} else if h.krakenClient == nil {
-		fmt.Println("Kraken client not initialized, skipping order.")
+		fmt.Println(result.Message)
 	}
+
+	return result
 }
 
 func (h *WebhookHandler) HandleGetSignals(w http.ResponseWriter, r *http.Request) {
 	if h.db == nil {
-		http.Error(w, "Database not initialized", http.StatusInternalServerError)
+		api.WriteError(w, r, http.StatusServiceUnavailable, "Database not initialized")
+		return
+	}
+
+	f, err := parseFilter(r)
+	if err != nil {
+		api.WriteError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	signals, err := h.db.GetRecentSignals(50)
+	signals, next, err := h.db.QuerySignals(f)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to fetch signals: %v", err), http.StatusInternalServerError)
+		api.WriteInternalError(w, r, "Failed to fetch signals", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(signals)
+	setNextCursor(w, next)
+	api.WriteJSON(w, http.StatusOK, signals)
 }
 
 func (h *WebhookHandler) HandleGetTrades(w http.ResponseWriter, r *http.Request) {
 	if h.db == nil {
-		http.Error(w, "Database not initialized", http.StatusInternalServerError)
+		api.WriteError(w, r, http.StatusServiceUnavailable, "Database not initialized")
+		return
+	}
+
+	f, err := parseFilter(r)
+	if err != nil {
+		api.WriteError(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	trades, err := h.db.GetRecentTrades(50)
+	trades, next, err := h.db.QueryTrades(f)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to fetch trades: %v", err), http.StatusInternalServerError)
+		api.WriteInternalError(w, r, "Failed to fetch trades", err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(trades)
+	setNextCursor(w, next)
+	api.WriteJSON(w, http.StatusOK, trades)
 }
@@ -0,0 +1,333 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"tvwh2k/auth"
+	"tvwh2k/database"
+	"tvwh2k/notify"
+	"tvwh2k/telegram"
+)
+
+func newTestHandler(t *testing.T) *WebhookHandler {
+	t.Helper()
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	h := NewWebhookHandler(nil, db)
+	h.SetAuthenticator("", &auth.Token{Secrets: []string{"secret"}})
+	return h
+}
+
+func TestWebhookAuthentication(t *testing.T) {
+	h := newTestHandler(t)
+	h.SetAuthenticator("trend", &auth.Token{Secrets: []string{"new", "old"}})
+	mux := http.NewServeMux()
+	mux.Handle("/webhooks", h)
+	mux.Handle("/webhooks/{strategy}", h)
+
+	tests := []struct {
+		path, body string
+		want       int
+	}{
+		{"/webhooks", `{"token":"wrong","pair":"XBTUSD","type":"hold"}`, http.StatusUnauthorized},
+		{"/webhooks", `{"pair":"XBTUSD","type":"hold"}`, http.StatusUnauthorized},
+		{"/webhooks", `{"token":"secret","strategy":"trend","pair":"XBTUSD","type":"hold"}`, http.StatusUnauthorized},
+		{"/webhooks/trend", `{"token":"secret","pair":"XBTUSD","type":"hold"}`, http.StatusUnauthorized},
+		{"/webhooks/trend", `{"token":"old","strategy":"scalp","pair":"XBTUSD","type":"hold"}`, http.StatusBadRequest},
+		{"/webhooks/trend", `{"token":"old","pair":"XBTUSD","type":"hold"}`, http.StatusOK},
+		{"/webhooks/scalp?token=secret", `{"pair":"XBTUSD","type":"hold"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body)))
+		if rec.Code != tt.want {
+			t.Errorf("POST %s %s: status %d, want %d", tt.path, tt.body, rec.Code, tt.want)
+		}
+	}
+
+	// Malformed and oversized bodies are refused before authentication without details.
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(`{"token":`)))
+	if rec.Code != http.StatusBadRequest || strings.Contains(rec.Body.String(), "unexpected end") {
+		t.Errorf("invalid JSON: status %d, body %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(strings.Repeat(" ", maxWebhookBody+1))))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status %d, want 413", rec.Code)
+	}
+
+	signals, err := h.db.GetRecentSignals(10)
+	if err != nil || len(signals) != 2 {
+		t.Fatalf("signals = %+v, err %v", signals, err)
+	}
+	for _, s := range signals {
+		if s.Strategy != "trend" && s.Strategy != "scalp" || strings.Contains(s.Payload, "token") {
+			t.Errorf("signal %+v", s)
+		}
+	}
+}
+
+func TestSignalAuditTrail(t *testing.T) {
+	h := newTestHandler(t)
+
+	body := `{"token":"secret","pair":"XBTUSD","type":"hold","volume":"1"}`
+	webhook := httptest.NewRecorder()
+	h.ServeHTTP(webhook, httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body)))
+
+	signals, err := h.db.GetRecentSignals(1)
+	if err != nil || len(signals) != 1 {
+		t.Fatalf("signals = %v, err %v", signals, err)
+	}
+	var resp WebhookResponse
+	if err := json.NewDecoder(webhook.Body).Decode(&resp); err != nil || resp.SignalID != signals[0].ID || resp.Status != signalRejected {
+		t.Fatalf("webhook response = %+v, err %v", resp, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/signals/{id}", h.HandleGetSignal)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/signals/"+strconv.FormatInt(signals[0].ID, 10), nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+
+	var detail SignalDetail
+	if err := json.NewDecoder(rec.Body).Decode(&detail); err != nil {
+		t.Fatal(err)
+	}
+	if detail.Signal.Status != signalRejected {
+		t.Fatalf("signal status = %q, want %q", detail.Signal.Status, signalRejected)
+	}
+	if len(detail.Steps) != 1 || detail.Steps[0].Step != stepValidation || detail.Steps[0].Status != "failed" {
+		t.Fatalf("steps = %+v", detail.Steps)
+	}
+}
+
+func TestPausedSkipsOrders(t *testing.T) {
+	h := newTestHandler(t)
+	if _, err := h.cmdPause(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"token":"secret","pair":"XBTUSD","type":"buy","volume":"1"}`
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body)))
+
+	signals, err := h.db.GetRecentSignals(1)
+	if err != nil || len(signals) != 1 {
+		t.Fatalf("signals = %v, err %v", signals, err)
+	}
+	if signals[0].Status != signalSkipped {
+		t.Fatalf("signal status = %q, want %q", signals[0].Status, signalSkipped)
+	}
+	steps, err := h.db.GetSignalSteps(signals[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := steps[len(steps)-1]
+	if last.Step != stepOrderRequest || last.Status != "skipped" || !strings.Contains(string(last.Detail), "trading paused") {
+		t.Fatalf("last step = %+v", last)
+	}
+
+	if reply, _ := h.cmdStatus(nil); !strings.Contains(reply, "paused") {
+		t.Errorf("status = %q", reply)
+	}
+	h.cmdResume(nil)
+	if h.Paused() {
+		t.Error("still paused after /resume")
+	}
+}
+
+func TestConfirmation(t *testing.T) {
+	h := newTestHandler(t)
+	h.SetConfirmation(42, []string{"manual"}, time.Minute)
+
+	var edits []string
+	var sendFails bool
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if strings.HasSuffix(r.URL.Path, "/editMessageText") {
+			edits = append(edits, r.Form.Get("text"))
+		}
+		if sendFails && strings.HasSuffix(r.URL.Path, "/sendMessage") {
+			w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"result":{"message_id":7,"chat":{"id":42}}}`))
+	}))
+	defer api.Close()
+	tg := telegram.NewClient("123:abc")
+	tg.SetBaseURL(api.URL)
+	tg.ChatInterval = 0
+	bot := telegram.NewBot(tg)
+	bot.Allow([]int64{42}, nil)
+	h.RegisterCommands(bot)
+
+	newSignal := func() int64 {
+		req := &WebhookRequest{Strategy: "manual", Pair: "XBTUSD", Type: "buy", Volume: "1"}
+		id, err := h.db.SaveSignal(req.Pair, req.Type, req.Strategy, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := h.processOrder(id, req); result.Status != signalPending {
+			t.Fatalf("status = %q, want %q", result.Status, signalPending)
+		}
+		h.setSignalStatus(id, signalPending)
+		order, err := h.db.GetPendingOrderBySignal(id)
+		if err != nil || order == nil || order.MessageID != 7 || order.ChatID != 42 {
+			t.Fatalf("pending order = %+v, err %v", order, err)
+		}
+		return id
+	}
+	press := func(data string) {
+		bot.HandleUpdate(telegram.Update{CallbackQuery: &telegram.CallbackQuery{
+			ID: "q", Data: data, From: &telegram.User{ID: 1, Username: "alice"},
+			Message: &telegram.Message{MessageID: 7, Chat: telegram.Chat{ID: 42}},
+		}})
+	}
+	status := func(signalID int64) (string, *database.PendingOrder) {
+		s, err := h.db.GetSignal(signalID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		order, err := h.db.GetPendingOrderBySignal(signalID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.Status, order
+	}
+
+	// Modify, then approve: without a Kraken client the approved order is skipped.
+	approved := newSignal()
+	order, _ := h.db.GetPendingOrderBySignal(approved)
+	id := strconv.FormatInt(order.ID, 10)
+	if _, err := h.cmdModify([]string{id, "volume=0.5", "price=100"}); err != nil {
+		t.Fatal(err)
+	}
+	press("approve:" + id)
+	signalStatus, order := status(approved)
+	if signalStatus != signalSkipped || order.Status != database.PendingStatusApproved || order.DecidedBy != "@alice" {
+		t.Fatalf("after approve: signal %q, order %+v", signalStatus, order)
+	}
+	var req WebhookRequest
+	json.Unmarshal(order.Request, &req)
+	if req.Volume != "0.5" || req.Price != "100" {
+		t.Errorf("modified request = %+v", req)
+	}
+	press("reject:" + id) // Already decided
+	if _, order := status(approved); order.Status != database.PendingStatusApproved {
+		t.Errorf("decided order changed to %q", order.Status)
+	}
+
+	rejected := newSignal()
+	order, _ = h.db.GetPendingOrderBySignal(rejected)
+	press("reject:" + strconv.FormatInt(order.ID, 10))
+	if signalStatus, order := status(rejected); signalStatus != signalDeclined || order.Status != database.PendingStatusRejected {
+		t.Fatalf("after reject: signal %q, order %+v", signalStatus, order)
+	}
+
+	expired := newSignal()
+	if _, err := h.db.Exec("UPDATE pending_orders SET expires_at = ? WHERE signal_id = ?", time.Now().Add(-time.Second).UTC(), expired); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.ExpirePendingOrders(); err != nil {
+		t.Fatal(err)
+	}
+	if signalStatus, order := status(expired); signalStatus != signalExpired || order.Status != database.PendingStatusExpired {
+		t.Fatalf("after expiry: signal %q, order %+v", signalStatus, order)
+	}
+
+	if len(edits) != 4 { // Modify, approve, reject, expire
+		t.Errorf("edited messages = %q", edits)
+	}
+
+	// A confirmation request that cannot be sent fails the order with a message for the other channels.
+	sendFails = true
+	req = WebhookRequest{Strategy: "manual", Pair: "XBTUSD", Type: "buy", Volume: "1"}
+	failed, err := h.db.SaveSignal(req.Pair, req.Type, req.Strategy, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := h.processOrder(failed, &req)
+	if result.Status != signalFailed || !strings.Contains(result.Message, "chat not found") {
+		t.Fatalf("result = %+v", result)
+	}
+	if _, order := status(failed); order.Status != database.PendingStatusRejected {
+		t.Errorf("pending order status = %q, want rejected", order.Status)
+	}
+}
+
+// notifyRecorder is a notification channel that records the events it receives.
+type notifyRecorder []notify.Event
+
+func (r *notifyRecorder) Notify(ctx context.Context, e notify.Event) error {
+	*r = append(*r, e)
+	return nil
+}
+
+func TestNotifications(t *testing.T) {
+	h := newTestHandler(t)
+	var events notifyRecorder
+	router := notify.NewRouter()
+	router.Add("test", &events)
+	h.SetNotifier(router)
+
+	body := `{"token":"secret","text":"go long","pair":"XBTUSD","type":"hold","volume":"1"}`
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body)))
+
+	if len(events) != 2 || events[0].Type != notify.EventSignal || events[1].Type != notify.EventOrderFailed || events[1].Severity != notify.Warning {
+		t.Fatalf("events = %+v", events)
+	}
+	steps, err := h.db.GetSignalSteps(events[0].SignalID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var delivered int
+	for _, st := range steps {
+		if st.Step == stepNotification && st.Status == "ok" && strings.Contains(string(st.Detail), `"channel":"test"`) {
+			delivered++
+		}
+	}
+	if delivered != 2 {
+		t.Errorf("steps = %+v", steps)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	h := newTestHandler(t)
+	if !h.beginOrder() {
+		t.Fatal("order refused before shutdown")
+	}
+
+	// Shutdown waits for the order in progress.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := h.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Shutdown with an order in progress = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	body := `{"token":"secret","pair":"XBTUSD","type":"buy","volume":"1"}`
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body)))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("webhook during shutdown: status %d", rec.Code)
+	}
+	if signals, _ := h.db.GetRecentSignals(1); len(signals) != 0 {
+		t.Errorf("signal stored during shutdown: %+v", signals)
+	}
+
+	h.inflight.Done()
+	if err := h.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
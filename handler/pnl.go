@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"tvwh2k/api"
+	"tvwh2k/database"
+)
+
+// pnlReport computes the PnL report with the cost method from the "method"
+// query parameter, falling back to the configured default.
+func (h *WebhookHandler) pnlReport(w http.ResponseWriter, r *http.Request) (*database.PnLReport, bool) {
+	if h.db == nil {
+		api.WriteError(w, r, http.StatusServiceUnavailable, "Database not initialized")
+		return nil, false
+	}
+
+	method := h.pnlMethod
+	if m := r.URL.Query().Get("method"); m != "" {
+		var err error
+		if method, err = database.ParseCostMethod(m); err != nil {
+			api.WriteError(w, r, http.StatusBadRequest, err.Error())
+			return nil, false
+		}
+	}
+
+	report, err := h.db.ComputePnL(method)
+	if err != nil {
+		api.WriteInternalError(w, r, "Failed to compute PnL", err)
+		return nil, false
+	}
+	return report, true
+}
+
+// HandleGetPnL returns realized PnL aggregated by day, pair or strategy
+// (query parameter "group_by", default "day").
+func (h *WebhookHandler) HandleGetPnL(w http.ResponseWriter, r *http.Request) {
+	report, ok := h.pnlReport(w, r)
+	if !ok {
+		return
+	}
+
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy == "" {
+		groupBy = "day"
+	}
+	buckets, err := report.Aggregate(groupBy)
+	if err != nil {
+		api.WriteError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, map[string]interface{}{
+		"method":   report.Method,
+		"group_by": groupBy,
+		"buckets":  buckets,
+	})
+}
+
+// HandleGetPnLTrades returns the realized PnL of every closing execution.
+func (h *WebhookHandler) HandleGetPnLTrades(w http.ResponseWriter, r *http.Request) {
+	report, ok := h.pnlReport(w, r)
+	if !ok {
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, report.Realized)
+}
+
+// HandleGetPositions returns the open positions with unrealized PnL valued at the live ticker.
+func (h *WebhookHandler) HandleGetPositions(w http.ResponseWriter, r *http.Request) {
+	report, ok := h.pnlReport(w, r)
+	if !ok {
+		return
+	}
+
+	if h.krakenClient != nil {
+		prices := make(map[string]float64)
+		for _, p := range report.Positions {
+			if _, ok := prices[p.Pair]; ok {
+				continue
+			}
+			price, err := h.krakenClient.LastPrice(p.Pair)
+			if err != nil {
+				fmt.Printf("Failed to fetch price for %s: %v\n", p.Pair, err)
+				continue
+			}
+			prices[p.Pair] = price
+		}
+		report.ApplyPrices(prices)
+	}
+
+	api.WriteJSON(w, http.StatusOK, report.Positions)
+}
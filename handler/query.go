@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"tvwh2k/api"
+	"tvwh2k/database"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// parseFilter reads the filter and pagination query parameters shared by the list endpoints:
+// pair, type, status, strategy, from, to (RFC 3339 or unix seconds), cursor, limit and order (asc/desc).
+func parseFilter(r *http.Request) (database.Filter, error) {
+	q := r.URL.Query()
+	f := database.Filter{
+		Pair:     q.Get("pair"),
+		Type:     q.Get("type"),
+		Status:   q.Get("status"),
+		Strategy: q.Get("strategy"),
+		Limit:    defaultPageSize,
+	}
+
+	var err error
+	if f.From, err = parseTime(q.Get("from")); err != nil {
+		return f, fmt.Errorf("invalid from: %w", err)
+	}
+	if f.To, err = parseTime(q.Get("to")); err != nil {
+		return f, fmt.Errorf("invalid to: %w", err)
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		if f.Cursor, err = strconv.ParseInt(v, 10, 64); err != nil || f.Cursor < 0 {
+			return f, fmt.Errorf("invalid cursor %q", v)
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit <= 0 {
+			return f, fmt.Errorf("invalid limit %q", v)
+		}
+		if f.Limit > maxPageSize {
+			f.Limit = maxPageSize
+		}
+	}
+
+	switch q.Get("order") {
+	case "", "desc":
+	case "asc":
+		f.Ascending = true
+	default:
+		return f, fmt.Errorf("invalid order %q, want asc or desc", q.Get("order"))
+	}
+	return f, nil
+}
+
+// parseTime parses an RFC 3339 timestamp, a date (YYYY-MM-DD) or unix seconds. Empty means no bound.
+func parseTime(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+// setNextCursor tells the client where the next page starts. No header means this was the last page.
+func setNextCursor(w http.ResponseWriter, next int64) {
+	if next != 0 {
+		w.Header().Set("X-Next-Cursor", strconv.FormatInt(next, 10))
+	}
+}
+
+// HandleGetTrade returns a single trade with its fills and status history, looked up by numeric ID or by exchange txid.
+func (h *WebhookHandler) HandleGetTrade(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		api.WriteError(w, r, http.StatusServiceUnavailable, "Database not initialized")
+		return
+	}
+
+	key := r.PathValue("id")
+	var trade *database.Trade
+	var err error
+	if id, perr := strconv.ParseInt(key, 10, 64); perr == nil {
+		trade, err = h.db.GetTrade(id)
+	} else {
+		trade, err = h.db.GetTradeByTxID(key)
+		if err == nil && trade != nil {
+			trade.Fills, err = h.db.GetFills(trade.ID)
+		}
+	}
+	if err == nil && trade != nil {
+		trade.Events, err = h.db.GetOrderEvents(trade.ID)
+	}
+	if err != nil {
+		api.WriteInternalError(w, r, "Failed to fetch trade", err)
+		return
+	}
+	if trade == nil {
+		api.WriteError(w, r, http.StatusNotFound, "Trade not found")
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, trade)
+}
@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"tvwh2k/api"
+)
+
+// HandleGetEquity returns the equity snapshots between from and to (both optional), oldest first.
+func (h *WebhookHandler) HandleGetEquity(w http.ResponseWriter, r *http.Request) {
+	if h.db == nil {
+		api.WriteError(w, r, http.StatusServiceUnavailable, "Database not initialized")
+		return
+	}
+
+	from, err := parseTime(r.URL.Query().Get("from"))
+	if err != nil {
+		api.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("invalid from: %v", err))
+		return
+	}
+	to, err := parseTime(r.URL.Query().Get("to"))
+	if err != nil {
+		api.WriteError(w, r, http.StatusBadRequest, fmt.Sprintf("invalid to: %v", err))
+		return
+	}
+
+	snapshots, err := h.db.GetEquitySnapshots(from, to)
+	if err != nil {
+		api.WriteInternalError(w, r, "Failed to fetch equity", err)
+		return
+	}
+
+	api.WriteJSON(w, http.StatusOK, snapshots)
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"context"
+	"errors"
+)
+
+var errShuttingDown = errors.New("shutting down, try again after the restart")
+
+// beginOrder registers order processing that Shutdown waits for. It reports false
+// once shutdown has begun; otherwise the caller must call h.inflight.Done when finished.
+func (h *WebhookHandler) beginOrder() bool {
+	h.lifecycleMu.Lock()
+	defer h.lifecycleMu.Unlock()
+	if h.closing {
+		return false
+	}
+	h.inflight.Add(1)
+	return true
+}
+
+// Shutdown stops accepting webhooks and order commands and waits until the orders
+// being processed are complete, or until ctx is done.
+func (h *WebhookHandler) Shutdown(ctx context.Context) error {
+	h.lifecycleMu.Lock()
+	h.closing = true
+	h.lifecycleMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"strconv"
 	// We gaan ervan uit dat de benodigde response types zoals
 	// BalanceResponse, TradeBalanceResponse, en de helper GenericResponse
 	// gedefinieerd zijn in het bestand types.go binnen dezelfde package.
@@ -99,22 +100,23 @@ func (k *Kraken) GetTradeBalance(optionalAsset string) (*TradeBalanceResponse, e
 	return &tradeBalanceResult, nil
 }
 
-/*
-// Je kunt hier meer functies toevoegen voor andere account-gerelateerde endpoints:
-// Bijvoorbeeld:
-func (k *Kraken) GetLedgers(params LedgersInput) (*LedgersResponse, error) {
-    path := krakenPrivatePathPrefix + "Ledgers"
-    // Converteer LedgersInput naar url.Values
-    urlParams := // ... conversie logica ...
-    respBytes, err := k.doRequest("POST", path, urlParams)
-    // ... response parsing ...
+// GetExtendedBalance haalt de uitgebreide balans op voor alle valuta, inclusief
+// het bedrag dat vastgehouden wordt voor open orders.
+// Deze methode correspondeert met het Kraken API endpoint: /0/private/BalanceEx
+func (k *Kraken) GetExtendedBalance() (map[string]ExtendedBalance, error) {
+	var balances map[string]ExtendedBalance
+	if err := k.privateCall("BalanceEx", url.Values{}, &balances); err != nil {
+		return nil, err
+	}
+	return balances, nil
 }
 
-func (k *Kraken) QueryLedgers(ledgerIDs []string) (*LedgersResponse, error) {
-    path := krakenPrivatePathPrefix + "QueryLedgers"
-    params := url.Values{}
-    params.Set("id", strings.Join(ledgerIDs, ",")) // IDs gescheiden door komma's
-    respBytes, err := k.doRequest("POST", path, params)
-    // ... response parsing ...
+// Available retourneert het vrij besteedbare bedrag: balans plus beschikbaar krediet
+// min het gebruikte krediet en het bedrag dat vastgehouden wordt voor open orders.
+func (b ExtendedBalance) Available() float64 {
+	balance, _ := strconv.ParseFloat(b.Balance, 64)
+	credit, _ := strconv.ParseFloat(b.Credit, 64)
+	creditUsed, _ := strconv.ParseFloat(b.CreditUsed, 64)
+	hold, _ := strconv.ParseFloat(b.HoldTrade, 64)
+	return balance + credit - creditUsed - hold
 }
-*/
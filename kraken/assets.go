@@ -0,0 +1,22 @@
+package kraken
+
+import "strings"
+
+// legacyAssets are Kraken asset IDs with an X (crypto) or Z (fiat) prefix.
+var legacyAssets = map[string]bool{
+	"XXBT": true, "XETH": true, "XLTC": true, "XXRP": true, "XXLM": true, "XXMR": true,
+	"XETC": true, "XREP": true, "XZEC": true, "XXDG": true, "XMLN": true,
+	"ZUSD": true, "ZEUR": true, "ZGBP": true, "ZCAD": true, "ZJPY": true, "ZAUD": true, "ZCHF": true,
+}
+
+// AssetCode converts a Kraken asset ID to its short code as used in pair names:
+// "XXBT" becomes "XBT", "ZEUR" becomes "EUR" and staked variants like "DOT.S" become "DOT".
+func AssetCode(asset string) string {
+	if i := strings.Index(asset, "."); i > 0 {
+		asset = asset[:i]
+	}
+	if legacyAssets[asset] {
+		return asset[1:]
+	}
+	return asset
+}
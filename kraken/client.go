@@ -6,15 +6,14 @@ import (
 	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
-	// Je hebt hier mogelijk "encoding/json" nodig als je besluit
-	// de basis Kraken error check ({ "error": [...] }) al in doRequest te doen.
-	// Voor nu laten we dat over aan de aanroepende functies (AddOrder, GetBalance etc).
 )
 
 const (
@@ -24,6 +23,8 @@ const (
 	krakenAPIVersionPath = "/0"
 	// krakenPrivatePathPrefix is the complete path prefix for private V0 endpoints.
 	krakenPrivatePathPrefix = krakenAPIVersionPath + "/private/"
+	// krakenPublicPathPrefix is the complete path prefix for public V0 endpoints.
+	krakenPublicPathPrefix = krakenAPIVersionPath + "/public/"
 	// defaultTimeout specifies the default timeout for HTTP requests.
 	defaultTimeout = 20 * time.Second
 )
@@ -33,7 +34,12 @@ const (
 type Kraken struct {
 	apiKey     string       // Kraken API Key.
 	apiSecret  string       // Kraken API Secret (Base64 encoded version).
+	baseURL    string       // Base URL of the API, krakenAPIBaseURL unless overridden.
 	httpClient *http.Client // The HTTP client used to make requests.
+
+	minMarginLevel atomic.Uint64   // math.Float64bits of the minimum margin level (percent) required to place new orders. 0 disables the guard.
+	withdrawKeys   map[string]bool // Withdrawal keys that Withdraw and WithdrawInfo may use.
+	lastNonce      atomic.Int64    // Nonce of the latest private call; Kraken rejects nonces that do not increase.
 }
 
 // NewClient initializes and returns a new Kraken API client.
@@ -56,12 +62,25 @@ func NewClient(apiKey, apiSecret string) (*Kraken, error) {
 	return &Kraken{
 		apiKey:    apiKey,
 		apiSecret: apiSecret,
+		baseURL:   krakenAPIBaseURL,
 		httpClient: &http.Client{
 			Timeout: defaultTimeout, // Gebruik een redelijke timeout.
 		},
 	}, nil
 }
 
+// nextNonce returns a nonce greater than all earlier ones, also for concurrent calls
+// and when the clock goes back: the current time in nanoseconds or the last nonce plus one.
+func (k *Kraken) nextNonce() int64 {
+	for {
+		last := k.lastNonce.Load()
+		nonce := max(last+1, time.Now().UnixNano())
+		if k.lastNonce.CompareAndSwap(last, nonce) {
+			return nonce
+		}
+	}
+}
+
 // generateSignature creates the API-Sign header value according to Kraken's specifications.
 // This function is intended for internal use within the package.
 // path: The full API endpoint path (e.g., "/0/private/AddOrder").
@@ -111,7 +130,7 @@ func (k *Kraken) doRequest(method, path string, params url.Values) ([]byte, erro
 	if !strings.HasPrefix(path, krakenPrivatePathPrefix) {
 		return nil, fmt.Errorf("internal logic error: path '%s' does not match private endpoint prefix '%s'", path, krakenPrivatePathPrefix)
 	}
-	fullURL := krakenAPIBaseURL + path
+	fullURL := k.baseURL + path
 
 	// Zorg dat params nooit nil is, voorkomt nil pointer dereference.
 	if params == nil {
@@ -119,7 +138,7 @@ func (k *Kraken) doRequest(method, path string, params url.Values) ([]byte, erro
 	}
 
 	// Genereer een unieke nonce (altijd nodig voor private calls).
-	nonce := fmt.Sprintf("%d", time.Now().UnixNano())
+	nonce := fmt.Sprintf("%d", k.nextNonce())
 	params.Set("nonce", nonce)
 
 	// Encodeer de parameters naar application/x-www-form-urlencoded formaat.
@@ -185,3 +204,70 @@ func (k *Kraken) SetHttpClient(client *http.Client) {
 		k.httpClient = client
 	}
 }
+
+// SetBaseURL overrides the API base URL, e.g. to point the client at a local test server.
+func (k *Kraken) SetBaseURL(baseURL string) {
+	if baseURL != "" {
+		k.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// privateCall performs a request to the private endpoint with the given name
+// (e.g. "OpenPositions") and decodes the 'result' field into result.
+// Errors reported by Kraken in the 'error' field are returned as *APIError.
+func (k *Kraken) privateCall(endpoint string, params url.Values, result interface{}) error {
+	body, err := k.doRequest("POST", krakenPrivatePathPrefix+endpoint, params)
+	if err != nil {
+		return fmt.Errorf("kraken request for %s failed: %w", endpoint, err)
+	}
+	if err := parseKrakenError(body); err != nil {
+		return err
+	}
+
+	var genericResp GenericResponse
+	if err := json.Unmarshal(body, &genericResp); err != nil {
+		return fmt.Errorf("failed to unmarshal %s response: %w", endpoint, err)
+	}
+	if result == nil || genericResp.Result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(genericResp.Result, result); err != nil {
+		return fmt.Errorf("failed to unmarshal %s result: %w", endpoint, err)
+	}
+	return nil
+}
+
+// publicCall performs an unauthenticated GET request to the public endpoint with the
+// given name (e.g. "Ticker") and decodes the 'result' field into result.
+func (k *Kraken) publicCall(endpoint string, params url.Values, result interface{}) error {
+	fullURL := k.baseURL + krakenPublicPathPrefix + endpoint
+	if len(params) > 0 {
+		fullURL += "?" + params.Encode()
+	}
+
+	res, err := k.httpClient.Get(fullURL)
+	if err != nil {
+		return fmt.Errorf("kraken request for %s failed: %w", endpoint, err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body from %s: %w", endpoint, err)
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("received non-2xx HTTP status %d from %s: %s", res.StatusCode, endpoint, string(body))
+	}
+	if err := parseKrakenError(body); err != nil {
+		return err
+	}
+
+	var genericResp GenericResponse
+	if err := json.Unmarshal(body, &genericResp); err != nil {
+		return fmt.Errorf("failed to unmarshal %s response: %w", endpoint, err)
+	}
+	if err := json.Unmarshal(genericResp.Result, result); err != nil {
+		return fmt.Errorf("failed to unmarshal %s result: %w", endpoint, err)
+	}
+	return nil
+}
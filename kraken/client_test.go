@@ -0,0 +1,42 @@
+package kraken
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNextNonceIncreases(t *testing.T) {
+	k, err := NewClient("key", "c2VjcmV0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent calls get distinct nonces.
+	const n = 1000
+	nonces := make(chan int64, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nonces <- k.nextNonce()
+		}()
+	}
+	wg.Wait()
+	close(nonces)
+	seen := make(map[int64]bool)
+	for nonce := range nonces {
+		if seen[nonce] {
+			t.Fatalf("nonce %d returned twice", nonce)
+		}
+		seen[nonce] = true
+	}
+
+	// A nonce ahead of the clock, e.g. after it was set back, still increases.
+	ahead := time.Now().Add(time.Hour).UnixNano()
+	k.lastNonce.Store(ahead)
+	if got := k.nextNonce(); got != ahead+1 {
+		t.Errorf("nextNonce = %d, want %d", got, ahead+1)
+	}
+}
@@ -0,0 +1,91 @@
+package kraken
+
+import (
+	"net/url"
+)
+
+// EarnStrategies lists the Earn strategies available for the given asset
+// (all assets if empty).
+func (k *Kraken) EarnStrategies(asset string) ([]EarnStrategy, error) {
+	var all []EarnStrategy
+	cursor := ""
+	for {
+		params := url.Values{}
+		if asset != "" {
+			params.Set("asset", asset)
+		}
+		if cursor != "" {
+			params.Set("cursor", cursor)
+		}
+
+		var resp EarnStrategiesResponse
+		if err := k.privateCall("Earn/Strategies", params, &resp); err != nil {
+			return nil, err
+		}
+		all = append(all, resp.Items...)
+		if resp.NextCursor == "" || len(resp.Items) == 0 {
+			return all, nil
+		}
+		cursor = resp.NextCursor
+	}
+}
+
+// EarnAllocate allocates amount to the Earn strategy. Allocation is asynchronous;
+// use EarnAllocateStatus to check whether it has completed.
+func (k *Kraken) EarnAllocate(strategyID, amount string) error {
+	return k.earnMove("Earn/Allocate", strategyID, amount)
+}
+
+// EarnDeallocate deallocates amount from the Earn strategy. Deallocation is asynchronous;
+// use EarnDeallocateStatus to check whether it has completed.
+func (k *Kraken) EarnDeallocate(strategyID, amount string) error {
+	return k.earnMove("Earn/Deallocate", strategyID, amount)
+}
+
+func (k *Kraken) earnMove(endpoint, strategyID, amount string) error {
+	params := url.Values{}
+	params.Set("strategy_id", strategyID)
+	params.Set("amount", amount)
+
+	var ok bool
+	return k.privateCall(endpoint, params, &ok)
+}
+
+// EarnAllocateStatus reports whether the last allocation to the strategy is still pending.
+func (k *Kraken) EarnAllocateStatus(strategyID string) (*EarnStatusResponse, error) {
+	return k.earnStatus("Earn/AllocateStatus", strategyID)
+}
+
+// EarnDeallocateStatus reports whether the last deallocation from the strategy is still pending.
+func (k *Kraken) EarnDeallocateStatus(strategyID string) (*EarnStatusResponse, error) {
+	return k.earnStatus("Earn/DeallocateStatus", strategyID)
+}
+
+func (k *Kraken) earnStatus(endpoint, strategyID string) (*EarnStatusResponse, error) {
+	params := url.Values{}
+	params.Set("strategy_id", strategyID)
+
+	var resp EarnStatusResponse
+	if err := k.privateCall(endpoint, params, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// EarnAllocations lists the current Earn allocations. Totals are converted to
+// convertedAsset (default "USD" if empty).
+func (k *Kraken) EarnAllocations(convertedAsset string, hideZero bool) (*EarnAllocationsResponse, error) {
+	params := url.Values{}
+	if convertedAsset != "" {
+		params.Set("converted_asset", convertedAsset)
+	}
+	if hideZero {
+		params.Set("hide_zero_allocations", "true")
+	}
+
+	var resp EarnAllocationsResponse
+	if err := k.privateCall("Earn/Allocations", params, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
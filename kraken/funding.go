@@ -0,0 +1,219 @@
+package kraken
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrWithdrawKeyNotAllowed is returned when a withdrawal uses a key that is not on the allowlist.
+var ErrWithdrawKeyNotAllowed = errors.New("withdrawal key not in allowlist")
+
+// SetWithdrawAllowlist configures the withdrawal keys (as named in the Kraken
+// account) that Withdraw and WithdrawInfo may use. With an empty allowlist all
+// withdrawals are refused.
+func (k *Kraken) SetWithdrawAllowlist(keys []string) {
+	k.withdrawKeys = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			k.withdrawKeys[key] = true
+		}
+	}
+}
+
+func (k *Kraken) checkWithdrawKey(key string) error {
+	if !k.withdrawKeys[key] {
+		return fmt.Errorf("%w: %q", ErrWithdrawKeyNotAllowed, key)
+	}
+	return nil
+}
+
+// DepositMethods retrieves the methods available for depositing the given asset.
+func (k *Kraken) DepositMethods(asset string) ([]DepositMethod, error) {
+	params := url.Values{}
+	params.Set("asset", asset)
+
+	var methods []DepositMethod
+	if err := k.privateCall("DepositMethods", params, &methods); err != nil {
+		return nil, err
+	}
+	return methods, nil
+}
+
+// DepositAddresses retrieves (or, if generateNew is true, generates) deposit addresses
+// for the given asset and method.
+func (k *Kraken) DepositAddresses(asset, method string, generateNew bool) ([]DepositAddress, error) {
+	params := url.Values{}
+	params.Set("asset", asset)
+	params.Set("method", method)
+	if generateNew {
+		params.Set("new", "true")
+	}
+
+	var addresses []DepositAddress
+	if err := k.privateCall("DepositAddresses", params, &addresses); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// DepositStatus retrieves the status of recent deposits. Asset and method are optional filters.
+func (k *Kraken) DepositStatus(asset, method string) ([]TransferStatus, error) {
+	return k.transferStatus("DepositStatus", asset, method)
+}
+
+// WithdrawStatus retrieves the status of recent withdrawals. Asset and method are optional filters.
+func (k *Kraken) WithdrawStatus(asset, method string) ([]TransferStatus, error) {
+	return k.transferStatus("WithdrawStatus", asset, method)
+}
+
+func (k *Kraken) transferStatus(endpoint, asset, method string) ([]TransferStatus, error) {
+	params := url.Values{}
+	if asset != "" {
+		params.Set("asset", asset)
+	}
+	if method != "" {
+		params.Set("method", method)
+	}
+
+	var statuses []TransferStatus
+	if err := k.privateCall(endpoint, params, &statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// WithdrawInfo retrieves fee information and limits for withdrawing amount of asset
+// to the withdrawal key. The key must be on the allowlist.
+func (k *Kraken) WithdrawInfo(asset, key, amount string) (*WithdrawInfoResponse, error) {
+	if err := k.checkWithdrawKey(key); err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("asset", asset)
+	params.Set("key", key)
+	params.Set("amount", amount)
+
+	var info WithdrawInfoResponse
+	if err := k.privateCall("WithdrawInfo", params, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Withdraw withdraws amount of asset to the withdrawal key and returns the reference ID.
+// The key must be on the allowlist.
+func (k *Kraken) Withdraw(asset, key, amount string) (string, error) {
+	if err := k.checkWithdrawKey(key); err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("asset", asset)
+	params.Set("key", key)
+	params.Set("amount", amount)
+
+	var resp RefIDResponse
+	if err := k.privateCall("Withdraw", params, &resp); err != nil {
+		return "", err
+	}
+	return resp.RefID, nil
+}
+
+// WithdrawCancel requests the cancellation of a pending withdrawal.
+// Returns true if the cancellation was accepted.
+func (k *Kraken) WithdrawCancel(asset, refID string) (bool, error) {
+	params := url.Values{}
+	params.Set("asset", asset)
+	params.Set("refid", refID)
+
+	var cancelled bool
+	if err := k.privateCall("WithdrawCancel", params, &cancelled); err != nil {
+		return false, err
+	}
+	return cancelled, nil
+}
+
+// WalletTransfer transfers amount of asset between wallets, e.g. from
+// "Spot Wallet" to "Futures Wallet". Returns the reference ID.
+func (k *Kraken) WalletTransfer(asset, from, to, amount string) (string, error) {
+	params := url.Values{}
+	params.Set("asset", asset)
+	params.Set("from", from)
+	params.Set("to", to)
+	params.Set("amount", amount)
+
+	var resp RefIDResponse
+	if err := k.privateCall("WalletTransfer", params, &resp); err != nil {
+		return "", err
+	}
+	return resp.RefID, nil
+}
+
+// SweepInput defines the parameters of a Sweep.
+type SweepInput struct {
+	Asset     string  // Asset to sweep, as named in the balance (e.g., "ZEUR").
+	Key       string  // Withdrawal key to sweep to. Must be on the allowlist.
+	Profit    float64 // Realized profit in Asset that has not been swept yet, e.g. from the PnL of the trades.
+	Threshold float64 // Realized profit to keep on the account.
+	MinAmount float64 // Minimum amount worth sweeping; smaller excesses are left alone.
+	DryRun    bool    // If true, only report what would be withdrawn.
+}
+
+// SweepResult describes the outcome of a Sweep.
+type SweepResult struct {
+	Balance float64 // Balance of the asset before the sweep.
+	Amount  string  // Amount withdrawn (or that would be withdrawn in a dry run). Empty if nothing was swept.
+	Fee     string  // Withdrawal fee reported by WithdrawInfo.
+	RefID   string  // Reference ID of the withdrawal. Empty in a dry run.
+}
+
+// Sweep withdraws the realized profit above the threshold to an allowlisted withdrawal
+// key. The trading capital is never swept: the amount is capped at the balance and at
+// the current withdrawal limit.
+func (k *Kraken) Sweep(in SweepInput) (*SweepResult, error) {
+	if err := k.checkWithdrawKey(in.Key); err != nil {
+		return nil, err
+	}
+
+	balances, err := k.GetBalance()
+	if err != nil {
+		return nil, err
+	}
+	balance, err := strconv.ParseFloat((*balances)[in.Asset], 64)
+	if err != nil {
+		return nil, fmt.Errorf("no balance for asset %s", in.Asset)
+	}
+
+	result := &SweepResult{Balance: balance}
+	excess := math.Floor(min(in.Profit-in.Threshold, balance)*1e8) / 1e8
+	if excess <= 0 || excess < in.MinAmount {
+		return result, nil
+	}
+	amount := strconv.FormatFloat(excess, 'f', -1, 64)
+
+	info, err := k.WithdrawInfo(in.Asset, in.Key, amount)
+	if err != nil {
+		return nil, err
+	}
+	if limit, err := strconv.ParseFloat(info.Limit, 64); err == nil && limit < excess {
+		amount = info.Limit
+	}
+	result.Amount = amount
+	result.Fee = info.Fee
+
+	if in.DryRun {
+		return result, nil
+	}
+
+	refID, err := k.Withdraw(in.Asset, in.Key, amount)
+	if err != nil {
+		return nil, err
+	}
+	result.RefID = refID
+	return result, nil
+}
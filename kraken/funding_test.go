@@ -0,0 +1,66 @@
+package kraken
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestWithdrawRequiresAllowlistedKey(t *testing.T) {
+	k := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL.Path)
+	})
+	k.SetWithdrawAllowlist([]string{"cold-wallet"})
+
+	if _, err := k.Withdraw("XXBT", "someone-else", "1"); !errors.Is(err, ErrWithdrawKeyNotAllowed) {
+		t.Fatalf("expected ErrWithdrawKeyNotAllowed, got %v", err)
+	}
+}
+
+func TestSweepWithdrawsProfitAboveThreshold(t *testing.T) {
+	var withdrawn string
+	k := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/0/private/Balance":
+			w.Write([]byte(`{"error":[],"result":{"ZEUR":"1250.5"}}`))
+		case "/0/private/WithdrawInfo":
+			w.Write([]byte(`{"error":[],"result":{"method":"SEPA","limit":"10000","amount":"250","fee":"0.5"}}`))
+		case "/0/private/Withdraw":
+			withdrawn = r.PostForm.Get("amount")
+			w.Write([]byte(`{"error":[],"result":{"refid":"AGBSO6T-UFMTTQ-I7KGS6"}}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	})
+	k.SetWithdrawAllowlist([]string{"bank"})
+
+	res, err := k.Sweep(SweepInput{Asset: "ZEUR", Key: "bank", Profit: 1350.5, Threshold: 1100, MinAmount: 100})
+	if err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	if withdrawn != "250.5" || res.RefID != "AGBSO6T-UFMTTQ-I7KGS6" {
+		t.Fatalf("withdrew %q, refid %q", withdrawn, res.RefID)
+	}
+}
+
+func TestSweepKeepsCapital(t *testing.T) {
+	k := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/0/private/Balance":
+			w.Write([]byte(`{"error":[],"result":{"ZEUR":"5000"}}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	})
+	k.SetWithdrawAllowlist([]string{"bank"})
+
+	// A large balance is trading capital, not profit.
+	res, err := k.Sweep(SweepInput{Asset: "ZEUR", Key: "bank", Profit: 80, Threshold: 50, MinAmount: 100, DryRun: true})
+	if err != nil {
+		t.Fatalf("Sweep: %v", err)
+	}
+	if res.Amount != "" {
+		t.Fatalf("swept %s of a 30 profit", res.Amount)
+	}
+}
@@ -0,0 +1,199 @@
+package kraken
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyPageSize is the number of entries Kraken returns per Ledgers/TradesHistory page.
+const historyPageSize = 50
+
+// maxQueryIDs is the maximum number of IDs accepted by QueryLedgers and QueryTrades per call.
+const maxQueryIDs = 20
+
+// LedgersInput defines the filters for the Ledgers call.
+type LedgersInput struct {
+	Assets     []string  // Optional list of assets to restrict output to (e.g., "XXBT", "ZEUR").
+	AssetClass string    // Optional asset class (default "currency").
+	Type       string    // Optional entry type (e.g., "trade", "deposit", "withdrawal", "staking"). Default "all".
+	Start      time.Time // Optional start of the time range (exclusive).
+	End        time.Time // Optional end of the time range (inclusive).
+	Offset     int       // Result offset for pagination.
+}
+
+func (in LedgersInput) values() url.Values {
+	params := url.Values{}
+	if len(in.Assets) > 0 {
+		params.Set("asset", strings.Join(in.Assets, ","))
+	}
+	if in.AssetClass != "" {
+		params.Set("aclass", in.AssetClass)
+	}
+	if in.Type != "" {
+		params.Set("type", in.Type)
+	}
+	setTimeRange(params, in.Start, in.End, in.Offset)
+	return params
+}
+
+// TradesHistoryInput defines the filters for the TradesHistory call.
+type TradesHistoryInput struct {
+	Type   string    // Optional trade type (e.g., "all", "any position", "closed position", "no position"). Default "all".
+	Trades bool      // Whether to include trades related to position in output.
+	Start  time.Time // Optional start of the time range (exclusive).
+	End    time.Time // Optional end of the time range (inclusive).
+	Offset int       // Result offset for pagination.
+}
+
+func (in TradesHistoryInput) values() url.Values {
+	params := url.Values{}
+	if in.Type != "" {
+		params.Set("type", in.Type)
+	}
+	if in.Trades {
+		params.Set("trades", "true")
+	}
+	setTimeRange(params, in.Start, in.End, in.Offset)
+	return params
+}
+
+// setTimeRange adds the start, end and ofs parameters shared by the history endpoints.
+func setTimeRange(params url.Values, start, end time.Time, offset int) {
+	if !start.IsZero() {
+		params.Set("start", strconv.FormatInt(start.Unix(), 10))
+	}
+	if !end.IsZero() {
+		params.Set("end", strconv.FormatInt(end.Unix(), 10))
+	}
+	if offset > 0 {
+		params.Set("ofs", strconv.Itoa(offset))
+	}
+}
+
+// Ledgers retrieves a single page (up to 50 entries) of ledger entries matching the input.
+func (k *Kraken) Ledgers(in LedgersInput) (*LedgersResponse, error) {
+	var resp LedgersResponse
+	if err := k.privateCall("Ledgers", in.values(), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AllLedgers retrieves all ledger entries matching the input by paging over ofs,
+// starting at in.Offset.
+func (k *Kraken) AllLedgers(in LedgersInput) (map[string]LedgerEntry, error) {
+	all := make(map[string]LedgerEntry)
+	for {
+		page, err := k.Ledgers(in)
+		if err != nil {
+			return nil, fmt.Errorf("ledgers at offset %d: %w", in.Offset, err)
+		}
+		for id, entry := range page.Ledger {
+			all[id] = entry
+		}
+		in.Offset += historyPageSize
+		if len(page.Ledger) == 0 || in.Offset >= page.Count {
+			return all, nil
+		}
+	}
+}
+
+// QueryLedgers retrieves ledger entries by ID. More than 20 IDs are split over multiple calls.
+func (k *Kraken) QueryLedgers(ledgerIDs []string) (map[string]LedgerEntry, error) {
+	all := make(map[string]LedgerEntry)
+	for _, batch := range batchIDs(ledgerIDs) {
+		params := url.Values{}
+		params.Set("id", strings.Join(batch, ","))
+
+		var resp map[string]LedgerEntry
+		if err := k.privateCall("QueryLedgers", params, &resp); err != nil {
+			return nil, err
+		}
+		for id, entry := range resp {
+			all[id] = entry
+		}
+	}
+	return all, nil
+}
+
+// TradesHistory retrieves a single page (up to 50 trades) of trade history matching the input.
+func (k *Kraken) TradesHistory(in TradesHistoryInput) (*TradesHistoryResponse, error) {
+	var resp TradesHistoryResponse
+	if err := k.privateCall("TradesHistory", in.values(), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AllTradesHistory retrieves all trades matching the input by paging over ofs,
+// starting at in.Offset.
+func (k *Kraken) AllTradesHistory(in TradesHistoryInput) (map[string]TradeInfo, error) {
+	all := make(map[string]TradeInfo)
+	for {
+		page, err := k.TradesHistory(in)
+		if err != nil {
+			return nil, fmt.Errorf("trades history at offset %d: %w", in.Offset, err)
+		}
+		for id, trade := range page.Trades {
+			all[id] = trade
+		}
+		in.Offset += historyPageSize
+		if len(page.Trades) == 0 || in.Offset >= page.Count {
+			return all, nil
+		}
+	}
+}
+
+// QueryTrades retrieves trades by trade ID. More than 20 IDs are split over multiple calls.
+func (k *Kraken) QueryTrades(tradeIDs []string) (map[string]TradeInfo, error) {
+	all := make(map[string]TradeInfo)
+	for _, batch := range batchIDs(tradeIDs) {
+		params := url.Values{}
+		params.Set("txid", strings.Join(batch, ","))
+
+		var resp map[string]TradeInfo
+		if err := k.privateCall("QueryTrades", params, &resp); err != nil {
+			return nil, err
+		}
+		for id, trade := range resp {
+			all[id] = trade
+		}
+	}
+	return all, nil
+}
+
+// TradeVolume retrieves the 30 day trade volume and the fee tiers for the given pairs.
+func (k *Kraken) TradeVolume(pairs []string) (*TradeVolumeResponse, error) {
+	params := url.Values{}
+	if len(pairs) > 0 {
+		params.Set("pair", strings.Join(pairs, ","))
+	}
+
+	var resp TradeVolumeResponse
+	if err := k.privateCall("TradeVolume", params, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// batchIDs splits ids into chunks of at most maxQueryIDs.
+func batchIDs(ids []string) [][]string {
+	var batches [][]string
+	for len(ids) > maxQueryIDs {
+		batches = append(batches, ids[:maxQueryIDs])
+		ids = ids[maxQueryIDs:]
+	}
+	if len(ids) > 0 {
+		batches = append(batches, ids)
+	}
+	return batches
+}
+
+// Timestamp converts a Kraken unix timestamp with fractional seconds to a time.Time.
+func Timestamp(ts float64) time.Time {
+	sec := int64(ts)
+	return time.Unix(sec, int64((ts-float64(sec))*1e9)).UTC()
+}
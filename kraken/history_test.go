@@ -0,0 +1,50 @@
+package kraken
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAllLedgersPaginates(t *testing.T) {
+	const total = 120
+	var offsets []string
+	k := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("start") != "1700000000" {
+			t.Errorf("start = %q", r.PostForm.Get("start"))
+		}
+		offsets = append(offsets, r.PostForm.Get("ofs"))
+
+		ofs, _ := strconv.Atoi(r.PostForm.Get("ofs"))
+		w.Write([]byte(`{"error":[],"result":{"count":` + strconv.Itoa(total) + `,"ledger":{`))
+		for i := ofs; i < ofs+historyPageSize && i < total; i++ {
+			if i > ofs {
+				w.Write([]byte(","))
+			}
+			fmt.Fprintf(w, `"L%d":{"refid":"T%d","type":"trade","asset":"XXBT","amount":"0.1"}`, i, i)
+		}
+		w.Write([]byte(`}}}`))
+	})
+
+	entries, err := k.AllLedgers(LedgersInput{Start: time.Unix(1700000000, 0)})
+	if err != nil {
+		t.Fatalf("AllLedgers: %v", err)
+	}
+	if len(entries) != total {
+		t.Fatalf("got %d entries, want %d", len(entries), total)
+	}
+	if want := []string{"", "50", "100"}; fmt.Sprint(offsets) != fmt.Sprint(want) {
+		t.Fatalf("offsets = %v, want %v", offsets, want)
+	}
+}
+
+func TestBatchIDs(t *testing.T) {
+	ids := make([]string, 45)
+	batches := batchIDs(ids)
+	if len(batches) != 3 || len(batches[0]) != 20 || len(batches[2]) != 5 {
+		t.Fatalf("unexpected batches: %d", len(batches))
+	}
+}
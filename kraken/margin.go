@@ -0,0 +1,153 @@
+package kraken
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrMarginLevelTooLow is returned by CheckMarginLevel when the account margin
+// level is below the configured minimum.
+var ErrMarginLevelTooLow = errors.New("margin level below configured minimum")
+
+// ErrInvalidLeverage is returned for a leverage Kraken margin orders do not accept.
+var ErrInvalidLeverage = errors.New("invalid leverage")
+
+// SetMinMarginLevel configures the margin guard. When level is greater than zero,
+// CheckMarginLevel fails while the account margin level reported by TradeBalance
+// is below level percent; callers run it before orders that add exposure. It may
+// be changed while orders are placed.
+func (k *Kraken) SetMinMarginLevel(level float64) {
+	k.minMarginLevel.Store(math.Float64bits(level))
+}
+
+// CheckMarginLevel returns an error wrapping ErrMarginLevelTooLow when the
+// current margin level is below the configured minimum. Kraken omits the margin
+// level when there are no open positions, which is treated as healthy.
+func (k *Kraken) CheckMarginLevel() error {
+	minLevel := math.Float64frombits(k.minMarginLevel.Load())
+	if minLevel <= 0 {
+		return nil
+	}
+
+	balance, err := k.GetTradeBalance("")
+	if err != nil {
+		return fmt.Errorf("margin guard: %w", err)
+	}
+	if balance.MarginLevel == "" {
+		return nil
+	}
+
+	level, err := strconv.ParseFloat(balance.MarginLevel, 64)
+	if err != nil {
+		return fmt.Errorf("margin guard: invalid margin level %q: %w", balance.MarginLevel, err)
+	}
+	if level < minLevel {
+		return fmt.Errorf("%w: %.2f%% < %.2f%%", ErrMarginLevelTooLow, level, minLevel)
+	}
+	return nil
+}
+
+// OpenPositions retrieves the open margin positions.
+// txids optionally restricts the result to the given position IDs.
+// If docalcs is true, Kraken includes the current value and unrealized PnL.
+func (k *Kraken) OpenPositions(txids []string, docalcs bool) (OpenPositionsResponse, error) {
+	params := url.Values{}
+	if len(txids) > 0 {
+		params.Set("txid", strings.Join(txids, ","))
+	}
+	if docalcs {
+		params.Set("docalcs", "true")
+	}
+
+	var positions OpenPositionsResponse
+	if err := k.privateCall("OpenPositions", params, &positions); err != nil {
+		return nil, err
+	}
+	return positions, nil
+}
+
+// ClosePosition closes the remaining volume of an open margin position by placing
+// an opposite reduce-only market order with the leverage the position was opened with.
+// If validate is true, the closing order is only validated, not submitted.
+func (k *Kraken) ClosePosition(positionID string, validate bool) (*AddOrderResponse, error) {
+	positions, err := k.OpenPositions([]string{positionID}, false)
+	if err != nil {
+		return nil, err
+	}
+	pos, ok := positions[positionID]
+	if !ok {
+		return nil, fmt.Errorf("position %s not found", positionID)
+	}
+
+	order, err := pos.CloseOrder()
+	if err != nil {
+		return nil, fmt.Errorf("position %s: %w", positionID, err)
+	}
+	order.Validate = validate
+	return k.AddOrder(order)
+}
+
+// CloseOrder builds the reduce-only market order that closes the remaining volume of the position.
+func (p PositionInfo) CloseOrder() (OrderInput, error) {
+	vol, err := strconv.ParseFloat(p.Vol, 64)
+	if err != nil {
+		return OrderInput{}, fmt.Errorf("invalid volume %q: %w", p.Vol, err)
+	}
+	closed, _ := strconv.ParseFloat(p.VolClosed, 64)
+	remaining := vol - closed
+	if remaining <= 0 {
+		return OrderInput{}, fmt.Errorf("no remaining volume to close")
+	}
+
+	leverage, err := p.Leverage()
+	if err != nil {
+		return OrderInput{}, err
+	}
+
+	side := "sell"
+	if p.Type == "sell" {
+		side = "buy"
+	}
+
+	return OrderInput{
+		Pair:       p.Pair,
+		Type:       side,
+		OrderType:  "market",
+		Volume:     strconv.FormatFloat(remaining, 'f', -1, 64),
+		Leverage:   leverage,
+		ReduceOnly: true,
+	}, nil
+}
+
+// Leverage derives the leverage of the position from its cost and initial margin.
+// Kraken does not return the leverage directly. A leverage below 2 is an error rather
+// than being rounded up, as closing with it would not match the position.
+func (p PositionInfo) Leverage() (string, error) {
+	cost, err1 := strconv.ParseFloat(p.Cost, 64)
+	margin, err2 := strconv.ParseFloat(p.Margin, 64)
+	if err1 != nil || err2 != nil || margin <= 0 {
+		return "", fmt.Errorf("cannot derive leverage from cost %q and margin %q", p.Cost, p.Margin)
+	}
+	lev := strconv.Itoa(int(cost/margin + 0.5))
+	if err := CheckLeverage(lev); err != nil {
+		return "", err
+	}
+	return lev, nil
+}
+
+// CheckLeverage checks the leverage of a margin order, e.g. "3" or "3:1". Kraken margin
+// orders need a leverage of at least 2; spot orders have none.
+func CheckLeverage(leverage string) error {
+	n, err := strconv.ParseFloat(strings.TrimSuffix(leverage, ":1"), 64)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidLeverage, leverage)
+	}
+	if n < 2 {
+		return fmt.Errorf("%w: %q is below 2", ErrInvalidLeverage, leverage)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package kraken
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Kraken {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	k, err := NewClient("key", "c2VjcmV0")
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	k.SetBaseURL(server.URL)
+	return k
+}
+
+func TestCheckMarginLevel(t *testing.T) {
+	k := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/0/private/TradeBalance" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"error":[],"result":{"e":"1000","ml":"120.5"}}`))
+	})
+	if err := k.CheckMarginLevel(); err != nil {
+		t.Fatalf("no minimum configured: %v", err)
+	}
+
+	k.SetMinMarginLevel(150)
+	if err := k.CheckMarginLevel(); !errors.Is(err, ErrMarginLevelTooLow) {
+		t.Fatalf("expected ErrMarginLevelTooLow, got %v", err)
+	}
+	k.SetMinMarginLevel(100)
+	if err := k.CheckMarginLevel(); err != nil {
+		t.Fatalf("margin level above minimum: %v", err)
+	}
+}
+
+func TestClosePosition(t *testing.T) {
+	k := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/0/private/OpenPositions":
+			w.Write([]byte(`{"error":[],"result":{"TPOS1":{"pair":"XXBTZUSD","type":"buy","vol":"1.5","vol_closed":"0.5","cost":"30000","margin":"10000"}}}`))
+		case "/0/private/AddOrder":
+			want := map[string]string{"pair": "XXBTZUSD", "type": "sell", "ordertype": "market", "volume": "1", "leverage": "3", "reduce_only": "true"}
+			for key, v := range want {
+				if got := r.PostForm.Get(key); got != v {
+					t.Errorf("%s = %q, want %q", key, got, v)
+				}
+			}
+			w.Write([]byte(`{"error":[],"result":{"descr":{"order":"sell 1 XBTUSD @ market"},"txid":["OCLOSE"]}}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	})
+
+	resp, err := k.ClosePosition("TPOS1", false)
+	if err != nil {
+		t.Fatalf("ClosePosition: %v", err)
+	}
+	if len(resp.TxID) != 1 || resp.TxID[0] != "OCLOSE" {
+		t.Fatalf("unexpected txid %v", resp.TxID)
+	}
+}
+
+func TestAddOrderRejectsLeverageBelowTwo(t *testing.T) {
+	k := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL.Path)
+	})
+
+	for _, leverage := range []string{"1", "1:1", "0.5", "x"} {
+		_, err := k.AddOrder(OrderInput{Pair: "XBTUSD", Type: "buy", OrderType: "market", Volume: "1", Leverage: leverage})
+		if !errors.Is(err, ErrInvalidLeverage) {
+			t.Errorf("leverage %q: expected ErrInvalidLeverage, got %v", leverage, err)
+		}
+	}
+	if err := CheckLeverage("5:1"); err != nil {
+		t.Errorf("5:1: %v", err)
+	}
+}
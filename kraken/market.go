@@ -0,0 +1,119 @@
+package kraken
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ticker retrieves ticker information for the given pairs. The result is keyed
+// by Kraken's canonical pair name (e.g. "XXBTZUSD" for "XBT/USD").
+func (k *Kraken) Ticker(pairs ...string) (map[string]TickerInfo, error) {
+	params := url.Values{}
+	params.Set("pair", strings.Join(pairs, ","))
+
+	var tickers map[string]TickerInfo
+	if err := k.publicCall("Ticker", params, &tickers); err != nil {
+		return nil, err
+	}
+	return tickers, nil
+}
+
+// LastPrice returns the last traded price of a single pair.
+func (k *Kraken) LastPrice(pair string) (float64, error) {
+	tickers, err := k.Ticker(pair)
+	if err != nil {
+		return 0, err
+	}
+	for _, t := range tickers {
+		if len(t.LastTrade) == 0 {
+			break
+		}
+		return strconv.ParseFloat(t.LastTrade[0], 64)
+	}
+	return 0, fmt.Errorf("no ticker for pair %s", pair)
+}
+
+// AssetPairs retrieves information about the given tradable pairs (all pairs if none are given).
+// The result is keyed by Kraken's canonical pair name.
+func (k *Kraken) AssetPairs(pairs ...string) (map[string]AssetPairInfo, error) {
+	params := url.Values{}
+	if len(pairs) > 0 {
+		params.Set("pair", strings.Join(pairs, ","))
+	}
+
+	var info map[string]AssetPairInfo
+	if err := k.publicCall("AssetPairs", params, &info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// OHLC retrieves candles of interval minutes (1, 5, 15, 30, 60, 240, 1440, 10080 or 21600)
+// for a single pair, oldest first. Kraken returns at most the last 720 candles; since
+// (optional) only returns candles after that time.
+func (k *Kraken) OHLC(pair string, interval int, since time.Time) ([]Candle, error) {
+	params := url.Values{}
+	params.Set("pair", pair)
+	params.Set("interval", strconv.Itoa(interval))
+	if !since.IsZero() {
+		params.Set("since", strconv.FormatInt(since.Unix(), 10))
+	}
+
+	// The result holds the candles keyed by pair name next to a "last" cursor.
+	var result map[string]json.RawMessage
+	if err := k.publicCall("OHLC", params, &result); err != nil {
+		return nil, err
+	}
+	for name, raw := range result {
+		if name == "last" {
+			continue
+		}
+		var rows [][]interface{}
+		if err := json.Unmarshal(raw, &rows); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal OHLC candles: %w", err)
+		}
+		candles := make([]Candle, 0, len(rows))
+		for _, row := range rows {
+			c, err := parseCandle(row)
+			if err != nil {
+				return nil, err
+			}
+			candles = append(candles, c)
+		}
+		return candles, nil
+	}
+	return nil, fmt.Errorf("no OHLC data for pair %s", pair)
+}
+
+// parseCandle parses an OHLC row: [time, open, high, low, close, vwap, volume, count].
+func parseCandle(row []interface{}) (Candle, error) {
+	var c Candle
+	if len(row) < 8 {
+		return c, fmt.Errorf("invalid OHLC row %v", row)
+	}
+	ts, ok := row[0].(float64)
+	if !ok {
+		return c, fmt.Errorf("invalid OHLC time %v", row[0])
+	}
+	c.Time = time.Unix(int64(ts), 0).UTC()
+
+	for i, dst := range []*float64{&c.Open, &c.High, &c.Low, &c.Close, &c.VWAP, &c.Volume} {
+		s, ok := row[i+1].(string)
+		if !ok {
+			return c, fmt.Errorf("invalid OHLC value %v", row[i+1])
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return c, fmt.Errorf("invalid OHLC value %q: %w", s, err)
+		}
+		*dst = v
+	}
+	if n, ok := row[7].(float64); ok {
+		c.Count = int(n)
+	}
+	return c, nil
+}
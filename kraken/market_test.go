@@ -0,0 +1,31 @@
+package kraken
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestOHLC(t *testing.T) {
+	k := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/0/public/OHLC" || r.URL.Query().Get("interval") != "1440" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`{"error":[],"result":{"XXBTZEUR":[
+			[1704067200,"38000.0","39000.0","37500.0","38500.5","38200.1","120.5",3000],
+			[1704153600,"38500.5","40000.0","38400.0","39800.0","39100.0","98.25",2500]
+		],"last":1704153600}}`))
+	})
+
+	candles, err := k.OHLC("XBTEUR", 1440, time.Time{})
+	if err != nil {
+		t.Fatalf("OHLC: %v", err)
+	}
+	if len(candles) != 2 {
+		t.Fatalf("got %d candles, want 2", len(candles))
+	}
+	c := candles[0]
+	if !c.Time.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || c.Close != 38500.5 || c.Volume != 120.5 || c.Count != 3000 {
+		t.Errorf("unexpected candle %+v", c)
+	}
+}
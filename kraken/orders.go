@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"strings"
+	"time"
 )
 
 // AddOrder submits a new order to the Kraken API.
@@ -29,6 +31,24 @@ func (k *Kraken) AddOrder(order OrderInput) (*AddOrderResponse, error) {
 	if order.TimeInForce != "" {
 		params.Set("timeinforce", order.TimeInForce)
 	}
+	if order.Leverage != "" {
+		if err := CheckLeverage(order.Leverage); err != nil {
+			return nil, err
+		}
+		params.Set("leverage", order.Leverage)
+	}
+	if order.ReduceOnly {
+		params.Set("reduce_only", "true")
+	}
+	if order.StartTm != "" {
+		params.Set("starttm", order.StartTm)
+	}
+	if order.ExpireTm != "" {
+		params.Set("expiretm", order.ExpireTm)
+	}
+	if order.Deadline != "" {
+		params.Set("deadline", order.Deadline)
+	}
 	if order.Validate {
 		params.Set("validate", "true")
 	}
@@ -66,3 +86,72 @@ func (k *Kraken) AddOrder(order OrderInput) (*AddOrderResponse, error) {
 
 	return &addOrderResp, nil
 }
+
+// QueryOrders retrieves orders by transaction ID. If trades is true, the trade IDs
+// of each order are included. More than 50 IDs are split over multiple calls.
+func (k *Kraken) QueryOrders(txids []string, trades bool) (map[string]OrderInfo, error) {
+	all := make(map[string]OrderInfo)
+	for len(txids) > 0 {
+		batch := txids
+		if len(batch) > 50 {
+			batch = batch[:50]
+		}
+		txids = txids[len(batch):]
+
+		params := url.Values{}
+		params.Set("txid", strings.Join(batch, ","))
+		if trades {
+			params.Set("trades", "true")
+		}
+
+		var resp map[string]OrderInfo
+		if err := k.privateCall("QueryOrders", params, &resp); err != nil {
+			return nil, err
+		}
+		for id, order := range resp {
+			all[id] = order
+		}
+	}
+	return all, nil
+}
+
+// OpenOrders retrieves all open orders. If trades is true, the trade IDs of each order are included.
+func (k *Kraken) OpenOrders(trades bool) (map[string]OrderInfo, error) {
+	params := url.Values{}
+	if trades {
+		params.Set("trades", "true")
+	}
+
+	var resp OpenOrdersResponse
+	if err := k.privateCall("OpenOrders", params, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Open, nil
+}
+
+// ClosedOrders retrieves a single page (up to 50 orders) of orders closed after start.
+func (k *Kraken) ClosedOrders(start time.Time, offset int, trades bool) (*ClosedOrdersResponse, error) {
+	params := url.Values{}
+	if trades {
+		params.Set("trades", "true")
+	}
+	setTimeRange(params, start, time.Time{}, offset)
+
+	var resp ClosedOrdersResponse
+	if err := k.privateCall("ClosedOrders", params, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CancelOrder cancels an open order by transaction ID (or user reference).
+func (k *Kraken) CancelOrder(txid string) (*CancelOrderResponse, error) {
+	params := url.Values{}
+	params.Set("txid", txid)
+
+	var resp CancelOrderResponse
+	if err := k.privateCall("CancelOrder", params, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
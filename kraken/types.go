@@ -3,8 +3,7 @@ package kraken
 
 import (
 	"encoding/json"
-	// No other standard library imports typically needed here,
-	// unless you use time.Time and need custom marshalling.
+	"time"
 )
 
 // --- Generic Response Handling ---
@@ -25,18 +24,22 @@ type GenericResponse struct {
 // Note: This struct serves to structure input data; it's converted to url.Values,
 // so json tags aren't used for submission but are included for clarity/potential other uses.
 type OrderInput struct {
-	Pair        string `json:"pair"`                  // Asset pair (e.g., "XBT/USD", "ETH/EUR")
-	Type        string `json:"type"`                  // Type of order: "buy" or "sell"
-	OrderType   string `json:"ordertype"`             // Order type (e.g., "market", "limit", "stop-loss", "take-profit", etc.)
-	Volume      string `json:"volume"`                // Order volume in base currency
-	Price       string `json:"price,omitempty"`       // Primary price (e.g., limit price) - optional depending on OrderType
-	Price2      string `json:"price2,omitempty"`      // Secondary price (e.g., stop loss price) - optional
-	UserRef     string `json:"userref,omitempty"`     // Optional user reference ID (should be parseable as int32)
-	OFlags      string `json:"oflags,omitempty"`      // Optional comma-delimited list of order flags (e.g., "fcib", "fciq", "nompp", "post")
-	TimeInForce string `json:"timeinforce,omitempty"` // Optional time-in-force policy (e.g., "GTC", "IOC", "GTD")
+	Pair        string            `json:"pair"`                  // Asset pair (e.g., "XBT/USD", "ETH/EUR")
+	Type        string            `json:"type"`                  // Type of order: "buy" or "sell"
+	OrderType   string            `json:"ordertype"`             // Order type (e.g., "market", "limit", "stop-loss", "take-profit", etc.)
+	Volume      string            `json:"volume"`                // Order volume in base currency
+	Price       string            `json:"price,omitempty"`       // Primary price (e.g., limit price) - optional depending on OrderType
+	Price2      string            `json:"price2,omitempty"`      // Secondary price (e.g., stop loss price) - optional
+	UserRef     string            `json:"userref,omitempty"`     // Optional user reference ID (should be parseable as int32)
+	OFlags      string            `json:"oflags,omitempty"`      // Optional comma-delimited list of order flags (e.g., "fcib", "fciq", "nompp", "post")
+	TimeInForce string            `json:"timeinforce,omitempty"` // Optional time-in-force policy (e.g., "GTC", "IOC", "GTD")
+	Leverage    string            `json:"leverage,omitempty"`    // Optional leverage for margin orders (e.g., "2", "5:1"). Empty means spot.
+	ReduceOnly  bool              `json:"reduce_only,omitempty"` // If true, the order can only reduce an open margin position.
+	StartTm     string            `json:"starttm,omitempty"`     // Optional scheduled start time: "0" (now), "+<n>" (seconds from now) or unix timestamp.
+	ExpireTm    string            `json:"expiretm,omitempty"`    // Optional expiration time, same format as StartTm.
+	Deadline    string            `json:"deadline,omitempty"`    // Optional RFC3339 timestamp after which the matching engine rejects the order.
 	Validate    bool              `json:"-"`                     // If true, only validate inputs, don't submit. Handled in AddOrder, not sent directly.
 	Close       map[string]string `json:"close,omitempty"`       // Conditional close order parameters (e.g. ordertype, price, price2)
-	// Add more fields as needed based on Kraken documentation (leverage, starttm, expiretm, etc.)
 }
 
 // AddOrderResponse defines the structure of the 'result' field returned by a successful AddOrder call.
@@ -49,6 +52,14 @@ type AddOrderResponse struct {
 type OrderDescription struct {
 	Order string `json:"order"`           // Textual description of the order (e.g., "buy 0.1 XBT/USD @ limit 50000").
 	Close string `json:"close,omitempty"` // Textual description of the conditional close order (if applicable).
+
+	// Structured fields, only returned when querying orders.
+	Pair      string `json:"pair,omitempty"`      // Asset pair.
+	Type      string `json:"type,omitempty"`      // Type of order: "buy" or "sell".
+	OrderType string `json:"ordertype,omitempty"` // Order type.
+	Price     string `json:"price,omitempty"`     // Primary price.
+	Price2    string `json:"price2,omitempty"`    // Secondary price.
+	Leverage  string `json:"leverage,omitempty"`  // Amount of leverage.
 }
 
 // CancelOrderResponse defines the structure of the 'result' field returned by CancelOrder.
@@ -77,41 +88,327 @@ type TradeBalanceResponse struct {
 	MarginLevel       string `json:"ml,omitempty"` // Margin level = (equity / initial margin) * 100.
 }
 
-// --- Other Common Types ---
+// --- Margin Types ---
 
-// Add structs for other endpoints as needed, for example:
-// - OpenOrdersResponse
-// - ClosedOrdersResponse
-// - TradesHistoryResponse
-// - LedgersResponse
-// - SystemStatusResponse
-// - AssetInfoResponse
-// - TradableAssetPairResponse
-// etc.
+// OpenPositionsResponse defines the structure of the 'result' field for the OpenPositions call.
+// It maps position IDs to their details.
+type OpenPositionsResponse map[string]PositionInfo
+
+// PositionInfo describes a single open margin position.
+type PositionInfo struct {
+	OrderTxID  string  `json:"ordertxid"`       // Order ID responsible for the position.
+	PosStatus  string  `json:"posstatus"`       // Position status (usually "open").
+	Pair       string  `json:"pair"`            // Asset pair (e.g., "XXBTZUSD").
+	Time       float64 `json:"time"`            // Unix timestamp of the trade that opened the position.
+	Type       string  `json:"type"`            // Direction of the position: "buy" (long) or "sell" (short).
+	OrderType  string  `json:"ordertype"`       // Order type used to open the position.
+	Cost       string  `json:"cost"`            // Opening cost of the position (quote currency).
+	Fee        string  `json:"fee"`             // Opening fee of the position (quote currency).
+	Vol        string  `json:"vol"`             // Position volume (base currency).
+	VolClosed  string  `json:"vol_closed"`      // Volume already closed (base currency).
+	Margin     string  `json:"margin"`          // Initial margin (quote currency).
+	Value      string  `json:"value,omitempty"` // Current value of remaining position (only with docalcs).
+	Net        string  `json:"net,omitempty"`   // Unrealized profit/loss of remaining position (only with docalcs).
+	Terms      string  `json:"terms"`           // Funding cost and term of the position.
+	RolloverTm string  `json:"rollovertm"`      // Unix timestamp of the next margin rollover fee.
+	Misc       string  `json:"misc"`            // Comma delimited list of miscellaneous info.
+	OFlags     string  `json:"oflags"`          // Comma delimited list of opening order flags.
+}
+
+// --- Account History Types ---
+
+// LedgersResponse defines the structure of the 'result' field for the Ledgers call.
+type LedgersResponse struct {
+	Ledger map[string]LedgerEntry `json:"ledger"` // Ledger entries keyed by ledger ID.
+	Count  int                    `json:"count"`  // Total number of entries matching the criteria.
+}
+
+// LedgerEntry describes a single ledger entry (a change to an asset balance).
+type LedgerEntry struct {
+	RefID   string  `json:"refid"`   // Reference ID of the trade, deposit, withdrawal etc.
+	Time    float64 `json:"time"`    // Unix timestamp of the ledger entry.
+	Type    string  `json:"type"`    // Type of entry (e.g., "trade", "deposit", "withdrawal", "staking").
+	SubType string  `json:"subtype"` // Additional info relating to the entry type.
+	AClass  string  `json:"aclass"`  // Asset class.
+	Asset   string  `json:"asset"`   // Asset (e.g., "XXBT", "ZEUR").
+	Amount  string  `json:"amount"`  // Transaction amount.
+	Fee     string  `json:"fee"`     // Transaction fee.
+	Balance string  `json:"balance"` // Resulting balance.
+}
+
+// TradesHistoryResponse defines the structure of the 'result' field for the TradesHistory call.
+type TradesHistoryResponse struct {
+	Trades map[string]TradeInfo `json:"trades"` // Trades keyed by trade ID.
+	Count  int                  `json:"count"`  // Total number of trades matching the criteria.
+}
+
+// TradeInfo describes a single executed trade (fill).
+type TradeInfo struct {
+	OrderTxID string   `json:"ordertxid"`           // Order responsible for the trade.
+	PosTxID   string   `json:"postxid"`             // Position responsible for the trade.
+	Pair      string   `json:"pair"`                // Asset pair (e.g., "XXBTZUSD").
+	Time      float64  `json:"time"`                // Unix timestamp of the trade.
+	Type      string   `json:"type"`                // Direction: "buy" or "sell".
+	OrderType string   `json:"ordertype"`           // Order type.
+	Price     string   `json:"price"`               // Average price the order was executed at (quote currency).
+	Cost      string   `json:"cost"`                // Total cost of the order (quote currency).
+	Fee       string   `json:"fee"`                 // Total fee (quote currency).
+	Vol       string   `json:"vol"`                 // Volume (base currency).
+	Margin    string   `json:"margin"`              // Initial margin (quote currency).
+	Leverage  string   `json:"leverage,omitempty"`  // Amount of leverage used in the trade.
+	Misc      string   `json:"misc"`                // Comma delimited list of miscellaneous info.
+	Ledgers   []string `json:"ledgers,omitempty"`   // Ledger IDs related to the trade (only with ledgers=true).
+	TradeID   int64    `json:"trade_id"`            // Unique identifier of the trade per pair.
+	Maker     bool     `json:"maker"`               // True if the trade was a maker trade.
+	PosStatus string   `json:"posstatus,omitempty"` // Position status ("open" or "closed"), only for position trades.
+	CPrice    string   `json:"cprice,omitempty"`    // Average price of the closed portion of the position.
+	CCost     string   `json:"ccost,omitempty"`     // Total cost of the closed portion of the position.
+	CFee      string   `json:"cfee,omitempty"`      // Total fee of the closed portion of the position.
+	CVol      string   `json:"cvol,omitempty"`      // Total volume of the closed portion of the position.
+	CMargin   string   `json:"cmargin,omitempty"`   // Total margin freed in the closed portion of the position.
+	Net       string   `json:"net,omitempty"`       // Net profit/loss of the closed portion of the position.
+	Trades    []string `json:"trades,omitempty"`    // List of closing trades for the position.
+}
+
+// TradeVolumeResponse defines the structure of the 'result' field for the TradeVolume call.
+type TradeVolumeResponse struct {
+	Currency  string                 `json:"currency"`             // Volume currency.
+	Volume    string                 `json:"volume"`               // Current discount volume (30 days).
+	Fees      map[string]FeeTierInfo `json:"fees,omitempty"`       // Taker fee tier per requested pair.
+	FeesMaker map[string]FeeTierInfo `json:"fees_maker,omitempty"` // Maker fee tier per requested pair.
+}
+
+// FeeTierInfo describes the current and next fee tier of a pair. All fees are in percent.
+type FeeTierInfo struct {
+	Fee        string `json:"fee"`         // Current fee.
+	MinFee     string `json:"min_fee"`     // Minimum fee for the pair (if not fixed).
+	MaxFee     string `json:"max_fee"`     // Maximum fee for the pair (if not fixed).
+	NextFee    string `json:"next_fee"`    // Next tier's fee (empty if at lowest tier).
+	TierVolume string `json:"tier_volume"` // Volume level of the current tier.
+	NextVolume string `json:"next_volume"` // Volume level of the next tier (empty if at lowest tier).
+}
+
+// --- Funding Types ---
+
+// OptionalAmount is an amount that Kraken returns either as a string or as
+// false (e.g. "no limit"). False and null are decoded as an empty string.
+type OptionalAmount string
+
+// UnmarshalJSON implements json.Unmarshaler for OptionalAmount.
+func (a *OptionalAmount) UnmarshalJSON(data []byte) error {
+	if string(data) == "false" || string(data) == "null" {
+		*a = ""
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		// Some fields are returned as JSON numbers.
+		var n json.Number
+		if errNum := json.Unmarshal(data, &n); errNum != nil {
+			return err
+		}
+		s = n.String()
+	}
+	*a = OptionalAmount(s)
+	return nil
+}
+
+// DepositMethod describes a method available for depositing an asset.
+type DepositMethod struct {
+	Method          string         `json:"method"`                      // Name of the deposit method.
+	Limit           OptionalAmount `json:"limit"`                       // Maximum net amount that can be deposited right now (empty if unlimited).
+	Fee             string         `json:"fee,omitempty"`               // Amount of fees that will be paid.
+	AddressSetupFee string         `json:"address-setup-fee,omitempty"` // Whether or not method has an address setup fee.
+	GenAddress      bool           `json:"gen-address,omitempty"`       // Whether new addresses can be generated for this method.
+	Minimum         string         `json:"minimum,omitempty"`           // Minimum net amount that can be deposited.
+}
+
+// DepositAddress describes a deposit address for an asset and method.
+type DepositAddress struct {
+	Address  string `json:"address"`        // Deposit address.
+	ExpireTm string `json:"expiretm"`       // Expiration time in unix timestamp, or "0" if not expiring.
+	New      bool   `json:"new,omitempty"`  // Whether the address has ever been used.
+	Tag      string `json:"tag,omitempty"`  // Tag, required by some assets.
+	Memo     string `json:"memo,omitempty"` // Memo, required by some assets.
+}
+
+// TransferStatus describes the status of a recent deposit or withdrawal.
+type TransferStatus struct {
+	Method     string         `json:"method"`                // Name of the deposit/withdrawal method.
+	AClass     string         `json:"aclass"`                // Asset class.
+	Asset      string         `json:"asset"`                 // Asset.
+	RefID      string         `json:"refid"`                 // Reference ID.
+	TxID       string         `json:"txid"`                  // Method transaction ID.
+	Info       string         `json:"info"`                  // Method transaction information (e.g., address).
+	Amount     string         `json:"amount"`                // Amount.
+	Fee        OptionalAmount `json:"fee"`                   // Fees paid.
+	Time       int64          `json:"time"`                  // Unix timestamp when the request was made.
+	Status     string         `json:"status"`                // Status: "Initial", "Pending", "Settled", "Success" or "Failure".
+	StatusProp string         `json:"status-prop,omitempty"` // Additional status property (e.g., "cancel-pending", "onhold").
+	Key        string         `json:"key,omitempty"`         // Withdrawal key name (withdrawals only).
+}
+
+// WithdrawInfoResponse defines the structure of the 'result' field for the WithdrawInfo call.
+type WithdrawInfoResponse struct {
+	Method string `json:"method"` // Name of the withdrawal method that will be used.
+	Limit  string `json:"limit"`  // Maximum net amount that can be withdrawn right now.
+	Amount string `json:"amount"` // Net amount that will be sent, after fees.
+	Fee    string `json:"fee"`    // Amount of fees that will be paid.
+}
+
+// RefIDResponse defines the structure of the 'result' field for calls that only return a reference ID
+// (Withdraw, WalletTransfer).
+type RefIDResponse struct {
+	RefID string `json:"refid"`
+}
 
-// Example structure for Open Orders (you'd need to define OrderInfo)
-/*
+// ExtendedBalance describes the balance of a single asset as returned by BalanceEx.
+type ExtendedBalance struct {
+	Balance    string `json:"balance"`               // Total balance.
+	Credit     string `json:"credit,omitempty"`      // Available credit.
+	CreditUsed string `json:"credit_used,omitempty"` // Credit in use.
+	HoldTrade  string `json:"hold_trade"`            // Amount held for open orders.
+}
+
+// --- Market Data Types ---
+
+// TickerInfo describes the ticker of a single pair. Prices are [price, whole lot volume, lot volume]
+// for Ask and Bid, and [price, lot volume] for LastTrade.
+type TickerInfo struct {
+	Ask       []string `json:"a"` // Best ask.
+	Bid       []string `json:"b"` // Best bid.
+	LastTrade []string `json:"c"` // Last trade closed.
+	Volume    []string `json:"v"` // Volume [today, last 24 hours].
+	VWAP      []string `json:"p"` // Volume weighted average price [today, last 24 hours].
+	Trades    []int    `json:"t"` // Number of trades [today, last 24 hours].
+	Low       []string `json:"l"` // Low [today, last 24 hours].
+	High      []string `json:"h"` // High [today, last 24 hours].
+	Open      string   `json:"o"` // Today's opening price.
+}
+
+// --- Earn Types ---
+
+// EarnStrategiesResponse defines the structure of the 'result' field for the Earn/Strategies call.
+type EarnStrategiesResponse struct {
+	Items      []EarnStrategy `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// EarnStrategy describes an Earn strategy (e.g. flexible or bonded staking of an asset).
+type EarnStrategy struct {
+	ID                string `json:"id"`                  // Strategy ID used to allocate and deallocate.
+	Asset             string `json:"asset"`               // Asset the strategy earns on.
+	UserMinAllocation string `json:"user_min_allocation"` // Minimum amount a user can allocate.
+	AllocationFee     string `json:"allocation_fee"`      // Fee applied when allocating.
+	DeallocationFee   string `json:"deallocation_fee"`    // Fee applied when deallocating.
+	CanAllocate       bool   `json:"can_allocate"`        // Whether the user can allocate to this strategy.
+	CanDeallocate     bool   `json:"can_deallocate"`      // Whether the user can deallocate from this strategy.
+	LockType          struct {
+		Type            string `json:"type"`                       // "flex", "bonded", "timed" or "instant".
+		PayoutFrequency int64  `json:"payout_frequency,omitempty"` // Seconds between rewards payouts.
+		UnbondingPeriod int64  `json:"unbonding_period,omitempty"` // Seconds it takes to deallocate (bonded).
+	} `json:"lock_type"`
+	APREstimate *struct {
+		Low  string `json:"low"`
+		High string `json:"high"`
+	} `json:"apr_estimate,omitempty"`
+}
+
+// EarnStatusResponse defines the structure of the 'result' field for the Earn/AllocateStatus
+// and Earn/DeallocateStatus calls.
+type EarnStatusResponse struct {
+	Pending bool `json:"pending"` // True while the last (de)allocation request is still being processed.
+}
+
+// EarnAllocationsResponse defines the structure of the 'result' field for the Earn/Allocations call.
+type EarnAllocationsResponse struct {
+	ConvertedAsset string           `json:"converted_asset"` // Asset the totals are converted to.
+	TotalAllocated string           `json:"total_allocated"` // Total allocated across strategies in converted asset.
+	TotalRewarded  string           `json:"total_rewarded"`  // Total rewarded across strategies in converted asset.
+	Items          []EarnAllocation `json:"items"`
+}
+
+// EarnAllocation describes the allocation to a single strategy.
+type EarnAllocation struct {
+	StrategyID      string `json:"strategy_id"`
+	NativeAsset     string `json:"native_asset"`
+	AmountAllocated struct {
+		Total EarnAmount `json:"total"`
+	} `json:"amount_allocated"`
+	TotalRewarded EarnAmount `json:"total_rewarded"`
+}
+
+// EarnAmount is an amount in both the native and the converted asset.
+type EarnAmount struct {
+	Native    string `json:"native"`
+	Converted string `json:"converted"`
+}
+
+// --- Order Query Types ---
+
+// OpenOrdersResponse defines the structure of the 'result' field for the OpenOrders call.
 type OpenOrdersResponse struct {
-	Open map[string]OrderInfo `json:"open"`
+	Open map[string]OrderInfo `json:"open"` // Open orders keyed by transaction ID.
 }
 
+// ClosedOrdersResponse defines the structure of the 'result' field for the ClosedOrders call.
+type ClosedOrdersResponse struct {
+	Closed map[string]OrderInfo `json:"closed"` // Closed orders keyed by transaction ID.
+	Count  int                  `json:"count"`  // Total number of closed orders matching the criteria.
+}
+
+// OrderInfo describes an order as returned by OpenOrders, ClosedOrders and QueryOrders.
 type OrderInfo struct {
-	RefID    string            `json:"refid"` // Referral order transaction ID
-	UserRef  int32             `json:"userref"` // User reference ID
-	Status   string            `json:"status"`  // Status of order (e.g., "open", "pending")
-	OpenTm   float64           `json:"opentm"`  // Unix timestamp of when order was placed
-	StartTm  float64           `json:"starttm"` // Unix timestamp of order start time (0 if not set)
-	ExpireTm float64           `json:"expiretm"`// Unix timestamp of order end time (0 if not set)
-	Descr    OrderDescription  `json:"descr"`   // Order description info
-	Vol      string            `json:"vol"`     // Volume of order in base currency
-	VolExec  string            `json:"vol_exec"`// Volume executed in base currency
-	Cost     string            `json:"cost"`    // Total cost (quote currency)
-	Fee      string            `json:"fee"`     // Total fee (quote currency)
-	Price    string            `json:"price"`   // Average price executed (quote currency)
-	StopPrice string           `json:"stopprice"`// Stop price (quote currency)
-	LimitPrice string          `json:"limitprice"`// Triggered limit price (quote currency, for trailing stops)
-	Misc     string            `json:"misc"`    // Comma delimited list of miscellaneous info
-	OFlags   string            `json:"oflags"`  // Comma delimited list of order flags
-	// Potentially add Trades field: []string `json:"trades"` // List of trade IDs related to order
-}
-*/
+	RefID      string           `json:"refid"`            // Referral order transaction ID that created this order (e.g. the parent of a conditional close).
+	UserRef    int32            `json:"userref"`          // User reference ID
+	Status     string           `json:"status"`           // Status of order: "pending", "open", "closed", "canceled" or "expired"
+	OpenTm     float64          `json:"opentm"`           // Unix timestamp of when order was placed
+	CloseTm    float64          `json:"closetm"`          // Unix timestamp of when order was closed (closed orders only)
+	StartTm    float64          `json:"starttm"`          // Unix timestamp of order start time (0 if not set)
+	ExpireTm   float64          `json:"expiretm"`         // Unix timestamp of order end time (0 if not set)
+	Descr      OrderDescription `json:"descr"`            // Order description info
+	Vol        string           `json:"vol"`              // Volume of order in base currency
+	VolExec    string           `json:"vol_exec"`         // Volume executed in base currency
+	Cost       string           `json:"cost"`             // Total cost (quote currency)
+	Fee        string           `json:"fee"`              // Total fee (quote currency)
+	Price      string           `json:"price"`            // Average price executed (quote currency)
+	StopPrice  string           `json:"stopprice"`        // Stop price (quote currency)
+	LimitPrice string           `json:"limitprice"`       // Triggered limit price (quote currency, for trailing stops)
+	Misc       string           `json:"misc"`             // Comma delimited list of miscellaneous info
+	OFlags     string           `json:"oflags"`           // Comma delimited list of order flags
+	Reason     string           `json:"reason,omitempty"` // Additional info on status (e.g. why an order was canceled)
+	Trades     []string         `json:"trades,omitempty"` // List of trade IDs related to order (only with trades=true)
+}
+
+// --- Asset Pair Types ---
+
+// AssetPairInfo describes a tradable asset pair. Only the fields used by this application are included.
+type AssetPairInfo struct {
+	AltName      string `json:"altname"`       // Alternate pair name (e.g., "XBTUSD").
+	WSName       string `json:"wsname"`        // WebSocket pair name (e.g., "XBT/USD").
+	Base         string `json:"base"`          // Asset ID of the base component (e.g., "XXBT").
+	Quote        string `json:"quote"`         // Asset ID of the quote component (e.g., "ZUSD").
+	CostDecimals int    `json:"cost_decimals"` // Scaling decimal places for cost.
+	PairDecimals int    `json:"pair_decimals"` // Scaling decimal places for price.
+	LotDecimals  int    `json:"lot_decimals"`  // Scaling decimal places for volume.
+	OrderMin     string `json:"ordermin"`      // Minimum order size (in base currency).
+}
+
+// Candle is a single OHLC candle as returned by the OHLC call.
+type Candle struct {
+	Time   time.Time // Start of the candle.
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	VWAP   float64 // Volume weighted average price.
+	Volume float64
+	Count  int // Number of trades.
+}
+
+// --- Other Common Types ---
+
+// Add structs for other endpoints as needed, for example:
+// - SystemStatusResponse
+// - AssetInfoResponse
+// etc.
@@ -0,0 +1,35 @@
+package krakenfutures
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// OpenPositions retrieves all open futures positions.
+func (c *Client) OpenPositions() (*OpenPositionsResponse, error) {
+	resp, err := c.doRequest(http.MethodGet, "openpositions", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var positions OpenPositionsResponse
+	if err := json.Unmarshal(resp, &positions); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal openpositions response: %w", err)
+	}
+	return &positions, nil
+}
+
+// Accounts retrieves balances and margin information of all futures accounts.
+func (c *Client) Accounts() (*AccountsResponse, error) {
+	resp, err := c.doRequest(http.MethodGet, "accounts", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var accounts AccountsResponse
+	if err := json.Unmarshal(resp, &accounts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal accounts response: %w", err)
+	}
+	return &accounts, nil
+}
@@ -0,0 +1,159 @@
+// Package krakenfutures provides a client for interacting with the Kraken Futures REST API (v3).
+package krakenfutures
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// futuresAPIBaseURL is the base URL for the Kraken Futures API.
+	futuresAPIBaseURL = "https://futures.kraken.com"
+	// FuturesDemoBaseURL is the base URL of the Kraken Futures demo environment.
+	FuturesDemoBaseURL = "https://demo-futures.kraken.com"
+	// futuresPathPrefix is the path prefix under which the API is served.
+	futuresPathPrefix = "/derivatives"
+	// futuresAPIPath is the path prefix for v3 endpoints. It is the part of the
+	// path that is included in the Authent signature.
+	futuresAPIPath = "/api/v3/"
+	// defaultTimeout specifies the default timeout for HTTP requests.
+	defaultTimeout = 20 * time.Second
+)
+
+// Client holds the configuration and the HTTP client for making
+// authenticated requests to the Kraken Futures API.
+type Client struct {
+	apiKey     string       // Kraken Futures API Key.
+	apiSecret  string       // Kraken Futures API Secret (Base64 encoded version).
+	baseURL    string       // Base URL of the API, futuresAPIBaseURL unless overridden.
+	httpClient *http.Client // The HTTP client used to make requests.
+}
+
+// NewClient initializes and returns a new Kraken Futures API client.
+// It requires the API key and the Base64 encoded API secret.
+// Returns an error if keys are missing or the secret cannot be decoded.
+func NewClient(apiKey, apiSecret string) (*Client, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("API key cannot be empty")
+	}
+	if apiSecret == "" {
+		return nil, fmt.Errorf("API secret cannot be empty")
+	}
+	if _, err := base64.StdEncoding.DecodeString(apiSecret); err != nil {
+		return nil, fmt.Errorf("invalid base64 API secret: %w", err)
+	}
+
+	return &Client{
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		baseURL:   futuresAPIBaseURL,
+		httpClient: &http.Client{
+			Timeout: defaultTimeout,
+		},
+	}, nil
+}
+
+// SetBaseURL overrides the API base URL, e.g. FuturesDemoBaseURL or a local test server.
+func (c *Client) SetBaseURL(baseURL string) {
+	if baseURL != "" {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+}
+
+// SetHttpClient allows replacing the default HTTP client.
+func (c *Client) SetHttpClient(client *http.Client) {
+	if client != nil {
+		c.httpClient = client
+	}
+}
+
+// generateAuthent creates the Authent header value according to Kraken Futures' specifications:
+// Base64(HMAC-SHA512(Base64Decode(secret), SHA256(postData + nonce + endpointPath))).
+// endpointPath is the path without the "/derivatives" prefix (e.g., "/api/v3/sendorder").
+func (c *Client) generateAuthent(postData, nonce, endpointPath string) (string, error) {
+	secretBytes, err := base64.StdEncoding.DecodeString(c.apiSecret)
+	if err != nil {
+		return "", fmt.Errorf("internal error: could not decode API secret: %w", err)
+	}
+
+	hash := sha256.Sum256([]byte(postData + nonce + endpointPath))
+
+	mac := hmac.New(sha512.New, secretBytes)
+	if _, err := mac.Write(hash[:]); err != nil {
+		return "", fmt.Errorf("internal error: failed writing to hmac: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// doRequest performs an authenticated request to a v3 endpoint (e.g., "sendorder").
+// For GET requests the params are sent in the query string, for POST requests in the body.
+// In both cases the encoded params are part of the signed postData.
+// Returns the raw response body bytes and an error if any step fails.
+func (c *Client) doRequest(method, endpoint string, params url.Values) ([]byte, error) {
+	endpointPath := futuresAPIPath + endpoint
+	fullURL := c.baseURL + futuresPathPrefix + endpointPath
+
+	if params == nil {
+		params = url.Values{}
+	}
+	postData := params.Encode()
+	nonce := fmt.Sprintf("%d", time.Now().UnixNano())
+
+	var body io.Reader
+	if method == http.MethodGet {
+		if postData != "" {
+			fullURL += "?" + postData
+		}
+	} else {
+		body = strings.NewReader(postData)
+	}
+
+	req, err := http.NewRequest(method, fullURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new HTTP request for %s: %w", endpoint, err)
+	}
+
+	authent, err := c.generateAuthent(postData, nonce, endpointPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate Authent for %s: %w", endpoint, err)
+	}
+
+	if method != http.MethodGet {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("APIKey", c.apiKey)
+	req.Header.Set("Nonce", nonce)
+	req.Header.Set("Authent", authent)
+	req.Header.Set("User-Agent", "tvwh2k (Go Kraken Futures Client)")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request execution failed for %s: %w", endpoint, err)
+	}
+	defer res.Body.Close()
+
+	respBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body from %s: %w", endpoint, err)
+	}
+
+	// Kraken Futures reports API errors with 4xx statuses as well, so try to
+	// surface the structured error before falling back to the HTTP status.
+	if apiErr := parseFuturesError(respBody); apiErr != nil {
+		return nil, apiErr
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, fmt.Errorf("received non-2xx HTTP status %d from %s: %s", res.StatusCode, endpoint, string(respBody))
+	}
+
+	return respBody, nil
+}
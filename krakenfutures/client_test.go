@@ -0,0 +1,85 @@
+package krakenfutures
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testSecret = "c2VjcmV0LWtleS1mb3ItdGVzdHM="
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	c, err := NewClient("key", testSecret)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	c.SetBaseURL(server.URL)
+	return c
+}
+
+func TestSendOrderSignsRequest(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/derivatives/api/v3/sendorder" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		r.ParseForm()
+
+		secret, _ := base64.StdEncoding.DecodeString(testSecret)
+		hash := sha256.Sum256([]byte(r.PostForm.Encode() + r.Header.Get("Nonce") + "/api/v3/sendorder"))
+		mac := hmac.New(sha512.New, secret)
+		mac.Write(hash[:])
+		if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); r.Header.Get("Authent") != want {
+			t.Errorf("Authent = %q, want %q", r.Header.Get("Authent"), want)
+		}
+		if r.Header.Get("APIKey") != "key" {
+			t.Errorf("APIKey = %q", r.Header.Get("APIKey"))
+		}
+		if r.PostForm.Get("reduceOnly") != "true" {
+			t.Errorf("reduceOnly = %q", r.PostForm.Get("reduceOnly"))
+		}
+
+		w.Write([]byte(`{"result":"success","sendStatus":{"order_id":"abc-123","status":"placed"}}`))
+	})
+
+	resp, err := c.SendOrder(OrderInput{OrderType: "mkt", Symbol: "PF_XBTUSD", Side: "sell", Size: "1", ReduceOnly: true})
+	if err != nil {
+		t.Fatalf("SendOrder: %v", err)
+	}
+	if resp.SendStatus.OrderID != "abc-123" {
+		t.Fatalf("order_id = %q", resp.SendStatus.OrderID)
+	}
+}
+
+func TestSendOrderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		check func(error) bool
+	}{
+		{"api error", `{"result":"error","error":"authenticationError"}`, IsFuturesError},
+		{"rejected", `{"result":"success","sendStatus":{"status":"insufficientAvailableFunds"}}`, func(err error) bool {
+			var rejected *OrderRejectedError
+			return errors.As(err, &rejected) && rejected.Status == "insufficientAvailableFunds"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			})
+			_, err := c.SendOrder(OrderInput{OrderType: "mkt", Symbol: "PF_XBTUSD", Side: "buy", Size: "1"})
+			if !tt.check(err) {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+}
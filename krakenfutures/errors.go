@@ -0,0 +1,84 @@
+package krakenfutures
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// APIError represents an error returned by the Kraken Futures API, which
+// responds with {"result":"error","error":"<code>"} on failure.
+type APIError struct {
+	// Code is the error identifier, e.g. "apiLimitExceeded" or "authenticationError".
+	Code string
+	// Errors contains additional error messages, if any were returned.
+	Errors []string
+}
+
+// Error implements the standard Go error interface for APIError.
+func (e *APIError) Error() string {
+	msgs := e.Errors
+	if e.Code != "" {
+		msgs = append([]string{e.Code}, msgs...)
+	}
+	if len(msgs) == 0 {
+		return "unknown Kraken Futures API error occurred"
+	}
+	return fmt.Sprintf("kraken futures API error(s): %s", strings.Join(msgs, "; "))
+}
+
+// IsFuturesError checks if a given error is a Kraken Futures APIError.
+func IsFuturesError(err error) bool {
+	if err == nil {
+		return false
+	}
+	_, ok := err.(*APIError)
+	return ok
+}
+
+// OrderRejectedError is returned when the API accepted the request but did not
+// place or cancel the order, e.g. with status "insufficientAvailableFunds".
+type OrderRejectedError struct {
+	Status string
+}
+
+// Error implements the standard Go error interface for OrderRejectedError.
+func (e *OrderRejectedError) Error() string {
+	return fmt.Sprintf("kraken futures order rejected: %s", e.Status)
+}
+
+// parseFuturesError checks the raw response body for an API-level error.
+// It returns nil if the body is not a JSON error response.
+func parseFuturesError(body []byte) error {
+	var resp struct {
+		Result string          `json:"result"`
+		Error  string          `json:"error"`
+		Errors json.RawMessage `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil
+	}
+	if resp.Result != "error" && resp.Error == "" {
+		return nil
+	}
+
+	apiErr := &APIError{Code: resp.Error}
+	if len(resp.Errors) > 0 {
+		// "errors" is either a list of strings or a list of objects with a message.
+		var list []string
+		if err := json.Unmarshal(resp.Errors, &list); err == nil {
+			apiErr.Errors = list
+		} else {
+			var objs []struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(resp.Errors, &objs); err == nil {
+				for _, o := range objs {
+					apiErr.Errors = append(apiErr.Errors, o.Message)
+				}
+			}
+		}
+	}
+	return apiErr
+}
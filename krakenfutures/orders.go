@@ -0,0 +1,81 @@
+package krakenfutures
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// SendOrder submits a new order to the Kraken Futures API.
+// If the request succeeds but the order is not placed, the response is returned
+// together with an *OrderRejectedError.
+func (c *Client) SendOrder(order OrderInput) (*SendOrderResponse, error) {
+	params := url.Values{}
+	params.Set("orderType", order.OrderType)
+	params.Set("symbol", order.Symbol)
+	params.Set("side", order.Side)
+	params.Set("size", order.Size)
+
+	if order.LimitPrice != "" {
+		params.Set("limitPrice", order.LimitPrice)
+	}
+	if order.StopPrice != "" {
+		params.Set("stopPrice", order.StopPrice)
+	}
+	if order.CliOrdID != "" {
+		params.Set("cliOrdId", order.CliOrdID)
+	}
+	if order.TriggerSignal != "" {
+		params.Set("triggerSignal", order.TriggerSignal)
+	}
+	if order.ReduceOnly {
+		params.Set("reduceOnly", "true")
+	}
+
+	resp, err := c.doRequest(http.MethodPost, "sendorder", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var sendOrderResp SendOrderResponse
+	if err := json.Unmarshal(resp, &sendOrderResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sendorder response: %w", err)
+	}
+
+	if sendOrderResp.SendStatus.Status != "placed" {
+		return &sendOrderResp, &OrderRejectedError{Status: sendOrderResp.SendStatus.Status}
+	}
+	return &sendOrderResp, nil
+}
+
+// CancelOrder cancels an open order by its order ID.
+func (c *Client) CancelOrder(orderID string) (*CancelOrderResponse, error) {
+	params := url.Values{}
+	params.Set("order_id", orderID)
+	return c.cancelOrder(params)
+}
+
+// CancelOrderByCliOrdID cancels an open order by the client order ID it was placed with.
+func (c *Client) CancelOrderByCliOrdID(cliOrdID string) (*CancelOrderResponse, error) {
+	params := url.Values{}
+	params.Set("cliOrdId", cliOrdID)
+	return c.cancelOrder(params)
+}
+
+func (c *Client) cancelOrder(params url.Values) (*CancelOrderResponse, error) {
+	resp, err := c.doRequest(http.MethodPost, "cancelorder", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var cancelResp CancelOrderResponse
+	if err := json.Unmarshal(resp, &cancelResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cancelorder response: %w", err)
+	}
+
+	if cancelResp.CancelStatus.Status != "cancelled" {
+		return &cancelResp, &OrderRejectedError{Status: cancelResp.CancelStatus.Status}
+	}
+	return &cancelResp, nil
+}
@@ -0,0 +1,121 @@
+package krakenfutures
+
+// --- Order Management Types ---
+
+// OrderInput defines the parameters for placing a new order via SendOrder.
+type OrderInput struct {
+	OrderType     string `json:"orderType"`               // "lmt", "post", "ioc", "mkt", "stp", "take_profit" or "trailing_stop"
+	Symbol        string `json:"symbol"`                  // Contract symbol (e.g., "PF_XBTUSD")
+	Side          string `json:"side"`                    // "buy" or "sell"
+	Size          string `json:"size"`                    // Order size in contracts
+	LimitPrice    string `json:"limitPrice,omitempty"`    // Limit price, required for "lmt", "post", "ioc" and stop-limit orders
+	StopPrice     string `json:"stopPrice,omitempty"`     // Trigger price for "stp" and "take_profit" orders
+	CliOrdID      string `json:"cliOrdId,omitempty"`      // Optional client order ID
+	TriggerSignal string `json:"triggerSignal,omitempty"` // Optional trigger signal: "mark", "index" or "last"
+	ReduceOnly    bool   `json:"reduceOnly,omitempty"`    // If true, the order can only reduce an open position
+}
+
+// SendOrderResponse defines the structure of a successful sendorder response.
+type SendOrderResponse struct {
+	Result     string     `json:"result"`
+	ServerTime string     `json:"serverTime"`
+	SendStatus SendStatus `json:"sendStatus"`
+}
+
+// SendStatus describes the outcome of a sendorder request.
+type SendStatus struct {
+	OrderID      string       `json:"order_id"`
+	Status       string       `json:"status"` // "placed" on success, otherwise the rejection reason
+	ReceivedTime string       `json:"receivedTime"`
+	CliOrdID     string       `json:"cliOrdId,omitempty"`
+	OrderEvents  []OrderEvent `json:"orderEvents,omitempty"`
+}
+
+// OrderEvent describes an event that happened to an order as result of a request.
+type OrderEvent struct {
+	Type    string  `json:"type"` // e.g. "PLACE", "EXECUTION", "REJECT", "CANCEL"
+	OrderID string  `json:"order_id,omitempty"`
+	Price   float64 `json:"price,omitempty"`
+	Amount  float64 `json:"amount,omitempty"`
+	Reason  string  `json:"reason,omitempty"`
+}
+
+// CancelOrderResponse defines the structure of a successful cancelorder response.
+type CancelOrderResponse struct {
+	Result       string       `json:"result"`
+	ServerTime   string       `json:"serverTime"`
+	CancelStatus CancelStatus `json:"cancelStatus"`
+}
+
+// CancelStatus describes the outcome of a cancelorder request.
+type CancelStatus struct {
+	OrderID      string `json:"order_id"`
+	CliOrdID     string `json:"cliOrdId,omitempty"`
+	Status       string `json:"status"` // "cancelled" on success, otherwise e.g. "notFound"
+	ReceivedTime string `json:"receivedTime"`
+}
+
+// --- Account Data Types ---
+
+// OpenPositionsResponse defines the structure of the openpositions response.
+type OpenPositionsResponse struct {
+	Result        string     `json:"result"`
+	ServerTime    string     `json:"serverTime"`
+	OpenPositions []Position `json:"openPositions"`
+}
+
+// Position describes an open futures position.
+type Position struct {
+	Side              string   `json:"side"` // "long" or "short"
+	Symbol            string   `json:"symbol"`
+	Price             float64  `json:"price"` // Average entry price
+	FillTime          string   `json:"fillTime"`
+	Size              float64  `json:"size"`
+	UnrealizedFunding *float64 `json:"unrealizedFunding,omitempty"`
+	PnlCurrency       string   `json:"pnlCurrency,omitempty"`
+	MaxFixedLeverage  *float64 `json:"maxFixedLeverage,omitempty"`
+}
+
+// AccountsResponse defines the structure of the accounts response.
+// Accounts maps account names (e.g., "flex", "cash", "fi_xbtusd") to their details.
+type AccountsResponse struct {
+	Result     string             `json:"result"`
+	ServerTime string             `json:"serverTime"`
+	Accounts   map[string]Account `json:"accounts"`
+}
+
+// Account holds the fields shared by the cash, margin and multi-collateral (flex) accounts.
+// Fields that do not apply to an account type are left empty.
+type Account struct {
+	Type               string             `json:"type"` // "cashAccount", "marginAccount" or "multiCollateralMarginAccount"
+	Currency           string             `json:"currency,omitempty"`
+	Balances           map[string]float64 `json:"balances,omitempty"`
+	Auxiliary          *Auxiliary         `json:"auxiliary,omitempty"`
+	MarginRequirements *MarginValues      `json:"marginRequirements,omitempty"`
+	TriggerEstimates   *MarginValues      `json:"triggerEstimates,omitempty"`
+
+	// Multi-collateral (flex) account fields.
+	PortfolioValue    float64 `json:"portfolioValue,omitempty"`
+	CollateralValue   float64 `json:"collateralValue,omitempty"`
+	AvailableMargin   float64 `json:"availableMargin,omitempty"`
+	InitialMargin     float64 `json:"initialMargin,omitempty"`
+	MaintenanceMargin float64 `json:"maintenanceMargin,omitempty"`
+	TotalUnrealized   float64 `json:"totalUnrealized,omitempty"`
+	MarginEquity      float64 `json:"marginEquity,omitempty"`
+}
+
+// Auxiliary holds summary values of a margin account.
+type Auxiliary struct {
+	AvailableFunds float64 `json:"af"`  // Available funds
+	PnL            float64 `json:"pnl"` // Unrealized PnL
+	PortfolioValue float64 `json:"pv"`  // Portfolio value
+	Funding        float64 `json:"funding,omitempty"`
+}
+
+// MarginValues holds the initial, maintenance and termination margin levels of a margin account.
+type MarginValues struct {
+	Initial     float64 `json:"im"`
+	Maintenance float64 `json:"mm"`
+	Liquidation float64 `json:"lt"`
+	Termination float64 `json:"tt"`
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"tvwh2k/database"
+	"tvwh2k/handler"
+)
+
+// lifecycle runs the background workers (pollers, schedulers and the Telegram bot)
+// and shuts the server down in order when the process is asked to stop.
+type lifecycle struct {
+	ctx     context.Context // Cancelled when the workers should stop
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+}
+
+func newLifecycle() *lifecycle {
+	l := &lifecycle{}
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+	return l
+}
+
+// Go runs a worker until its context is cancelled.
+func (l *lifecycle) Go(run func(ctx context.Context)) {
+	l.workers.Add(1)
+	go func() {
+		defer l.workers.Done()
+		run(l.ctx)
+	}()
+}
+
+// Shutdown stops the server from accepting requests and the handler from accepting
+// webhooks and order commands, waits for the requests and orders in progress, then
+// stops the workers and closes the database. Whatever has not finished by the deadline
+// of ctx is abandoned, but the database is still closed.
+func (l *lifecycle) Shutdown(ctx context.Context, srv *http.Server, h *handler.WebhookHandler, db *database.DB) error {
+	// The server and the handler drain in parallel: webhooks in progress hold
+	// both a request and an order.
+	var srvErr, ordersErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		srvErr = srv.Shutdown(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		ordersErr = h.Shutdown(ctx)
+	}()
+	wg.Wait()
+
+	var errs []error
+	if srvErr != nil {
+		errs = append(errs, fmt.Errorf("HTTP server: %w", srvErr))
+	}
+	if ordersErr != nil {
+		errs = append(errs, fmt.Errorf("orders in progress: %w", ordersErr))
+	}
+
+	l.cancel()
+	done := make(chan struct{})
+	go func() {
+		l.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("background workers: %w", ctx.Err()))
+	}
+
+	if err := db.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("database: %w", err))
+	}
+	return errors.Join(errs...)
+}
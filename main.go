@@ -1,45 +1,385 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+	"tvwh2k/api"
+	"tvwh2k/auth"
+	"tvwh2k/config"
 	"tvwh2k/database"
+	"tvwh2k/earn"
 	"tvwh2k/handler"
 	"tvwh2k/kraken"
+	"tvwh2k/krakenfutures"
+	"tvwh2k/maintenance"
+	"tvwh2k/notify"
+	"tvwh2k/reconcile"
+	"tvwh2k/report"
+	"tvwh2k/telegram"
 )
 
 func main() {
-	apiKey := os.Getenv("KRAKEN_API_KEY")
-	apiSecret := os.Getenv("KRAKEN_API_SECRET")
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	var k *kraken.Kraken
-	var err error
+	// Subcommands (e.g. "tvwh2k sweep ...") run once and exit.
+	if len(os.Args) > 1 {
+		if err := runCommand(cfg, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	if apiKey == "" || apiSecret == "" {
+	k, err := newKrakenClient(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create Kraken client: %v", err)
+	}
+	if k == nil {
 		fmt.Println("Warning: KRAKEN_API_KEY or KRAKEN_API_SECRET not set. Kraken integration disabled.")
 	} else {
-		k, err = kraken.NewClient(apiKey, apiSecret)
-		if err != nil {
-			log.Fatalf("Failed to create Kraken client: %v", err)
-		}
 		fmt.Println("Kraken client initialized.")
 	}
 
 	// Initialize Database
-	db, err := database.InitDB("./tvwh2k.db")
+	db, err := database.InitDB(string(cfg.Database.URL))
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
+	pnlMethod := database.CostMethod(cfg.Database.PnLMethod)
 
 	h := handler.NewWebhookHandler(k, db)
-	http.HandleFunc("/webhooks", h.ServeHTTP)
-	http.HandleFunc("/api/signals", h.HandleGetSignals)
-	http.HandleFunc("/api/trades", h.HandleGetTrades)
+	h.SetPnLMethod(pnlMethod)
+	life := newLifecycle()
+	router := newRouter(cfg)
+
+	authenticators, err := newAuthenticators(cfg)
+	if err != nil {
+		log.Fatalf("Invalid webhook authentication: %v", err)
+	}
+	for strategy, a := range authenticators {
+		h.SetAuthenticator(strategy, a)
+	}
+	if _, ok := authenticators[""]; !ok {
+		fmt.Println("Warning: TOKEN not set. Only strategies in WEBHOOK_SECRETS accept webhooks.")
+	}
+
+	var tg *telegram.Client
+	if cfg.Telegram.BotToken != "" {
+		tg = telegram.NewClient(string(cfg.Telegram.BotToken))
+	}
+	notifier, tgNotifier := newNotifier(cfg, tg, db)
+	if channels := notifier.Channels(); len(channels) > 0 {
+		fmt.Printf("Notifying via %s.\n", strings.Join(channels, ", "))
+	} else {
+		fmt.Println("Warning: no notification channels configured.")
+	}
+	h.SetNotifier(notifier)
+
+	notes := newTradeNotes(cfg, k, db)
+	notes.Method = pnlMethod
+	h.SetTradeNotes(notes)
+
+	if cfg.Earn.StrategyID != "" && k != nil {
+		p := newEarnPolicy(cfg, k, db)
+		h.SetEarnPolicy(p)
+		fmt.Printf("Earn policy enabled for %s (strategy %s).\n", p.Asset, p.StrategyID)
+	}
+
+	if k != nil {
+		interval := cfg.Kraken.ReconcileInterval
+		rec := reconcile.New(k, db)
+		rec.PnLMethod = pnlMethod
+		rec.Notifier = notifier
+		rec.Notes = notes
+		life.Go(func(ctx context.Context) { rec.Run(ctx, interval) })
+		fmt.Printf("Reconciling trades every %s.\n", interval)
+	}
+
+	sched := newReportScheduler(cfg, k, db, notifier)
+	sched.Method = pnlMethod
+	life.Go(sched.Run)
+	fmt.Printf("Daily report at %02d:00 UTC, equity in %s.\n", sched.Hour, sched.Currency)
 
-	fmt.Println("Starting server on :8081...")
-	if err := http.ListenAndServe(":8081", nil); err != nil {
+	m := newMaintainer(cfg, db)
+	if m.BackupDir != "" || m.Retention > 0 {
+		interval := cfg.Maintenance.BackupInterval
+		life.Go(func(ctx context.Context) { m.Run(ctx, interval) })
+		fmt.Printf("Running database maintenance every %s.\n", interval)
+	}
+
+	if f := cfg.Futures; f.APIKey != "" {
+		client, err := krakenfutures.NewClient(string(f.APIKey), string(f.APISecret))
+		if err != nil {
+			log.Fatalf("Failed to create Kraken Futures client: %v", err)
+		}
+		if f.Demo {
+			client.SetBaseURL(krakenfutures.FuturesDemoBaseURL)
+		}
+		h.SetFuturesClient(client)
+		fmt.Println("Kraken Futures client initialized.")
+	}
+
+	if tg != nil {
+		bot := newBot(cfg, tg)
+		h.RegisterCommands(bot)
+		if webhookURL := cfg.Telegram.WebhookURL; webhookURL != "" {
+			if err := bot.SetWebhook(webhookURL, string(cfg.Telegram.WebhookSecret)); err != nil {
+				log.Fatalf("Failed to set Telegram webhook: %v", err)
+			}
+			router.Handle(http.MethodPost, "/telegram/webhook", bot)
+			fmt.Println("Telegram bot receiving updates via webhook.")
+		} else {
+			life.Go(bot.Poll)
+			fmt.Println("Telegram bot polling for updates.")
+		}
+		// Strategies may be added to confirmation mode on reload, so pending orders always expire.
+		life.Go(func(ctx context.Context) { h.RunPendingExpiry(ctx, 10*time.Second) })
+	}
+
+	// The settings that can change on SIGHUP are applied by the reloader.
+	rl := &reloader{cfg: cfg, h: h, k: k, notifier: notifier, telegram: tgNotifier}
+	if err := rl.apply(cfg); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if strategies := cfg.ConfirmStrategies(); len(strategies) > 0 {
+		fmt.Printf("Orders of %s need confirmation in Telegram.\n", strings.Join(strategies, ","))
+	}
+	life.Go(rl.Run)
+
+	router.Handle(http.MethodPost, "/webhooks", h)
+	router.Handle(http.MethodPost, "/webhooks/{strategy}", h)
+	for path, f := range map[string]http.HandlerFunc{
+		"/signals":         h.HandleGetSignals,
+		"/signals/{id}":    h.HandleGetSignal,
+		"/trades":          h.HandleGetTrades,
+		"/trades/{id}":     h.HandleGetTrade,
+		"/pnl":             h.HandleGetPnL,
+		"/pnl/trades":      h.HandleGetPnLTrades,
+		"/positions":       h.HandleGetPositions,
+		"/equity":          h.HandleGetEquity,
+		"/export/{report}": h.HandleExport,
+	} {
+		router.Handle(http.MethodGet, "/api/v1"+path, f)
+		router.Handle(http.MethodGet, "/api"+path, api.Deprecated(f))
+	}
+
+	srv := newServer(cfg, router)
+	shutdownTimeout := cfg.Server.ShutdownTimeout
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 1)
+	go func() {
+		fmt.Printf("Starting server on %s...\n", srv.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-serveErr:
 		log.Fatal(err)
+	case <-ctx.Done():
+	}
+	stop() // A second signal kills the process right away
+
+	fmt.Printf("Shutting down, waiting up to %s for orders in progress...\n", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := life.Shutdown(shutdownCtx, srv, h, db); err != nil {
+		log.Fatalf("Shutdown incomplete: %v", err)
+	}
+	fmt.Println("Shutdown complete.")
+}
+
+// newServer creates the HTTP server listening on server.addr (LISTEN_ADDR, default :8081).
+func newServer(cfg *config.Config, router http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           router,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+}
+
+// newRouter creates the HTTP router. CORS_ORIGINS lists the origins of dashboards
+// allowed to call the API from a browser and MAX_BODY_BYTES limits request bodies.
+func newRouter(cfg *config.Config) *api.Router {
+	return api.NewRouter(api.Options{CORSOrigins: cfg.Server.CORSOrigins, MaxBodyBytes: cfg.Server.MaxBodyBytes})
+}
+
+// newAuthenticators creates the webhook authenticators by strategy. The tokens hold the
+// secrets of all strategies and the strategies those with their own; several secrets are
+// accepted while they are rotated. webhook.auth selects whether the secret is sent as token
+// or used for an HMAC signature, and webhook.allowed_ips restricts the senders, with the
+// trusted proxies allowed to forward requests.
+func newAuthenticators(cfg *config.Config) (map[string]auth.Authenticator, error) {
+	w := cfg.Webhook
+	var ips auth.Authenticator
+	if len(w.AllowedIPs) > 0 {
+		allowed, err := auth.ParsePrefixes(strings.Join(w.AllowedIPs, ","))
+		if err != nil {
+			return nil, fmt.Errorf("WEBHOOK_ALLOWED_IPS: %w", err)
+		}
+		proxies, err := auth.ParsePrefixes(strings.Join(w.TrustedProxies, ","))
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+		}
+		ips = &auth.IPAllowlist{Allowed: allowed, TrustedProxies: proxies}
+	}
+
+	newAuth := func(secrets []config.Secret) auth.Authenticator {
+		list := make([]string, len(secrets))
+		for i, s := range secrets {
+			list[i] = string(s)
+		}
+		var a auth.Authenticator = &auth.Token{Secrets: list}
+		if w.Auth == "hmac" {
+			a = &auth.HMAC{Secrets: list, Window: w.HMACWindow}
+		}
+		if ips != nil {
+			return auth.All{ips, a}
+		}
+		return a
+	}
+
+	authenticators := make(map[string]auth.Authenticator)
+	if len(w.Tokens) > 0 {
+		authenticators[""] = newAuth(w.Tokens)
+	}
+	for strategy, s := range cfg.Strategies {
+		if len(s.Secrets) > 0 {
+			authenticators[strategy] = newAuth(s.Secrets)
+		}
+	}
+	return authenticators, nil
+}
+
+// newKrakenClient creates the Kraken spot client. It returns nil without error when no
+// API credentials are configured. The margin guard is set by the reloader.
+func newKrakenClient(cfg *config.Config) (*kraken.Kraken, error) {
+	if cfg.Kraken.APIKey == "" {
+		return nil, nil
+	}
+	k, err := kraken.NewClient(string(cfg.Kraken.APIKey), string(cfg.Kraken.APISecret))
+	if err != nil {
+		return nil, err
+	}
+	k.SetWithdrawAllowlist(cfg.Kraken.WithdrawKeys)
+	return k, nil
+}
+
+// newEarnPolicy creates the idle-balance Earn policy.
+func newEarnPolicy(cfg *config.Config, k *kraken.Kraken, db *database.DB) *earn.Policy {
+	e := cfg.Earn
+	p := earn.NewPolicy(k, db, e.StrategyID, e.Asset)
+	if e.Quote != "" {
+		p.Quote = e.Quote
+	}
+	p.Reserve, p.MinMove = e.Reserve, e.MinMove
+	return p
+}
+
+// newMaintainer creates the database maintenance.
+func newMaintainer(cfg *config.Config, db *database.DB) *maintenance.Maintainer {
+	m := maintenance.New(db)
+	m.BackupDir = cfg.Maintenance.BackupDir
+	m.Keep = cfg.Maintenance.BackupKeep
+	m.Retention = time.Duration(cfg.Maintenance.RetentionDays) * 24 * time.Hour
+	return m
+}
+
+// newReportScheduler creates the daily equity snapshot and summary scheduler.
+// Summaries are sent as report events.
+func newReportScheduler(cfg *config.Config, k *kraken.Kraken, db *database.DB, n notify.Notifier) *report.Scheduler {
+	s := report.NewScheduler(k, db)
+	s.Currency = cfg.Reports.Currency
+	s.Hour = cfg.Reports.Hour
+	s.Send = func(text string) error {
+		return n.Notify(context.Background(), notify.Event{Type: notify.EventReport, Severity: notify.Info, Text: text})
+	}
+	return s
+}
+
+// newNotifier creates the configured notification channels. It also returns the Telegram
+// channel, if any, whose topics change on reload. The routing rules are set by the reloader.
+func newNotifier(cfg *config.Config, tg *telegram.Client, db *database.DB) (*notify.Router, *notify.Telegram) {
+	n := cfg.Notify
+	r := notify.NewRouter()
+	var t *notify.Telegram
+	if tg != nil && cfg.Telegram.ChatID != 0 {
+		t = newTelegramNotifier(cfg, tg, db)
+		r.Add("telegram", t)
+	}
+	if n.DiscordURL != "" {
+		r.Add("discord", &notify.Discord{URL: string(n.DiscordURL)})
+	}
+	if n.SlackURL != "" {
+		r.Add("slack", &notify.Slack{URL: string(n.SlackURL)})
+	}
+	if n.WebhookURL != "" {
+		w := &notify.Webhook{URL: n.WebhookURL, Header: http.Header{}}
+		if n.WebhookSecret != "" {
+			w.Header.Set("Authorization", "Bearer "+string(n.WebhookSecret))
+		}
+		r.Add("webhook", w)
+	}
+	if smtp := n.SMTP; smtp.Host != "" {
+		r.Add("email", &notify.Email{
+			Addr:     net.JoinHostPort(smtp.Host, smtp.Port),
+			Username: smtp.Username,
+			Password: string(smtp.Password),
+			From:     smtp.From,
+			To:       smtp.To,
+		})
+	}
+	if n.NtfyURL != "" {
+		r.Add("ntfy", &notify.Ntfy{URL: n.NtfyURL, Token: string(n.NtfyToken)})
+	}
+	if n.GotifyURL != "" {
+		r.Add("gotify", &notify.Gotify{URL: strings.TrimRight(n.GotifyURL, "/"), Token: string(n.GotifyToken)})
+	}
+	return r, t
+}
+
+// newTelegramNotifier threads the updates of a signal under its message as set by
+// telegram.threading (reply, edit or off). The forum topics are set by the reloader.
+func newTelegramNotifier(cfg *config.Config, tg *telegram.Client, db *database.DB) *notify.Telegram {
+	t := &notify.Telegram{Client: tg, ChatID: cfg.Telegram.ChatID, Messages: db}
+	if mode := cfg.Telegram.Threading; mode == "off" {
+		t.Messages = nil
+	} else {
+		t.Threading = mode
+	}
+	return t
+}
+
+// newTradeNotes creates the order details for notifications, with charts if telegram.charts is set.
+func newTradeNotes(cfg *config.Config, k *kraken.Kraken, db *database.DB) *report.Notes {
+	n := report.NewNotes(k, db)
+	n.Charts = cfg.Telegram.Charts
+	n.ChartInterval = cfg.Telegram.ChartInterval
+	n.ChartCandles = cfg.Telegram.ChartCandles
+	return n
+}
+
+// newBot creates the Telegram command bot. Commands are accepted from the allowed chats
+// (default the chat of the notifications) and, if set, only from the allowed users.
+func newBot(cfg *config.Config, tg *telegram.Client) *telegram.Bot {
+	chats := cfg.Telegram.AllowedChats
+	if len(chats) == 0 && cfg.Telegram.ChatID != 0 {
+		chats = []int64{cfg.Telegram.ChatID}
 	}
+	bot := telegram.NewBot(tg)
+	bot.Allow(chats, cfg.Telegram.AllowedUsers)
+	return bot
 }
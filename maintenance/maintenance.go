@@ -0,0 +1,238 @@
+// Package maintenance keeps the database in shape: it takes periodic online
+// backups of the SQLite database, rotates old backups and prunes raw signal
+// payloads past the retention window.
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"tvwh2k/database"
+)
+
+const (
+	backupPrefix = "tvwh2k-"
+	backupSuffix = ".db"
+	backupTime   = "20060102T150405Z"
+)
+
+// Maintainer runs the periodic maintenance tasks.
+type Maintainer struct {
+	db *database.DB
+
+	// BackupDir is the directory backups are written to; empty disables backups.
+	BackupDir string
+	// Keep is the number of backups to keep; older ones are removed. 0 keeps all.
+	Keep int
+	// Retention is how long raw signal payloads are kept; 0 keeps them forever.
+	Retention time.Duration
+
+	now        func() time.Time
+	skipWarned bool // Whether skipping backups of a non-SQLite database was logged
+}
+
+// New creates a Maintainer that keeps 7 backups and all payloads.
+func New(db *database.DB) *Maintainer {
+	return &Maintainer{db: db, Keep: 7, now: time.Now}
+}
+
+// Run performs the maintenance every interval until ctx is cancelled.
+func (m *Maintainer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.RunOnce(); err != nil {
+			fmt.Printf("Maintenance failed: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce takes a backup, rotates old backups and prunes old payloads, as configured.
+// Backups only cover SQLite databases; other databases are skipped with a warning the
+// first time. Payloads are pruned even when the backup fails.
+func (m *Maintainer) RunOnce() error {
+	var errs []error
+	if m.BackupDir != "" {
+		if err := m.backupAndRotate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if m.Retention > 0 {
+		n, err := m.db.PruneSignals(m.now().Add(-m.Retention))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pruning signals: %w", err))
+		} else if n > 0 {
+			fmt.Printf("Pruned payloads of %d signals older than %s.\n", n, m.Retention)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// backupAndRotate takes a backup of a SQLite database and removes the oldest backups.
+func (m *Maintainer) backupAndRotate() error {
+	if name := m.db.Dialect().Name(); name != "sqlite3" {
+		if !m.skipWarned {
+			m.skipWarned = true
+			fmt.Printf("Warning: BACKUP_DIR is set but backups only support SQLite, skipping backups of the %s database.\n", name)
+		}
+		return nil
+	}
+
+	path, err := m.Backup()
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	fmt.Printf("Database backed up to %s.\n", path)
+
+	removed, err := m.Rotate()
+	if err != nil {
+		return fmt.Errorf("rotating backups: %w", err)
+	}
+	for _, b := range removed {
+		fmt.Printf("Removed old backup %s.\n", b.Path)
+	}
+	return nil
+}
+
+// Backup writes a new backup to BackupDir and returns its path.
+func (m *Maintainer) Backup() (string, error) {
+	if err := os.MkdirAll(m.BackupDir, 0o750); err != nil {
+		return "", err
+	}
+	path := filepath.Join(m.BackupDir, backupPrefix+m.now().UTC().Format(backupTime)+backupSuffix)
+	if err := m.db.Backup(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// BackupFile is a backup in the backup directory.
+type BackupFile struct {
+	Name string
+	Path string
+	Time time.Time
+	Size int64
+}
+
+// Backups lists the backups in dir, newest first.
+func Backups(dir string) ([]BackupFile, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []BackupFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		t, err := time.Parse(backupTime, strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix))
+		if err != nil {
+			continue // Not one of ours
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, BackupFile{Name: name, Path: filepath.Join(dir, name), Time: t, Size: info.Size()})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Time.After(backups[j].Time) })
+	return backups, nil
+}
+
+// Rotate removes all but the newest Keep backups and returns the removed ones.
+func (m *Maintainer) Rotate() ([]BackupFile, error) {
+	if m.Keep <= 0 {
+		return nil, nil
+	}
+	backups, err := Backups(m.BackupDir)
+	if err != nil || len(backups) <= m.Keep {
+		return nil, err
+	}
+
+	removed := backups[m.Keep:]
+	for _, b := range removed {
+		if err := os.Remove(b.Path); err != nil {
+			return nil, err
+		}
+	}
+	return removed, nil
+}
+
+// Restore replaces the SQLite database file with a backup. The application must
+// be stopped. The current database is kept next to it as <file>.pre-restore-<time>,
+// and the backup is checked before anything is replaced.
+// It returns the path of the saved current database, empty if there was none.
+func Restore(backup, dbFile string) (string, error) {
+	if err := database.CheckIntegrity(backup); err != nil {
+		return "", err
+	}
+
+	var saved string
+	if _, err := os.Stat(dbFile); err == nil {
+		// Fold the write-ahead log into the database first so the saved copy is complete.
+		if err := checkpoint(dbFile); err != nil {
+			return "", err
+		}
+		saved = dbFile + ".pre-restore-" + time.Now().UTC().Format(backupTime)
+		if err := os.Rename(dbFile, saved); err != nil {
+			return "", err
+		}
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbFile + suffix); err != nil && !os.IsNotExist(err) {
+			return saved, err
+		}
+	}
+
+	if err := copyFile(backup, dbFile); err != nil {
+		return saved, err
+	}
+	return saved, nil
+}
+
+// checkpoint writes the WAL of a SQLite database back into the database file.
+func checkpoint(dbFile string) error {
+	db, err := database.Open(dbFile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	return err
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
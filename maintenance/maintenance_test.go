@@ -0,0 +1,137 @@
+package maintenance
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"tvwh2k/database"
+)
+
+func TestBackupRotateRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbFile := filepath.Join(dir, "tvwh2k.db")
+	db, err := database.InitDB(dbFile)
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	signalID, err := db.SaveSignal("XBTUSD", "buy", "trend", map[string]string{"text": "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := New(db)
+	m.BackupDir = filepath.Join(dir, "backups")
+	m.Keep = 2
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return at }
+	for i := 0; i < 3; i++ {
+		if err := m.RunOnce(); err != nil {
+			t.Fatalf("RunOnce: %v", err)
+		}
+		at = at.Add(time.Hour)
+	}
+
+	backups, err := Backups(m.BackupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || backups[0].Name != "tvwh2k-20240101T020000Z.db" || backups[1].Name != "tvwh2k-20240101T010000Z.db" {
+		t.Fatalf("got backups %+v, want the newest two", backups)
+	}
+
+	// Changes after the backup are undone by the restore.
+	if _, err := db.SaveSignal("ETHUSD", "sell", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	saved, err := Restore(backups[0].Path, dbFile)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if saved == "" {
+		t.Error("expected the current database to be saved")
+	}
+
+	db, err = database.InitDB(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	signals, err := db.GetRecentSignals(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signals) != 1 || signals[0].ID != signalID {
+		t.Fatalf("got %d signals after restore, want only the first", len(signals))
+	}
+}
+
+func TestPruneSignals(t *testing.T) {
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	defer db.Close()
+
+	signalID, err := db.SaveSignal("XBTUSD", "buy", "trend", map[string]string{"text": "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveSignalStep(signalID, "order_request", "sent", map[string]string{"pair": "XBTUSD"}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := New(db)
+	m.Retention = 24 * time.Hour
+	m.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	if err := m.RunOnce(); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+
+	s, err := db.GetSignal(signalID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Payload != "" || s.Pair != "XBTUSD" || s.Strategy != "trend" {
+		t.Errorf("got %+v, want the signal without payload", s)
+	}
+	steps, err := db.GetSignalSteps(signalID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 1 || steps[0].Detail != nil {
+		t.Errorf("got steps %+v, want the step without detail", steps)
+	}
+}
+
+func TestRunOncePrunesWhenBackupFails(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.InitDB(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("InitDB: %v", err)
+	}
+	defer db.Close()
+
+	signalID, err := db.SaveSignal("XBTUSD", "buy", "trend", map[string]string{"text": "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := New(db)
+	m.BackupDir = filepath.Join(dir, "test.db", "backups") // Not a directory
+	m.Retention = 24 * time.Hour
+	m.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	if err := m.RunOnce(); err == nil || !strings.Contains(err.Error(), "backup") {
+		t.Fatalf("RunOnce: err = %v, want the backup error", err)
+	}
+
+	s, err := db.GetSignal(signalID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Payload != "" {
+		t.Errorf("payload %q was not pruned", s.Payload)
+	}
+}
@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// httpClient is used by all HTTP based channels.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// post sends body to url and fails on non-2xx responses.
+func post(ctx context.Context, url, contentType string, body []byte, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func postJSON(ctx context.Context, url string, v interface{}, header http.Header) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return post(ctx, url, "application/json", body, header)
+}
+
+// truncate shortens text to at most limit runes.
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
+}
+
+// Discord posts events to a Discord channel webhook.
+type Discord struct {
+	URL string
+}
+
+// discordLimit is the maximum length of a Discord message.
+const discordLimit = 2000
+
+func (d *Discord) Notify(ctx context.Context, e Event) error {
+	return postJSON(ctx, d.URL, map[string]string{"content": truncate(e.Body(), discordLimit)}, nil)
+}
+
+// Slack posts events to a Slack incoming webhook.
+type Slack struct {
+	URL string
+}
+
+func (s *Slack) Notify(ctx context.Context, e Event) error {
+	return postJSON(ctx, s.URL, map[string]string{"text": e.Body()}, nil)
+}
+
+// Webhook posts events as JSON to any HTTP endpoint. Header is added to every request,
+// e.g. for authentication.
+type Webhook struct {
+	URL    string
+	Header http.Header
+}
+
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	return postJSON(ctx, w.URL, e, w.Header)
+}
+
+// Ntfy publishes events to an ntfy topic URL, e.g. https://ntfy.sh/my-topic.
+type Ntfy struct {
+	URL   string
+	Token string // Optional access token
+}
+
+var ntfyPriorities = map[Severity]string{Info: "default", Warning: "high", Critical: "urgent"}
+
+func (n *Ntfy) Notify(ctx context.Context, e Event) error {
+	header := http.Header{}
+	header.Set("Title", e.Subject())
+	header.Set("Priority", ntfyPriorities[e.Severity])
+	header.Set("Tags", string(e.Type))
+	if n.Token != "" {
+		header.Set("Authorization", "Bearer "+n.Token)
+	}
+	return post(ctx, n.URL, "text/plain; charset=utf-8", []byte(e.textAndFields()), header)
+}
+
+// Gotify sends events to a Gotify server with an application token.
+type Gotify struct {
+	URL   string // Server URL, e.g. https://gotify.example.com
+	Token string
+}
+
+var gotifyPriorities = map[Severity]int{Info: 4, Warning: 6, Critical: 8}
+
+func (g *Gotify) Notify(ctx context.Context, e Event) error {
+	header := http.Header{}
+	header.Set("X-Gotify-Key", g.Token)
+	return postJSON(ctx, g.URL+"/message", map[string]interface{}{
+		"title":    e.Subject(),
+		"message":  e.textAndFields(),
+		"priority": gotifyPriorities[e.Severity],
+	}, header)
+}
@@ -0,0 +1,321 @@
+// Package notify delivers events such as received signals, fills and daily reports
+// to notification channels (Telegram, Discord, Slack, HTTP webhooks, email, ntfy and
+// Gotify). A Router sends each event to the channels its rules select.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of an event; routing rules select channels by it.
+type EventType string
+
+const (
+	EventSignal       EventType = "signal_received"
+	EventOrderPlaced  EventType = "order_placed" // Placed, or validated in test mode
+	EventOrderSkipped EventType = "order_skipped"
+	EventOrderPartial EventType = "order_partially_filled"
+	EventOrderFilled  EventType = "order_filled" // Including take profit and stop loss orders
+	EventOrderFailed  EventType = "order_failed" // Rejected, failed, canceled or expired
+	EventRiskBreach   EventType = "risk_breach"  // Refused by a risk check
+	EventReport       EventType = "report"       // Daily and weekly summaries
+)
+
+// EventTypes lists all event types.
+var EventTypes = []EventType{EventSignal, EventOrderPlaced, EventOrderSkipped, EventOrderPartial, EventOrderFilled, EventOrderFailed, EventRiskBreach, EventReport}
+
+// Severity orders events by importance.
+type Severity int
+
+const (
+	Info Severity = iota
+	Warning
+	Critical
+)
+
+var severityNames = []string{"info", "warning", "critical"}
+
+func (s Severity) String() string {
+	if s < Info || s > Critical {
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ParseSeverity parses info, warning or critical.
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if strings.EqualFold(name, n) {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q (want info, warning or critical)", name)
+}
+
+// Event is something worth telling the operator about.
+type Event struct {
+	Type     EventType `json:"type"`
+	Severity Severity  `json:"severity"`
+	Title    string    `json:"title,omitempty"` // Optional, see Subject
+	Text     string    `json:"text"`
+	Fields   []Field   `json:"fields,omitempty"` // Structured details, e.g. entry price and size
+	SignalID int64     `json:"signal_id,omitempty"`
+	Strategy string    `json:"strategy,omitempty"` // Strategy of the signal, if known
+	Time     time.Time `json:"time"`
+
+	// Chart is an optional PNG image, e.g. of the recent candles with the trade's entries and exits.
+	// Channels that cannot attach images ignore it.
+	Chart []byte `json:"-"`
+}
+
+// Field is a labelled detail of an event.
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Subject is the title of the event, or a title derived from its type.
+func (e Event) Subject() string {
+	if e.Title != "" {
+		return e.Title
+	}
+	return "tvwh2k: " + strings.ReplaceAll(string(e.Type), "_", " ")
+}
+
+// Body is the text for channels without a separate title: the title, if any,
+// followed by the text and the fields.
+func (e Event) Body() string {
+	lines := make([]string, 0, len(e.Fields)+2)
+	if e.Title != "" {
+		lines = append(lines, e.Title)
+	}
+	if e.Text != "" {
+		lines = append(lines, e.Text)
+	}
+	return strings.Join(append(lines, e.FieldLines()...), "\n")
+}
+
+// textAndFields is the body for channels that show the title separately.
+func (e Event) textAndFields() string {
+	return Event{Text: e.Text, Fields: e.Fields}.Body()
+}
+
+// FieldLines formats the fields as "Name: Value" lines.
+func (e Event) FieldLines() []string {
+	lines := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		lines[i] = f.Name + ": " + f.Value
+	}
+	return lines
+}
+
+// Notifier delivers events to one channel.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// Rule routes the events of the given types with at least MinSeverity to channels.
+// A rule without event types matches all events.
+type Rule struct {
+	Events      []EventType
+	MinSeverity Severity
+	Channels    []string
+}
+
+func (r Rule) matches(e Event) bool {
+	if e.Severity < r.MinSeverity {
+		return false
+	}
+	if len(r.Events) == 0 {
+		return true
+	}
+	for _, t := range r.Events {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRules parses routing rules of the form
+//
+//	<events>[@<min severity>]=<channels>;...
+//
+// where events and channels are comma separated and * matches all event types, e.g.
+// "order_failed,risk_breach=telegram,email; report=email; *@warning=ntfy".
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, spec := range strings.Split(s, ";") {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		match, channels, ok := strings.Cut(spec, "=")
+		if !ok {
+			return nil, fmt.Errorf("rule %q: want <events>=<channels>", spec)
+		}
+
+		var rule Rule
+		events, severity, hasSeverity := strings.Cut(match, "@")
+		if hasSeverity {
+			var err error
+			if rule.MinSeverity, err = ParseSeverity(strings.TrimSpace(severity)); err != nil {
+				return nil, fmt.Errorf("rule %q: %w", spec, err)
+			}
+		}
+		for _, name := range splitList(events) {
+			if name == "*" {
+				rule.Events = nil
+				break
+			}
+			if !knownEvent(EventType(name)) {
+				return nil, fmt.Errorf("rule %q: unknown event %q", spec, name)
+			}
+			rule.Events = append(rule.Events, EventType(name))
+		}
+		if rule.Channels = splitList(channels); len(rule.Channels) == 0 {
+			return nil, fmt.Errorf("rule %q: no channels", spec)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func knownEvent(t EventType) bool {
+	for _, known := range EventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Router sends events to named channels according to its rules.
+// Without rules every event goes to every channel.
+type Router struct {
+	channels map[string]Notifier
+
+	// Timeout bounds each delivery, so a stalled channel cannot hold up the caller.
+	Timeout time.Duration
+
+	mu    sync.RWMutex // Guards rules, which may be replaced while events are sent
+	rules []Rule
+}
+
+// NewRouter creates a router without channels that gives each delivery 10 seconds.
+func NewRouter() *Router {
+	return &Router{channels: make(map[string]Notifier), Timeout: 10 * time.Second}
+}
+
+// Add registers a channel under a name used in rules, e.g. "telegram".
+func (r *Router) Add(name string, n Notifier) {
+	r.channels[name] = n
+}
+
+// Channels returns the names of the registered channels, sorted.
+func (r *Router) Channels() []string {
+	names := make([]string, 0, len(r.channels))
+	for name := range r.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetRules replaces the routing rules, also while events are sent. Rules may only name
+// registered channels.
+func (r *Router) SetRules(rules []Rule) error {
+	for _, rule := range rules {
+		for _, name := range rule.Channels {
+			if _, ok := r.channels[name]; !ok {
+				return fmt.Errorf("rule names unknown channel %q (configured: %s)", name, strings.Join(r.Channels(), ", "))
+			}
+		}
+	}
+	r.mu.Lock()
+	r.rules = rules
+	r.mu.Unlock()
+	return nil
+}
+
+// Route returns the channels an event is sent to, sorted.
+func (r *Router) Route(e Event) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.rules) == 0 {
+		return r.Channels()
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, rule := range r.rules {
+		if !rule.matches(e) {
+			continue
+		}
+		for _, name := range rule.Channels {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Delivery is the outcome of sending an event to one channel.
+type Delivery struct {
+	Channel string
+	Err     error
+}
+
+// Send delivers the event to the channels selected by the rules and reports each delivery.
+// Each delivery is cancelled after Timeout.
+func (r *Router) Send(ctx context.Context, e Event) []Delivery {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	var deliveries []Delivery
+	for _, name := range r.Route(e) {
+		deliveries = append(deliveries, Delivery{Channel: name, Err: r.deliver(ctx, r.channels[name], e)})
+	}
+	return deliveries
+}
+
+func (r *Router) deliver(ctx context.Context, n Notifier, e Event) error {
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	return n.Notify(ctx, e)
+}
+
+// Notify implements Notifier; it returns the errors of all failed deliveries.
+func (r *Router) Notify(ctx context.Context, e Event) error {
+	var errs []error
+	for _, d := range r.Send(ctx, e) {
+		if d.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", d.Channel, d.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,287 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+	"time"
+	"tvwh2k/database"
+	"tvwh2k/telegram"
+)
+
+// recorder is a channel that records the events it receives.
+type recorder struct {
+	events []Event
+	err    error
+}
+
+func (r *recorder) Notify(ctx context.Context, e Event) error {
+	r.events = append(r.events, e)
+	return r.err
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("order_failed,risk_breach=telegram,email; report=email; *@warning=ntfy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("rules = %+v", rules)
+	}
+	if len(rules[0].Events) != 2 || rules[0].Channels[1] != "email" {
+		t.Errorf("rule 0 = %+v", rules[0])
+	}
+	if rules[2].Events != nil || rules[2].MinSeverity != Warning {
+		t.Errorf("rule 2 = %+v", rules[2])
+	}
+
+	for _, bad := range []string{"order_failed", "nope=telegram", "report=", "*@loud=telegram"} {
+		if _, err := ParseRules(bad); err == nil {
+			t.Errorf("ParseRules(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestRouter(t *testing.T) {
+	telegram, email, ntfy := &recorder{}, &recorder{err: errors.New("smtp down")}, &recorder{}
+	r := NewRouter()
+	r.Add("telegram", telegram)
+	r.Add("email", email)
+	r.Add("ntfy", ntfy)
+
+	// Without rules every channel gets every event.
+	if got := r.Route(Event{Type: EventSignal}); strings.Join(got, ",") != "email,ntfy,telegram" {
+		t.Errorf("default route = %v", got)
+	}
+
+	rules, _ := ParseRules("order_failed,risk_breach=telegram,email; report=email; *@critical=ntfy")
+	if err := r.SetRules(rules); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		event Event
+		want  string
+	}{
+		{Event{Type: EventSignal}, ""},
+		{Event{Type: EventReport}, "email"},
+		{Event{Type: EventOrderFailed, Severity: Warning}, "email,telegram"},
+		{Event{Type: EventRiskBreach, Severity: Critical}, "email,ntfy,telegram"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(r.Route(tt.event), ","); got != tt.want {
+			t.Errorf("Route(%s) = %q, want %q", tt.event.Type, got, tt.want)
+		}
+	}
+
+	err := r.Notify(context.Background(), Event{Type: EventRiskBreach, Severity: Critical, Text: "margin"})
+	if err == nil || !strings.Contains(err.Error(), "email: smtp down") {
+		t.Errorf("err = %v", err)
+	}
+	if len(telegram.events) != 1 || len(ntfy.events) != 1 || telegram.events[0].Time.IsZero() {
+		t.Errorf("telegram %v, ntfy %v", telegram.events, ntfy.events)
+	}
+
+	if err := r.SetRules([]Rule{{Channels: []string{"pager"}}}); err == nil {
+		t.Error("rule with an unknown channel accepted")
+	}
+}
+
+// stalled is a channel that only returns when its delivery is cancelled.
+type stalled struct{}
+
+func (stalled) Notify(ctx context.Context, e Event) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRouterTimeout(t *testing.T) {
+	r := NewRouter()
+	r.Timeout = 10 * time.Millisecond
+	r.Add("stalled", stalled{})
+	r.Add("telegram", &recorder{})
+
+	deliveries := r.Send(context.Background(), Event{Type: EventSignal})
+	if len(deliveries) != 2 || !errors.Is(deliveries[0].Err, context.DeadlineExceeded) || deliveries[1].Err != nil {
+		t.Errorf("deliveries = %+v", deliveries)
+	}
+}
+
+func TestEmailTimeout(t *testing.T) {
+	// A server that accepts the connection but never greets.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			defer conn.Close()
+			time.Sleep(time.Second)
+		}
+	}()
+
+	m := &Email{Addr: l.Addr().String(), From: "bot@example.com", To: []string{"a@example.com"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := m.Notify(ctx, Event{Text: "hi"}); err == nil {
+		t.Fatal("expected a timeout")
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("Notify returned after %s", d)
+	}
+}
+
+func TestHTTPChannels(t *testing.T) {
+	type request struct {
+		path   string
+		header http.Header
+		body   string
+	}
+	var got request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got = request{r.URL.Path, r.Header, string(body)}
+	}))
+	defer server.Close()
+
+	e := Event{Type: EventOrderFailed, Severity: Critical, Title: "Order failed", Text: "insufficient funds"}
+	tests := []struct {
+		name  string
+		n     Notifier
+		check func(request) bool
+	}{
+		{"discord", &Discord{URL: server.URL}, func(r request) bool {
+			return r.body == `{"content":"Order failed\ninsufficient funds"}`
+		}},
+		{"slack", &Slack{URL: server.URL}, func(r request) bool {
+			return r.body == `{"text":"Order failed\ninsufficient funds"}`
+		}},
+		{"webhook", &Webhook{URL: server.URL, Header: http.Header{"Authorization": {"Bearer s"}}}, func(r request) bool {
+			var decoded map[string]interface{}
+			json.Unmarshal([]byte(r.body), &decoded)
+			return decoded["type"] == "order_failed" && decoded["severity"] == "critical" && r.header.Get("Authorization") == "Bearer s"
+		}},
+		{"ntfy", &Ntfy{URL: server.URL + "/alerts", Token: "tk"}, func(r request) bool {
+			return r.path == "/alerts" && r.body == "insufficient funds" && r.header.Get("Title") == "Order failed" &&
+				r.header.Get("Priority") == "urgent" && r.header.Get("Authorization") == "Bearer tk"
+		}},
+		{"gotify", &Gotify{URL: server.URL, Token: "app"}, func(r request) bool {
+			return r.path == "/message" && r.header.Get("X-Gotify-Key") == "app" &&
+				r.body == `{"message":"insufficient funds","priority":8,"title":"Order failed"}`
+		}},
+	}
+	for _, tt := range tests {
+		got = request{}
+		if err := tt.n.Notify(context.Background(), e); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !tt.check(got) {
+			t.Errorf("%s: unexpected request %+v", tt.name, got)
+		}
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+	}))
+	defer failing.Close()
+	if err := (&Slack{URL: failing.URL}).Notify(context.Background(), e); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestEmailMessage(t *testing.T) {
+	m := &Email{From: "bot@example.com", To: []string{"a@example.com", "b@example.com"}}
+	msg := string(m.message(Event{Type: EventReport, Text: "line 1\nline 2"}))
+	for _, want := range []string{"To: a@example.com, b@example.com\r\n", "Subject: tvwh2k: report\r\n", "\r\n\r\nline 1\r\nline 2\r\n"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message lacks %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestTelegramHTML(t *testing.T) {
+	e := Event{Title: "Order Filled: buy 1 XBT<USD", Text: "a & b", Fields: []Field{{"Realized PnL", "+19.00 (+18.91%)"}}}
+	want := "<b>Order Filled: buy 1 XBT&lt;USD</b>\na &amp; b\n<b>Realized PnL:</b> +19.00 (+18.91%)"
+	if got := telegramHTML(e); got != want {
+		t.Errorf("telegramHTML = %q, want %q", got, want)
+	}
+}
+
+// messageStore is an in-memory MessageStore.
+type messageStore map[int64]*database.TelegramMessage
+
+func (s messageStore) SaveTelegramMessage(m database.TelegramMessage) error {
+	s[m.SignalID] = &m
+	return nil
+}
+
+func (s messageStore) GetTelegramMessage(signalID, chatID int64) (*database.TelegramMessage, error) {
+	return s[signalID], nil
+}
+
+func (s messageStore) SetTelegramMessageText(signalID, chatID int64, text string) error {
+	s[signalID].Text = text
+	return nil
+}
+
+func TestTelegramThreading(t *testing.T) {
+	type request struct {
+		method string
+		form   url.Values
+	}
+	var requests []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		requests = append(requests, request{path.Base(r.URL.Path), r.PostForm})
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"chat":{"id":42}}}`, len(requests))
+	}))
+	defer server.Close()
+	client := telegram.NewClient("123:abc")
+	client.SetBaseURL(server.URL)
+	client.ChatInterval = 0
+
+	store := messageStore{}
+	tg := &Telegram{Client: client, ChatID: 42, Messages: store, Topics: map[string]int64{"trend": 12}}
+	send := func(e Event) request {
+		t.Helper()
+		if err := tg.Notify(context.Background(), e); err != nil {
+			t.Fatal(err)
+		}
+		return requests[len(requests)-1]
+	}
+
+	// The first message of a signal goes to the strategy's topic, later ones reply to it.
+	r := send(Event{Type: EventSignal, Text: "Received Signal", SignalID: 1, Strategy: "trend"})
+	if r.form.Get("message_thread_id") != "12" || store[1] == nil || store[1].MessageID != 1 {
+		t.Fatalf("signal message %+v, stored %+v", r, store[1])
+	}
+	r = send(Event{Type: EventOrderPlaced, Text: "Order Placed", SignalID: 1})
+	if r.method != "sendMessage" || r.form.Get("message_thread_id") != "12" || !strings.Contains(r.form.Get("reply_parameters"), `"message_id":1`) {
+		t.Errorf("reply %+v", r)
+	}
+	if r = send(Event{Type: EventOrderPlaced, Text: "Other strategy", SignalID: 2}); r.form.Get("message_thread_id") != "" || r.form.Get("reply_parameters") != "" {
+		t.Errorf("unrelated message %+v", r)
+	}
+
+	// In edit mode updates are appended, warnings still reply.
+	tg.Threading = ThreadEdit
+	r = send(Event{Type: EventOrderFilled, Text: "Order Filled", SignalID: 1})
+	if r.method != "editMessageText" || r.form.Get("message_id") != "1" || r.form.Get("text") != "Received Signal\n\nOrder Filled" || store[1].Text != r.form.Get("text") {
+		t.Errorf("edit %+v, stored %+v", r, store[1])
+	}
+	r = send(Event{Type: EventOrderFailed, Severity: Warning, Text: "Order Canceled", SignalID: 1})
+	if r.method != "sendMessage" || !strings.Contains(r.form.Get("reply_parameters"), `"message_id":1`) {
+		t.Errorf("warning %+v", r)
+	}
+}
@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"tvwh2k/database"
+	"tvwh2k/telegram"
+)
+
+// MessageStore remembers the Telegram message of each signal.
+type MessageStore interface {
+	SaveTelegramMessage(m database.TelegramMessage) error
+	GetTelegramMessage(signalID, chatID int64) (*database.TelegramMessage, error)
+	SetTelegramMessageText(signalID, chatID int64, text string) error
+}
+
+// Threading modes of the Telegram channel.
+const (
+	ThreadReply = "reply" // Updates of a signal reply to its first message
+	ThreadEdit  = "edit"  // Updates are appended to the first message of the signal
+)
+
+// Telegram sends events to a Telegram chat, formatted as HTML with the title and field
+// names in bold. An event chart is sent as a photo with the text as caption.
+//
+// With Messages set, the first message about a signal is stored and the later events of
+// the signal, such as the placed order, fills and stop-outs, reply to it. In ThreadEdit mode
+// they are appended to it instead, unless they are warnings, have a chart or would make
+// the message too long; those are still sent as replies so they notify.
+type Telegram struct {
+	Client    *telegram.Client
+	ChatID    int64
+	Messages  MessageStore
+	Threading string // ThreadReply if empty
+
+	// Topics maps strategies to the forum topics their messages are sent to. The topic
+	// of "*" is used for other strategies; without it they go to the general topic.
+	// Once events are sent it is only changed with SetTopics.
+	Topics   map[string]int64
+	topicsMu sync.RWMutex
+}
+
+// SetTopics replaces the forum topics of the strategies, also while events are sent.
+func (t *Telegram) SetTopics(topics map[string]int64) {
+	t.topicsMu.Lock()
+	defer t.topicsMu.Unlock()
+	t.Topics = topics
+}
+
+func (t *Telegram) Notify(ctx context.Context, e Event) error {
+	text := telegramHTML(e)
+	opts := &telegram.SendOptions{ParseMode: telegram.HTML, ThreadID: t.topic(e.Strategy)}
+
+	var thread *database.TelegramMessage
+	var lookupErr error
+	if t.Messages != nil && e.SignalID != 0 {
+		// Without the stored message the event is still sent, just not threaded.
+		thread, lookupErr = t.Messages.GetTelegramMessage(e.SignalID, t.ChatID)
+	}
+	if thread != nil {
+		opts.ThreadID = thread.ThreadID
+		if edited, err := t.edit(ctx, thread, e, text); edited {
+			return err
+		}
+		opts.ReplyTo = thread.MessageID
+	}
+
+	first, editable, err := t.send(ctx, text, e.Chart, opts)
+	if err != nil {
+		return errors.Join(lookupErr, err)
+	}
+	if thread == nil && lookupErr == nil && t.Messages != nil && e.SignalID != 0 {
+		m := database.TelegramMessage{SignalID: e.SignalID, ChatID: t.ChatID, MessageID: first.MessageID, ThreadID: opts.ThreadID}
+		if editable {
+			m.Text = text
+		}
+		return t.Messages.SaveTelegramMessage(m)
+	}
+	return lookupErr
+}
+
+// edit appends an event to the message of its signal. It reports false if the
+// event should be sent as a reply instead.
+func (t *Telegram) edit(ctx context.Context, thread *database.TelegramMessage, e Event, text string) (bool, error) {
+	if t.Threading != ThreadEdit || thread.Text == "" || e.Chart != nil || e.Severity > Info {
+		return false, nil
+	}
+	edited := thread.Text + "\n\n" + text
+	if len(telegram.SplitMessage(edited, telegram.MaxMessageLength)) > 1 {
+		return false, nil
+	}
+	if err := t.Client.EditMessageContext(ctx, t.ChatID, thread.MessageID, edited, &telegram.SendOptions{ParseMode: telegram.HTML}); err != nil {
+		if ctx.Err() != nil {
+			return true, err
+		}
+		return false, nil // E.g. the message was deleted
+	}
+	return true, t.Messages.SetTelegramMessageText(thread.SignalID, thread.ChatID, edited)
+}
+
+// send sends the text, with the chart as photo if there is one, and returns the first
+// message. Editable reports whether that message holds the whole text and can be edited.
+func (t *Telegram) send(ctx context.Context, text string, chart []byte, opts *telegram.SendOptions) (first telegram.Message, editable bool, err error) {
+	if chart != nil {
+		// Captions are short; longer texts follow the photo as a message.
+		caption := text
+		if len([]rune(caption)) > telegram.MaxCaptionLength {
+			caption = ""
+		}
+		photo, err := t.Client.SendPhotoContext(ctx, t.ChatID, chart, caption, opts)
+		if err != nil {
+			return telegram.Message{}, false, err
+		}
+		if caption == "" {
+			_, err = t.Client.SendMessageContext(ctx, t.ChatID, text, opts)
+		}
+		return *photo, false, err
+	}
+	msgs, err := t.Client.SendMessageContext(ctx, t.ChatID, text, opts)
+	if len(msgs) == 0 {
+		return telegram.Message{}, false, err
+	}
+	return msgs[0], len(msgs) == 1, err
+}
+
+func (t *Telegram) topic(strategy string) int64 {
+	t.topicsMu.RLock()
+	defer t.topicsMu.RUnlock()
+	if id, ok := t.Topics[strategy]; ok && strategy != "" {
+		return id
+	}
+	return t.Topics["*"]
+}
+
+// telegramHTML formats an event with one line per field so long messages split cleanly.
+func telegramHTML(e Event) string {
+	var lines []string
+	if e.Title != "" {
+		lines = append(lines, "<b>"+telegram.EscapeHTML(e.Title)+"</b>")
+	}
+	if e.Text != "" {
+		lines = append(lines, telegram.EscapeHTML(e.Text))
+	}
+	for _, f := range e.Fields {
+		lines = append(lines, "<b>"+telegram.EscapeHTML(f.Name)+":</b> "+telegram.EscapeHTML(f.Value))
+	}
+	return strings.Join(lines, "\n")
+}
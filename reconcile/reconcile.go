@@ -0,0 +1,289 @@
+// Package reconcile keeps the trades table in sync with Kraken: it polls the
+// orders of unfinished trades, records their fills and links conditional close
+// orders to the trade that created them.
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"tvwh2k/database"
+	"tvwh2k/kraken"
+	"tvwh2k/notify"
+	"tvwh2k/report"
+)
+
+// unfinishedStatuses are the trade statuses that can still change on Kraken.
+var unfinishedStatuses = []string{"pending", "open", "partial"}
+
+// Reconciler polls Kraken for order updates of trades stored in the database.
+type Reconciler struct {
+	client *kraken.Kraken
+	db     *database.DB
+
+	// PnLMethod is the cost method used to update realized PnL when new fills arrive.
+	PnLMethod database.CostMethod
+
+	// Notifier, if set, is told about partially filled, filled, canceled and expired orders.
+	Notifier notify.Notifier
+	// Notes, if set, adds the fill details and a chart to the notifications of filled orders.
+	Notes *report.Notes
+
+	pairs map[string]kraken.AssetPairInfo // Asset pair info cache, keyed by the pair as stored in trades.
+}
+
+// New creates a Reconciler.
+func New(k *kraken.Kraken, db *database.DB) *Reconciler {
+	return &Reconciler{
+		client:    k,
+		db:        db,
+		PnLMethod: database.FIFO,
+		pairs:     make(map[string]kraken.AssetPairInfo),
+	}
+}
+
+// Run reconciles every interval until ctx is cancelled.
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Sync(); err != nil {
+			fmt.Printf("Reconcile failed: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync updates all unfinished trades and links new conditional close orders.
+func (r *Reconciler) Sync() error {
+	trades, err := r.db.GetTradesByStatus(unfinishedStatuses...)
+	if err != nil {
+		return fmt.Errorf("loading trades: %w", err)
+	}
+	if len(trades) == 0 {
+		return nil
+	}
+
+	orders, err := r.queryOrders(trades)
+	if err != nil {
+		return fmt.Errorf("querying orders: %w", err)
+	}
+
+	newFills := 0
+	for _, t := range trades {
+		order, ok := orders[t.TxID]
+		if !ok {
+			continue
+		}
+		n, err := r.syncTrade(t, order)
+		if err != nil {
+			fmt.Printf("Reconcile trade %d (%s) failed: %v\n", t.ID, t.TxID, err)
+		}
+		newFills += n
+	}
+
+	if newFills > 0 {
+		if err := r.db.UpdateRealizedPnL(r.PnLMethod); err != nil {
+			fmt.Printf("Updating realized PnL failed: %v\n", err)
+		}
+	}
+
+	return r.linkCloseOrders()
+}
+
+// queryOrders looks up the spot orders of trades. Kraken rejects a whole batch when one
+// txid is invalid, so a rejected batch is queried again one order at a time: orders
+// Kraken refuses are logged and marked "unknown" so they are not polled again, and
+// the other trades still reconcile.
+func (r *Reconciler) queryOrders(trades []database.Trade) (map[string]kraken.OrderInfo, error) {
+	txids := make([]string, len(trades))
+	for i, t := range trades {
+		txids[i] = t.TxID
+	}
+	orders, err := r.client.QueryOrders(txids, true)
+	if err == nil || !kraken.IsKrakenError(err) {
+		return orders, err
+	}
+
+	orders = make(map[string]kraken.OrderInfo)
+	for _, t := range trades {
+		order, err := r.client.QueryOrders([]string{t.TxID}, true)
+		if err != nil && !kraken.IsKrakenError(err) {
+			return nil, err
+		}
+		if err != nil {
+			fmt.Printf("Kraken refused order %s of trade %d, no longer reconciling it: %v\n", t.TxID, t.ID, err)
+			if err := r.db.RecordOrderEvent(t.ID, "unknown", database.SourcePoll, map[string]string{"error": err.Error()}); err != nil {
+				return nil, fmt.Errorf("marking trade %d: %w", t.ID, err)
+			}
+			continue
+		}
+		for id, o := range order {
+			orders[id] = o
+		}
+	}
+	return orders, nil
+}
+
+// syncTrade records new fills and the current status of a single trade.
+// It returns the number of new fills.
+func (r *Reconciler) syncTrade(t database.Trade, order kraken.OrderInfo) (int, error) {
+	newFills := 0
+	if len(order.Trades) > 0 {
+		fills, err := r.client.QueryTrades(order.Trades)
+		if err != nil {
+			return 0, err
+		}
+		for id, fill := range fills {
+			isNew, err := r.db.SaveFill(r.toFill(t, id, fill, order.OFlags))
+			if err != nil {
+				return newFills, err
+			}
+			if isNew {
+				newFills++
+			}
+		}
+	}
+
+	status := tradeStatus(order)
+	if status != t.Status {
+		if err := r.db.RecordOrderEvent(t.ID, status, database.SourcePoll, order); err != nil {
+			return newFills, err
+		}
+	}
+	// Partially filled orders are reported again with every new fill.
+	if status != t.Status || status == "partial" && newFills > 0 {
+		r.notify(t, status, order)
+	}
+	return newFills, nil
+}
+
+// notify reports orders that were filled further or finished since the last sync.
+func (r *Reconciler) notify(t database.Trade, status string, order kraken.OrderInfo) {
+	if r.Notifier == nil {
+		return
+	}
+	e := notify.Event{SignalID: t.SignalID}
+	switch status {
+	case "closed":
+		if r.Notes != nil {
+			e = r.Notes.OrderFilled(t, order)
+			break
+		}
+		e.Type, e.Severity = notify.EventOrderFilled, notify.Info
+		e.Text = fmt.Sprintf("%s @ %s\nTxID: %s", report.FillTitle(t, order.VolExec), order.Price, t.TxID)
+	case "partial":
+		e.Type, e.Severity = notify.EventOrderPartial, notify.Info
+		e.Text = fmt.Sprintf("◐ Order Partially Filled: %s %s of %s %s @ %s\nTxID: %s", t.Type, order.VolExec, t.Volume, t.Pair, order.Price, t.TxID)
+	case "canceled", "expired":
+		e.Type, e.Severity = notify.EventOrderFailed, notify.Warning
+		e.Text = fmt.Sprintf("⚠️ Order %s: %s %s %s (filled %s)\nTxID: %s", status, t.Type, t.Volume, t.Pair, order.VolExec, t.TxID)
+		if order.Reason != "" {
+			e.Text += "\nReason: " + order.Reason
+		}
+	default:
+		return
+	}
+	if err := r.Notifier.Notify(context.Background(), e); err != nil {
+		fmt.Printf("Notifying %s of trade %d failed: %v\n", e.Type, t.ID, err)
+	}
+}
+
+// linkCloseOrders stores conditional close orders whose parent trade is known.
+// Kraken creates them as new orders referring to the parent through refid.
+func (r *Reconciler) linkCloseOrders() error {
+	open, err := r.client.OpenOrders(false)
+	if err != nil {
+		return fmt.Errorf("querying open orders: %w", err)
+	}
+
+	for txid, order := range open {
+		if order.RefID == "" {
+			continue
+		}
+		known, err := r.db.GetTradeByTxID(txid)
+		if err != nil || known != nil {
+			continue
+		}
+		parent, err := r.db.GetTradeByTxID(order.RefID)
+		if err != nil || parent == nil {
+			continue
+		}
+
+		price := order.Descr.Price
+		if price == "" || price == "0" {
+			price = order.StopPrice
+		}
+		status := tradeStatus(order)
+		id, err := r.db.SaveCloseTrade(*parent, order.Descr.OrderType, order.Vol, price, txid, status)
+		if err != nil {
+			return fmt.Errorf("saving close order %s: %w", txid, err)
+		}
+		if err := r.db.RecordOrderEvent(id, status, database.SourcePoll, order); err != nil {
+			return fmt.Errorf("recording close order %s: %w", txid, err)
+		}
+		fmt.Printf("Linked close order %s to trade %d.\n", txid, parent.ID)
+	}
+	return nil
+}
+
+// toFill converts a Kraken trade into a fill of the given trade. Kraken charges
+// fees in the quote currency unless the order was placed with the "fcib" flag.
+func (r *Reconciler) toFill(t database.Trade, krakenTradeID string, info kraken.TradeInfo, oflags string) database.Fill {
+	price, _ := strconv.ParseFloat(info.Price, 64)
+	vol, _ := strconv.ParseFloat(info.Vol, 64)
+	cost, _ := strconv.ParseFloat(info.Cost, 64)
+	fee, _ := strconv.ParseFloat(info.Fee, 64)
+
+	feeCurrency := ""
+	if pair, err := r.assetPair(t.Pair); err == nil {
+		feeCurrency = pair.Quote
+		if strings.Contains(oflags, "fcib") {
+			feeCurrency = pair.Base
+		}
+	}
+
+	return database.Fill{
+		TradeID:       t.ID,
+		KrakenTradeID: krakenTradeID,
+		Price:         price,
+		Volume:        vol,
+		Cost:          cost,
+		Fee:           fee,
+		FeeCurrency:   feeCurrency,
+		ExecutedAt:    kraken.Timestamp(info.Time),
+	}
+}
+
+func (r *Reconciler) assetPair(pair string) (kraken.AssetPairInfo, error) {
+	if info, ok := r.pairs[pair]; ok {
+		return info, nil
+	}
+	pairs, err := r.client.AssetPairs(pair)
+	if err != nil {
+		return kraken.AssetPairInfo{}, err
+	}
+	for _, info := range pairs {
+		r.pairs[pair] = info
+		return info, nil
+	}
+	return kraken.AssetPairInfo{}, fmt.Errorf("unknown pair %s", pair)
+}
+
+// tradeStatus maps a Kraken order status to a trade status. Open orders with
+// executed volume are reported as "partial".
+func tradeStatus(order kraken.OrderInfo) string {
+	if order.Status == "open" {
+		if exec, _ := strconv.ParseFloat(order.VolExec, 64); exec > 0 {
+			return "partial"
+		}
+	}
+	return order.Status
+}
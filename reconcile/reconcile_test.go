@@ -0,0 +1,218 @@
+package reconcile
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tvwh2k/database"
+	"tvwh2k/kraken"
+	"tvwh2k/notify"
+)
+
+func TestSyncRecordsFillsAndCloseOrders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/0/private/QueryOrders":
+			w.Write([]byte(`{"error":[],"result":{"OPARENT":{"status":"closed","vol":"1.0","vol_exec":"1.0","oflags":"fciq","trades":["T1","T2"]}}}`))
+		case "/0/private/QueryTrades":
+			w.Write([]byte(`{"error":[],"result":{
+				"T1":{"ordertxid":"OPARENT","pair":"XXBTZUSD","time":1700000000.5,"type":"buy","price":"100","cost":"25","fee":"0.1","vol":"0.25"},
+				"T2":{"ordertxid":"OPARENT","pair":"XXBTZUSD","time":1700000001,"type":"buy","price":"104","cost":"78","fee":"0.3","vol":"0.75"}}}`))
+		case "/0/public/AssetPairs":
+			w.Write([]byte(`{"error":[],"result":{"XXBTZUSD":{"altname":"XBTUSD","base":"XXBT","quote":"ZUSD"}}}`))
+		case "/0/private/OpenOrders":
+			w.Write([]byte(`{"error":[],"result":{"open":{"OCLOSE":{"refid":"OPARENT","status":"open","vol":"1.0","vol_exec":"0","stopprice":"90","descr":{"ordertype":"stop-loss","price":"90"}}}}}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	k, err := kraken.NewClient("key", "c2VjcmV0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.SetBaseURL(server.URL)
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	parentID, err := db.SaveTrade(1, "XBTUSD", "buy", "market", "1.0", "", "OPARENT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordOrderEvent(parentID, "open", database.SourceWebhook, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	r := New(k, db)
+	if err := r.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	// A second sync must not duplicate fills or close orders.
+	if err := r.Sync(); err != nil {
+		t.Fatalf("second Sync: %v", err)
+	}
+
+	parent, err := db.GetTradeByTxID("OPARENT")
+	if err != nil || parent == nil {
+		t.Fatalf("parent trade: %v", err)
+	}
+	if parent.Status != "closed" || parent.ExecutedVolume != 1 || parent.AvgPrice != 103 {
+		t.Fatalf("parent = status %s, executed %v, avg %v", parent.Status, parent.ExecutedVolume, parent.AvgPrice)
+	}
+	events, err := db.GetOrderEvents(parentID)
+	if err != nil || len(events) != 2 {
+		t.Fatalf("events = %+v, err %v; want open and closed", events, err)
+	}
+	if events[0].Status != "open" || events[0].Source != database.SourceWebhook ||
+		events[1].Status != "closed" || events[1].Source != database.SourcePoll || len(events[1].Data) == 0 {
+		t.Fatalf("events = %+v", events)
+	}
+	fills, err := db.GetFills(parentID)
+	if err != nil || len(fills) != 2 || fills[0].FeeCurrency != "ZUSD" {
+		t.Fatalf("fills = %+v, err %v", fills, err)
+	}
+
+	closeTrade, err := db.GetTradeByTxID("OCLOSE")
+	if err != nil || closeTrade == nil {
+		t.Fatalf("close trade: %v", err)
+	}
+	if closeTrade.ParentTradeID == nil || *closeTrade.ParentTradeID != parentID || closeTrade.Type != "sell" {
+		t.Fatalf("close trade = %+v", closeTrade)
+	}
+}
+
+// notifyRecorder is a notifier that records the events it receives.
+type notifyRecorder []notify.Event
+
+func (r *notifyRecorder) Notify(ctx context.Context, e notify.Event) error {
+	*r = append(*r, e)
+	return nil
+}
+
+func TestSyncNotifiesEveryPartialFill(t *testing.T) {
+	trades := []string{`"T1":{"price":"100","cost":"25","fee":"0.1","vol":"0.25","time":1700000000}`}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids := make([]string, len(trades))
+		for i, tr := range trades {
+			ids[i] = tr[:4]
+		}
+		switch r.URL.Path {
+		case "/0/private/QueryOrders":
+			w.Write([]byte(`{"error":[],"result":{"OPARENT":{"status":"open","vol":"1.0","vol_exec":"0.5","trades":[` + strings.Join(ids, ",") + `]}}}`))
+		case "/0/private/QueryTrades":
+			w.Write([]byte(`{"error":[],"result":{` + strings.Join(trades, ",") + `}}`))
+		case "/0/public/AssetPairs":
+			w.Write([]byte(`{"error":[],"result":{"XXBTZUSD":{"altname":"XBTUSD","base":"XXBT","quote":"ZUSD"}}}`))
+		case "/0/private/OpenOrders":
+			w.Write([]byte(`{"error":[],"result":{"open":{}}}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	k, err := kraken.NewClient("key", "c2VjcmV0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.SetBaseURL(server.URL)
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.SaveTrade(1, "XBTUSD", "buy", "limit", "1.0", "100", "OPARENT"); err != nil {
+		t.Fatal(err)
+	}
+
+	var events notifyRecorder
+	r := New(k, db)
+	r.Notifier = &events
+	sync := func() {
+		t.Helper()
+		if err := r.Sync(); err != nil {
+			t.Fatalf("Sync: %v", err)
+		}
+	}
+	sync()
+	trades = append(trades, `"T2":{"price":"101","cost":"25.25","fee":"0.1","vol":"0.25","time":1700000001}`)
+	sync()
+	sync() // No new fills
+
+	if len(events) != 2 || events[0].Type != notify.EventOrderPartial || events[1].Type != notify.EventOrderPartial {
+		t.Fatalf("events = %+v, want two partial fills", events)
+	}
+}
+
+func TestSyncSkipsOrdersKrakenRefuses(t *testing.T) {
+	var queried []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/0/private/QueryOrders":
+			txid := r.PostForm.Get("txid")
+			queried = append(queried, txid)
+			if strings.Contains(txid, "e5f7a1c2-") {
+				w.Write([]byte(`{"error":["EOrder:Invalid order"]}`))
+				return
+			}
+			w.Write([]byte(`{"error":[],"result":{"OSPOT":{"status":"canceled","vol":"1.0","vol_exec":"0"}}}`))
+		case "/0/private/OpenOrders":
+			w.Write([]byte(`{"error":[],"result":{"open":{}}}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	k, err := kraken.NewClient("key", "c2VjcmV0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.SetBaseURL(server.URL)
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// A Kraken Futures order ID stored as a trade, and one stored as a futures order.
+	if _, err := db.SaveTrade(1, "PF_XBTUSD", "buy", "market", "1", "", "e5f7a1c2-0000-4000-8000-000000000001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.SaveFuturesOrder(database.FuturesOrder{SignalID: 1, Symbol: "PF_XBTUSD", Side: "buy", OrderID: "e5f7a1c2-0000-4000-8000-000000000002"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SaveTrade(1, "XBTUSD", "buy", "limit", "1.0", "100", "OSPOT"); err != nil {
+		t.Fatal(err)
+	}
+
+	r := New(k, db)
+	if err := r.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	spot, err := db.GetTradeByTxID("OSPOT")
+	if err != nil || spot == nil || spot.Status != "canceled" {
+		t.Fatalf("spot trade = %+v, err %v", spot, err)
+	}
+	futures, err := db.GetTradeByTxID("e5f7a1c2-0000-4000-8000-000000000001")
+	if err != nil || futures == nil || futures.Status != "unknown" {
+		t.Fatalf("futures trade = %+v, err %v", futures, err)
+	}
+
+	// Neither is queried again.
+	queried = nil
+	if err := r.Sync(); err != nil {
+		t.Fatalf("second Sync: %v", err)
+	}
+	if len(queried) != 0 {
+		t.Errorf("queried %q", queried)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"tvwh2k/config"
+	"tvwh2k/handler"
+	"tvwh2k/kraken"
+	"tvwh2k/notify"
+)
+
+// reloader applies the settings that can change without a restart: test mode, the margin
+// guard, confirmation mode, the Telegram topics and the notification rules. On SIGHUP it
+// loads the configuration again; secrets and the other settings need a restart.
+type reloader struct {
+	cfg      *config.Config // Configuration in effect
+	h        *handler.WebhookHandler
+	k        *kraken.Kraken // Optional
+	notifier *notify.Router
+	telegram *notify.Telegram // Optional
+}
+
+// Run reloads the configuration on every SIGHUP until ctx is cancelled.
+func (r *reloader) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.reload()
+		}
+	}
+}
+
+// reload loads and applies the configuration. An invalid configuration is not applied.
+func (r *reloader) reload() {
+	cfg, err := config.Load()
+	if err == nil {
+		err = r.apply(cfg)
+	}
+	if err != nil {
+		fmt.Printf("Reload failed, keeping the current configuration: %v\n", err)
+		return
+	}
+	for _, section := range cfg.RestartRequired(r.cfg) {
+		fmt.Printf("Warning: changes to %s take effect after a restart.\n", section)
+	}
+	// The confirmation chat stays in effect until a restart, so a later reload keeps it too.
+	cfg.Telegram.ChatID = r.cfg.Telegram.ChatID
+	r.cfg = cfg
+	fmt.Println("Configuration reloaded.")
+}
+
+// apply sets the reloadable settings of cfg. Nothing is changed if it fails.
+func (r *reloader) apply(cfg *config.Config) error {
+	rules, err := notify.ParseRules(cfg.Notify.Rules)
+	if err == nil {
+		err = r.notifier.SetRules(rules)
+	}
+	if err != nil {
+		return fmt.Errorf("notify.rules (NOTIFY_RULES): %w", err)
+	}
+
+	r.h.SetTestMode(cfg.Kraken.TestMode)
+	if r.k != nil {
+		r.k.SetMinMarginLevel(cfg.Kraken.MinMarginLevel)
+	}
+	// chat_id needs a restart: confirmations stay in the chat the bot was started with.
+	r.h.SetConfirmation(r.cfg.Telegram.ChatID, cfg.ConfirmStrategies(), cfg.Telegram.ConfirmTimeout)
+	if r.telegram != nil {
+		r.telegram.SetTopics(cfg.Topics())
+	}
+	return nil
+}
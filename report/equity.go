@@ -0,0 +1,60 @@
+// Package report takes daily equity snapshots and builds the daily and weekly
+// performance summaries sent to Telegram.
+package report
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"tvwh2k/database"
+	"tvwh2k/kraken"
+)
+
+// Snapshot values all balances of the Kraken account in currency, adds the
+// unrealized PnL of open margin positions and stores the result as taken at at.
+// Balances that cannot be valued (no ticker against currency) are left out.
+func Snapshot(k *kraken.Kraken, db *database.DB, currency string, at time.Time) (*database.EquitySnapshot, error) {
+	balances, err := k.GetBalance()
+	if err != nil {
+		return nil, fmt.Errorf("loading balances: %w", err)
+	}
+
+	s := &database.EquitySnapshot{
+		TakenAt:  at.UTC(),
+		Currency: currency,
+		Balances: make(map[string]float64),
+	}
+	for asset, amountStr := range *balances {
+		amount, err := strconv.ParseFloat(amountStr, 64)
+		if err != nil || amount == 0 {
+			continue
+		}
+		code := kraken.AssetCode(asset)
+		price := 1.0
+		if code != currency {
+			if price, err = k.LastPrice(code + currency); err != nil {
+				fmt.Printf("Equity snapshot: cannot value %s in %s: %v\n", asset, currency, err)
+				continue
+			}
+		}
+		value := amount * price
+		s.Balances[asset] = value
+		s.BalanceValue += value
+	}
+
+	tb, err := k.GetTradeBalance(currency)
+	if err != nil {
+		return nil, fmt.Errorf("loading trade balance: %w", err)
+	}
+	if tb.UnrealizedNetPNL != "" {
+		if s.UnrealizedPnL, err = strconv.ParseFloat(tb.UnrealizedNetPNL, 64); err != nil {
+			return nil, fmt.Errorf("invalid unrealized PnL %q: %w", tb.UnrealizedNetPNL, err)
+		}
+	}
+	s.Equity = s.BalanceValue + s.UnrealizedPnL
+
+	if s.ID, err = db.SaveEquitySnapshot(*s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
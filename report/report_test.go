@@ -0,0 +1,187 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"tvwh2k/database"
+	"tvwh2k/kraken"
+	"tvwh2k/notify"
+)
+
+func TestSchedulerRunAt(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/0/private/Balance":
+			w.Write([]byte(`{"error":[],"result":{"ZUSD":"1000.0","XXBT":"0.5","DOT.S":"0.0"}}`))
+		case "/0/public/Ticker":
+			if r.URL.Query().Get("pair") != "XBTUSD" {
+				t.Errorf("unexpected ticker pair %s", r.URL.Query().Get("pair"))
+			}
+			w.Write([]byte(`{"error":[],"result":{"XXBTZUSD":{"c":["40000.0","0.1"]}}}`))
+		case "/0/private/TradeBalance":
+			w.Write([]byte(`{"error":[],"result":{"e":"21000","n":"-50.5"}}`))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	k, err := kraken.NewClient("key", "c2VjcmV0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.SetBaseURL(server.URL)
+
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// A winning and a losing round trip on the previous day.
+	day := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	signalID, _ := db.SaveSignal("XBTUSD", "buy", "trend", nil)
+	for i, f := range []struct {
+		side  string
+		price float64
+	}{{"buy", 100}, {"sell", 120}, {"buy", 100}, {"sell", 90}} {
+		tradeID, err := db.SaveTrade(signalID, "XBTUSD", f.side, "market", "1", "", "O"+string(rune('A'+i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fill := database.Fill{TradeID: tradeID, KrakenTradeID: "T" + string(rune('A'+i)), Price: f.price, Volume: 1, Cost: f.price,
+			Fee: 0.5, FeeCurrency: "ZUSD", ExecutedAt: day.Add(time.Duration(i+1) * time.Hour)}
+		if _, err := db.SaveFill(fill); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.SaveEquitySnapshot(database.EquitySnapshot{TakenAt: day, Currency: "USD", Equity: 20000}); err != nil {
+		t.Fatal(err)
+	}
+
+	var sent []string
+	s := NewScheduler(k, db)
+	s.Send = func(text string) error {
+		sent = append(sent, text)
+		return nil
+	}
+	at := day.AddDate(0, 0, 1) // A Monday: daily and weekly summary
+	if err := s.RunAt(at); err != nil {
+		t.Fatalf("RunAt: %v", err)
+	}
+
+	snap, err := db.GetEquityAt(at)
+	if err != nil || snap == nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if snap.BalanceValue != 21000 || snap.UnrealizedPnL != -50.5 || snap.Equity != 20949.5 {
+		t.Errorf("got snapshot %+v", snap)
+	}
+
+	if len(sent) != 2 {
+		t.Fatalf("sent %d summaries, want daily and weekly", len(sent))
+	}
+	for _, want := range []string{"Daily summary 2024-01-07", "PnL: +8.00 (fees 2 ZUSD)", "Win rate: 50% (1/2)",
+		"Equity: 20000.00 → 20949.50 USD", "trend: +8.00 over 2 trades", "best #2 XBTUSD +19.00, worst #4 XBTUSD -11.00"} {
+		if !strings.Contains(sent[0], want) {
+			t.Errorf("daily summary misses %q:\n%s", want, sent[0])
+		}
+	}
+	if !strings.HasPrefix(sent[1], "📊 Weekly summary 2024-01-01 – 2024-01-07") {
+		t.Errorf("unexpected weekly summary:\n%s", sent[1])
+	}
+}
+
+func TestSchedulerNext(t *testing.T) {
+	s := &Scheduler{Hour: 6}
+	now := time.Date(2024, 1, 1, 5, 30, 0, 0, time.UTC)
+	if got := s.next(now); !got.Equal(time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("next(%s) = %s", now, got)
+	}
+	now = time.Date(2024, 1, 1, 6, 0, 0, 0, time.UTC)
+	if got := s.next(now); !got.Equal(time.Date(2024, 1, 2, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("next(%s) = %s", now, got)
+	}
+}
+
+func TestNotesOrderFilled(t *testing.T) {
+	start := time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/0/public/OHLC" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var rows []string
+		for i := 0; i < 4; i++ {
+			ts := start.Add(time.Duration(i) * time.Hour).Unix()
+			rows = append(rows, fmt.Sprintf(`[%d,"100","125","95","%d","110","1",3]`, ts, 100+i*5))
+		}
+		fmt.Fprintf(w, `{"error":[],"result":{"XXBTZUSD":[%s],"last":0}}`, strings.Join(rows, ","))
+	}))
+	defer server.Close()
+	k, err := kraken.NewClient("key", "c2VjcmV0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.SetBaseURL(server.URL)
+
+	db, err := database.InitDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Buy at 100, then sell at 120 an hour and a half later.
+	var sell database.Trade
+	for i, f := range []struct {
+		side  string
+		price float64
+	}{{"buy", 100}, {"sell", 120}} {
+		tradeID, err := db.SaveTrade(0, "XBTUSD", f.side, "market", "1", "", "O"+f.side)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fill := database.Fill{TradeID: tradeID, KrakenTradeID: "T" + f.side, Price: f.price, Volume: 1, Cost: f.price,
+			Fee: 0.5, ExecutedAt: start.Add(time.Duration(i)*90*time.Minute + time.Minute)}
+		if _, err := db.SaveFill(fill); err != nil {
+			t.Fatal(err)
+		}
+		if f.side == "sell" {
+			trade, _ := db.GetTrade(tradeID)
+			sell = *trade
+		}
+	}
+
+	n := NewNotes(k, db)
+	n.Charts = true
+	n.ChartInterval = 60
+	closed := float64(start.Add(91 * time.Minute).Unix())
+	e := n.OrderFilled(sell, kraken.OrderInfo{Status: "closed", VolExec: "1", Price: "120", Cost: "120", Fee: "0.5", CloseTm: closed})
+
+	fields := map[string]string{}
+	for _, f := range e.Fields {
+		fields[f.Name] = f.Value
+	}
+	// Cost basis 100.5 including the buy fee, proceeds 119.5 net of the sell fee.
+	if fields["Realized PnL"] != "+19.00 (+18.91%)" || fields["Duration"] != "1h 30m" || fields["Position"] != "Flat" {
+		t.Errorf("fields = %v", fields)
+	}
+	if !strings.HasPrefix(e.Title, "✅ Order Filled: sell 1 XBTUSD") || e.Type != notify.EventOrderFilled {
+		t.Errorf("event = %+v", e)
+	}
+	if _, err := png.Decode(bytes.NewReader(e.Chart)); err != nil {
+		t.Errorf("chart is not a PNG: %v", err)
+	}
+}
+
+func TestFeesText(t *testing.T) {
+	got := feesText(map[string]float64{"ZUSD": 0.1 + 0.2, "XXBT": 0.00012})
+	if want := "0.00012 XXBT, 0.3 ZUSD"; got != want {
+		t.Errorf("feesText = %q, want %q", got, want)
+	}
+}
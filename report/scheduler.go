@@ -0,0 +1,108 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"tvwh2k/database"
+	"tvwh2k/kraken"
+)
+
+// Scheduler takes an equity snapshot every day at Hour (UTC) and sends the daily
+// summary, plus the weekly summary on WeeklyOn.
+type Scheduler struct {
+	k  *kraken.Kraken // Optional; without it no snapshots are taken
+	db *database.DB
+
+	Currency string              // Currency equity is valued in
+	Hour     int                 // UTC hour of the daily run
+	WeeklyOn time.Weekday        // Day the weekly summary is sent
+	Method   database.CostMethod // Cost method for realized PnL
+
+	// Send delivers a summary; nil only logs it.
+	Send func(text string) error
+
+	now func() time.Time
+}
+
+// NewScheduler creates a scheduler running at midnight UTC, valuing equity in USD
+// and sending the weekly summary on Mondays.
+func NewScheduler(k *kraken.Kraken, db *database.DB) *Scheduler {
+	return &Scheduler{
+		k:        k,
+		db:       db,
+		Currency: "USD",
+		WeeklyOn: time.Monday,
+		Method:   database.FIFO,
+		now:      time.Now,
+	}
+}
+
+// Run performs the daily run at every scheduled time until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		at := s.next(s.now())
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := s.RunAt(at); err != nil {
+			fmt.Printf("Daily report failed: %v\n", err)
+		}
+	}
+}
+
+// next returns the first scheduled time after t.
+func (s *Scheduler) next(t time.Time) time.Time {
+	t = t.UTC()
+	at := time.Date(t.Year(), t.Month(), t.Day(), s.Hour, 0, 0, 0, time.UTC)
+	if !at.After(t) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at
+}
+
+// RunAt takes the snapshot for at and sends the summaries of the day (and week) ending at at.
+func (s *Scheduler) RunAt(at time.Time) error {
+	if s.k != nil {
+		snap, err := Snapshot(s.k, s.db, s.Currency, at)
+		if err != nil {
+			// Still send the summary, just without the closing equity.
+			fmt.Printf("Equity snapshot failed: %v\n", err)
+		} else {
+			fmt.Printf("Equity snapshot: %.2f %s.\n", snap.Equity, snap.Currency)
+		}
+	}
+
+	from := at.AddDate(0, 0, -1)
+	if err := s.send(fmt.Sprintf("Daily summary %s", from.Format("2006-01-02")), from, at); err != nil {
+		return err
+	}
+	if at.Weekday() == s.WeeklyOn {
+		from := at.AddDate(0, 0, -7)
+		title := fmt.Sprintf("Weekly summary %s – %s", from.Format("2006-01-02"), at.AddDate(0, 0, -1).Format("2006-01-02"))
+		if err := s.send(title, from, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) send(title string, from, to time.Time) error {
+	summary, err := Summarize(s.db, title, from, to, s.Method)
+	if err != nil {
+		return fmt.Errorf("%s: %w", title, err)
+	}
+	text := summary.Text()
+	if s.Send == nil {
+		fmt.Println(text)
+		return nil
+	}
+	if err := s.Send(text); err != nil {
+		return fmt.Errorf("sending %s: %w", title, err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"tvwh2k/database"
+)
+
+// Summary describes the performance over a period.
+type Summary struct {
+	Title string    `json:"title"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+
+	database.ActivityCounts
+	PnL        float64           `json:"pnl"` // Realized, net of fees
+	Wins       int               `json:"wins"`
+	Losses     int               `json:"losses"`
+	Strategies []StrategySummary `json:"strategies"`
+
+	Currency    string   `json:"currency,omitempty"`
+	StartEquity *float64 `json:"start_equity,omitempty"` // Latest snapshot at the start of the period
+	EndEquity   *float64 `json:"end_equity,omitempty"`   // Latest snapshot at the end of the period
+}
+
+// WinRate is the share of winning closing trades, 0 if nothing was closed.
+func (s *Summary) WinRate() float64 {
+	if s.Wins+s.Losses == 0 {
+		return 0
+	}
+	return float64(s.Wins) / float64(s.Wins+s.Losses)
+}
+
+// StrategySummary is the realized PnL of one strategy with its best and worst trade.
+type StrategySummary struct {
+	Strategy string     `json:"strategy"`
+	PnL      float64    `json:"pnl"`
+	Trades   int        `json:"trades"` // Closing trades
+	Best     TradeTotal `json:"best"`
+	Worst    TradeTotal `json:"worst"`
+}
+
+// TradeTotal is the realized PnL of a closing trade.
+type TradeTotal struct {
+	TradeID int64   `json:"trade_id"`
+	Pair    string  `json:"pair"`
+	PnL     float64 `json:"pnl"`
+}
+
+// Summarize builds the summary of [from, to). Realized PnL is matched with method.
+func Summarize(db *database.DB, title string, from, to time.Time, method database.CostMethod) (*Summary, error) {
+	s := &Summary{Title: title, From: from, To: to, Strategies: []StrategySummary{}}
+
+	var err error
+	if s.ActivityCounts, err = db.CountActivity(from, to); err != nil {
+		return nil, err
+	}
+
+	pnl, err := db.ComputePnL(method)
+	if err != nil {
+		return nil, err
+	}
+	// A trade can close several lots; its PnL is their sum.
+	trades := make(map[int64]*TradeTotal)
+	strategyOf := make(map[int64]string)
+	var order []int64
+	for _, r := range pnl.Realized {
+		if r.Closed.Before(from) || !r.Closed.Before(to) {
+			continue
+		}
+		t, ok := trades[r.TradeID]
+		if !ok {
+			t = &TradeTotal{TradeID: r.TradeID, Pair: r.Pair}
+			trades[r.TradeID] = t
+			strategyOf[r.TradeID] = r.Strategy
+			order = append(order, r.TradeID)
+		}
+		t.PnL += r.PnL
+	}
+
+	strategies := make(map[string]*StrategySummary)
+	for _, id := range order {
+		t := trades[id]
+		s.PnL += t.PnL
+		if t.PnL >= 0 {
+			s.Wins++
+		} else {
+			s.Losses++
+		}
+
+		name := strategyOf[id]
+		st, ok := strategies[name]
+		if !ok {
+			st = &StrategySummary{Strategy: name, Best: *t, Worst: *t}
+			strategies[name] = st
+		}
+		st.PnL += t.PnL
+		st.Trades++
+		if t.PnL > st.Best.PnL {
+			st.Best = *t
+		}
+		if t.PnL < st.Worst.PnL {
+			st.Worst = *t
+		}
+	}
+	for _, st := range strategies {
+		s.Strategies = append(s.Strategies, *st)
+	}
+	sort.Slice(s.Strategies, func(i, j int) bool { return s.Strategies[i].Strategy < s.Strategies[j].Strategy })
+
+	start, err := db.GetEquityAt(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := db.GetEquityAt(to)
+	if err != nil {
+		return nil, err
+	}
+	if start != nil && end != nil {
+		s.Currency = end.Currency
+		s.StartEquity, s.EndEquity = &start.Equity, &end.Equity
+	}
+	return s, nil
+}
+
+// Text formats the summary as a Telegram message.
+func (s *Summary) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📊 %s\n", s.Title)
+	fmt.Fprintf(&b, "PnL: %+.2f", s.PnL)
+	if len(s.Fees) > 0 {
+		fmt.Fprintf(&b, " (fees %s)", feesText(s.Fees))
+	}
+	b.WriteString("\n")
+	fmt.Fprintf(&b, "Signals: %d, trades: %d\n", s.Signals, s.Trades)
+	if closed := s.Wins + s.Losses; closed > 0 {
+		fmt.Fprintf(&b, "Win rate: %.0f%% (%d/%d)\n", s.WinRate()*100, s.Wins, closed)
+	}
+	if s.StartEquity != nil {
+		fmt.Fprintf(&b, "Equity: %.2f → %.2f %s\n", *s.StartEquity, *s.EndEquity, s.Currency)
+	}
+	for _, st := range s.Strategies {
+		name := st.Strategy
+		if name == "" {
+			name = "(no strategy)"
+		}
+		fmt.Fprintf(&b, "\n%s: %+.2f over %d trades\n", name, st.PnL, st.Trades)
+		fmt.Fprintf(&b, "  best #%d %s %+.2f, worst #%d %s %+.2f\n",
+			st.Best.TradeID, st.Best.Pair, st.Best.PnL, st.Worst.TradeID, st.Worst.Pair, st.Worst.PnL)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// feesText lists fees per currency, e.g. "2 ZUSD, 0.0001 XXBT".
+func feesText(fees map[string]float64) string {
+	currencies := make([]string, 0, len(fees))
+	for currency := range fees {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	parts := make([]string, len(currencies))
+	for i, currency := range currencies {
+		amount := strconv.FormatFloat(math.Round(fees[currency]*1e8)/1e8, 'f', -1, 64)
+		parts[i] = strings.TrimSpace(amount + " " + currency)
+	}
+	return strings.Join(parts, ", ")
+}
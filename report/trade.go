@@ -0,0 +1,267 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"tvwh2k/chart"
+	"tvwh2k/database"
+	"tvwh2k/kraken"
+	"tvwh2k/notify"
+)
+
+// Notes adds the details of placed and filled orders to their notifications: entry,
+// size, notional, fees, take profit and stop loss levels, the running position and, for
+// fills that close a position, the realized PnL and how long the position was held.
+// With Charts set a candle chart with the entries and exits is attached.
+type Notes struct {
+	k  *kraken.Kraken // Optional; needed for market entry prices and charts
+	db *database.DB
+
+	Method        database.CostMethod // Cost method for positions and realized PnL
+	Charts        bool
+	ChartInterval int // Candle interval in minutes
+	ChartCandles  int // Number of candles
+}
+
+// NewNotes creates trade notes without charts. Charts default to the last 24 hours in 15 minute candles.
+func NewNotes(k *kraken.Kraken, db *database.DB) *Notes {
+	return &Notes{
+		k:             k,
+		db:            db,
+		Method:        database.FIFO,
+		ChartInterval: 15,
+		ChartCandles:  96,
+	}
+}
+
+// Order is a spot order as it was placed.
+type Order struct {
+	Strategy  string
+	Pair      string
+	Side      string // buy or sell
+	OrderType string
+	Volume    string
+	Price     string
+
+	// Conditional close order, e.g. stop-loss at ClosePrice
+	CloseOrderType string
+	ClosePrice     string
+}
+
+// OrderPlaced adds the details of a placed order to its notification.
+func (n *Notes) OrderPlaced(e *notify.Event, o Order) {
+	volume, _ := strconv.ParseFloat(o.Volume, 64)
+	entry, _ := strconv.ParseFloat(o.Price, 64)
+	entryText := o.Price
+	if o.OrderType == "" || o.OrderType == "market" || entry == 0 {
+		entry, entryText = 0, "market"
+		if n.k != nil {
+			if last, err := n.k.LastPrice(o.Pair); err == nil {
+				entry, entryText = last, "≈ "+chart.FormatPrice(last)+" (market)"
+			}
+		}
+	}
+
+	e.Fields = append(e.Fields,
+		notify.Field{Name: "Entry", Value: entryText},
+		notify.Field{Name: "Size", Value: o.Volume + " " + o.Pair},
+	)
+	if entry > 0 && volume > 0 {
+		e.Fields = append(e.Fields, notify.Field{Name: "Notional", Value: fmt.Sprintf("%.2f", entry*volume)})
+	}
+
+	var levels []chart.Level
+	if entry > 0 {
+		levels = append(levels, chart.Level{Price: entry, Label: "Entry"})
+	}
+	if price, err := strconv.ParseFloat(o.ClosePrice, 64); err == nil && o.CloseOrderType != "" {
+		level := chart.Level{Price: price, Label: "Close"}
+		name := "Close (" + o.CloseOrderType + ")"
+		switch {
+		case strings.HasPrefix(o.CloseOrderType, "take-profit"):
+			name, level.Label, level.Color = "Take profit", "TP", chart.Blue
+		case strings.HasPrefix(o.CloseOrderType, "stop-loss"):
+			name, level.Label, level.Color = "Stop loss", "SL", chart.Red
+		}
+		value := o.ClosePrice
+		if entry > 0 {
+			value += fmt.Sprintf(" (%+.2f%%)", (price-entry)/entry*100)
+		}
+		e.Fields = append(e.Fields, notify.Field{Name: name, Value: value})
+		levels = append(levels, level)
+	}
+
+	report, err := n.db.ComputePairPnL(n.Method, o.Pair)
+	if err == nil {
+		e.Fields = append(e.Fields, notify.Field{Name: "Position", Value: positionText(report, o.Pair, o.Strategy)})
+	}
+
+	if n.Charts {
+		var extra []chart.Marker
+		if entry > 0 {
+			extra = append(extra, chart.Marker{Time: time.Now(), Price: entry, Side: o.Side})
+		}
+		n.attachChart(e, o.Pair, levels, extra)
+	}
+}
+
+// OrderFilled builds the notification for a spot order that has been filled.
+func (n *Notes) OrderFilled(t database.Trade, order kraken.OrderInfo) notify.Event {
+	e := notify.Event{
+		Type:     notify.EventOrderFilled,
+		Severity: notify.Info,
+		Title:    FillTitle(t, order.VolExec),
+		Text:     "TxID: " + t.TxID,
+		SignalID: t.SignalID,
+		Fields: []notify.Field{
+			{Name: "Average price", Value: order.Price},
+			{Name: "Size", Value: order.VolExec + " " + t.Pair},
+			{Name: "Notional", Value: order.Cost},
+			{Name: "Fees", Value: order.Fee},
+		},
+	}
+
+	var levels []chart.Level
+	strategy := ""
+	if s, err := n.db.GetSignal(t.SignalID); err == nil && s != nil {
+		strategy = s.Strategy
+	}
+	report, err := n.db.ComputePairPnL(n.Method, t.Pair)
+	if err == nil {
+		var pnl, costBasis float64
+		var opened time.Time
+		closes := 0
+		for _, r := range report.Realized {
+			if r.TradeID != t.ID {
+				continue
+			}
+			closes++
+			pnl += r.PnL
+			costBasis += r.CostBasis
+			if opened.IsZero() || r.Opened.Before(opened) {
+				opened = r.Opened
+			}
+		}
+		if closes > 0 {
+			value := fmt.Sprintf("%+.2f", pnl)
+			if costBasis > 0 {
+				value += fmt.Sprintf(" (%+.2f%%)", pnl/costBasis*100)
+			}
+			e.Fields = append(e.Fields, notify.Field{Name: "Realized PnL", Value: value})
+			if closed := executedAt(order); !opened.IsZero() && closed.After(opened) {
+				e.Fields = append(e.Fields, notify.Field{Name: "Duration", Value: formatDuration(closed.Sub(opened))})
+			}
+		}
+		e.Fields = append(e.Fields, notify.Field{Name: "Position", Value: positionText(report, t.Pair, strategy)})
+		if p := findPosition(report, t.Pair, strategy); p != nil {
+			levels = append(levels, chart.Level{Price: p.AvgCost, Label: "Avg"})
+		}
+	}
+
+	if n.Charts {
+		n.attachChart(&e, t.Pair, levels, nil)
+	}
+	return e
+}
+
+// FillTitle describes a filled order; take profit and stop loss orders are named as such.
+func FillTitle(t database.Trade, volume string) string {
+	title := "✅ Order Filled"
+	if t.ParentTradeID != nil {
+		switch {
+		case strings.HasPrefix(t.OrderType, "stop-loss"):
+			title = "🛑 Stopped Out"
+		case strings.HasPrefix(t.OrderType, "take-profit"):
+			title = "🎯 Take Profit Hit"
+		}
+	}
+	return fmt.Sprintf("%s: %s %s %s", title, t.Type, volume, t.Pair)
+}
+
+// attachChart renders the recent candles of pair with the fills in that window as markers.
+// Failures only leave the event without a chart.
+func (n *Notes) attachChart(e *notify.Event, pair string, levels []chart.Level, extra []chart.Marker) {
+	if n.k == nil {
+		return
+	}
+	interval := time.Duration(n.ChartInterval) * time.Minute
+	since := time.Now().Add(-interval * time.Duration(n.ChartCandles))
+	candles, err := n.k.OHLC(pair, n.ChartInterval, since)
+	if err != nil || len(candles) == 0 {
+		fmt.Printf("No chart for %s: %v\n", pair, err)
+		return
+	}
+	if len(candles) > n.ChartCandles {
+		candles = candles[len(candles)-n.ChartCandles:]
+	}
+
+	c := &chart.Chart{
+		Title:   fmt.Sprintf("%s %dm", pair, n.ChartInterval),
+		Candles: candles,
+		Levels:  levels,
+		Markers: extra,
+	}
+	trades, _, err := n.db.QueryTrades(database.Filter{Pair: pair, From: candles[0].Time, Limit: 500})
+	if err == nil {
+		for _, t := range trades {
+			for _, f := range t.Fills {
+				c.Markers = append(c.Markers, chart.Marker{Time: f.ExecutedAt, Price: f.Price, Side: t.Type})
+			}
+		}
+	}
+
+	png, err := c.PNG()
+	if err != nil {
+		fmt.Printf("Rendering chart for %s failed: %v\n", pair, err)
+		return
+	}
+	e.Chart = png
+}
+
+func findPosition(report *database.PnLReport, pair, strategy string) *database.Position {
+	for i := range report.Positions {
+		if report.Positions[i].Pair == pair && report.Positions[i].Strategy == strategy {
+			return &report.Positions[i]
+		}
+	}
+	return nil
+}
+
+// positionText describes the open position of a strategy in a pair, e.g. "Long 0.5 @ 95000".
+func positionText(report *database.PnLReport, pair, strategy string) string {
+	p := findPosition(report, pair, strategy)
+	if p == nil || math.Abs(p.Volume) < 1e-12 {
+		return "Flat"
+	}
+	side := "Long"
+	if p.Side == "sell" {
+		side = "Short"
+	}
+	return fmt.Sprintf("%s %s @ %s", side, strconv.FormatFloat(p.Volume, 'f', -1, 64), chart.FormatPrice(p.AvgCost))
+}
+
+// executedAt is when an order was closed, or now if Kraken did not report it.
+func executedAt(order kraken.OrderInfo) time.Time {
+	if order.CloseTm > 0 {
+		sec, frac := math.Modf(order.CloseTm)
+		return time.Unix(int64(sec), int64(frac*1e9))
+	}
+	return time.Now()
+}
+
+// formatDuration formats a holding period, e.g. "2d 3h", "3h 12m" or "12m".
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days, hours, minutes := int(d/(24*time.Hour)), int(d/time.Hour)%24, int(d/time.Minute)%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
@@ -0,0 +1,289 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pollTimeout is how long getUpdates waits for new updates (long polling).
+const pollTimeout = 30 * time.Second
+
+// Update is an incoming update. Only messages and inline button presses are handled.
+type Update struct {
+	UpdateID      int64          `json:"update_id"`
+	Message       *Message       `json:"message,omitempty"`
+	CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
+}
+
+// CallbackQuery is sent when an inline keyboard button is pressed.
+type CallbackQuery struct {
+	ID      string   `json:"id"`
+	From    *User    `json:"from"`
+	Message *Message `json:"message,omitempty"` // The message with the keyboard
+	Data    string   `json:"data"`
+}
+
+// InlineButton is a button of an inline keyboard. Pressing it sends Data
+// as a callback query; Data has the form "<action>:<argument>".
+type InlineButton struct {
+	Text string `json:"text"`
+	Data string `json:"callback_data"`
+}
+
+// InlineKeyboard is a message keyboard, one slice of buttons per row.
+type InlineKeyboard [][]InlineButton
+
+// CommandFunc handles a bot command. Args are the words after the command;
+// the returned text is sent back to the chat.
+type CommandFunc func(args []string) (string, error)
+
+// CallbackFunc handles an inline button press. Arg is the part of the button data after
+// the action; the returned text is shown to the user as a notification.
+type CallbackFunc func(q *CallbackQuery, arg string) (string, error)
+
+type command struct {
+	help string
+	fn   CommandFunc
+}
+
+// Bot receives commands through long polling or a webhook and answers them.
+// Only messages from allowed chats and users are handled.
+type Bot struct {
+	*Client
+
+	commands     map[string]command
+	callbacks    map[string]CallbackFunc
+	allowedChats map[int64]bool
+	allowedUsers map[int64]bool
+	secret       string // Webhook secret token
+	offset       int64  // Next update ID to fetch
+}
+
+// NewBot creates a bot that calls the Bot API through c.
+func NewBot(c *Client) *Bot {
+	b := &Bot{
+		Client:       c,
+		commands:     make(map[string]command),
+		callbacks:    make(map[string]CallbackFunc),
+		allowedChats: make(map[int64]bool),
+		allowedUsers: make(map[int64]bool),
+	}
+	b.Handle("help", "List the available commands", b.help)
+	return b
+}
+
+// Allow permits commands from the given chats and users. A message is handled
+// when its chat is allowed and, if any users are configured, its sender is too.
+func (b *Bot) Allow(chatIDs, userIDs []int64) {
+	for _, id := range chatIDs {
+		b.allowedChats[id] = true
+	}
+	for _, id := range userIDs {
+		b.allowedUsers[id] = true
+	}
+}
+
+// Handle registers a command, e.g. Handle("status", ...) for /status.
+func (b *Bot) Handle(name, help string, fn CommandFunc) {
+	b.commands[name] = command{help: help, fn: fn}
+}
+
+// HandleCallback registers the handler of inline buttons whose data starts with action.
+func (b *Bot) HandleCallback(action string, fn CallbackFunc) {
+	b.callbacks[action] = fn
+}
+
+func (b *Bot) help(args []string) (string, error) {
+	names := make([]string, 0, len(b.commands))
+	for name := range b.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("Commands:")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "\n/%s - %s", name, b.commands[name].help)
+	}
+	return sb.String(), nil
+}
+
+// Poll fetches updates with long polling and handles them until ctx is cancelled.
+// A configured webhook is removed first, as Telegram does not allow both. An update
+// being handled when ctx is cancelled is completed before Poll returns.
+func (b *Bot) Poll(ctx context.Context) {
+	if err := b.call(ctx, "deleteWebhook", url.Values{}, nil); err != nil {
+		log.Printf("Telegram deleteWebhook failed: %v", err)
+	}
+
+	for ctx.Err() == nil {
+		updates, err := b.getUpdates(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Telegram getUpdates failed: %v", err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(5 * time.Second):
+				}
+			}
+			continue
+		}
+		for _, u := range updates {
+			b.offset = u.UpdateID + 1
+			b.HandleUpdate(u)
+		}
+	}
+
+	// Confirm the handled updates, so they are not handled again after a restart.
+	if b.offset != 0 {
+		params := url.Values{}
+		params.Set("offset", strconv.FormatInt(b.offset, 10))
+		params.Set("timeout", "0")
+		if err := b.call(context.Background(), "getUpdates", params, nil); err != nil {
+			log.Printf("Telegram getUpdates failed: %v", err)
+		}
+	}
+}
+
+func (b *Bot) getUpdates(ctx context.Context) ([]Update, error) {
+	params := url.Values{}
+	params.Set("timeout", strconv.Itoa(int(pollTimeout.Seconds())))
+	params.Set("allowed_updates", `["message","callback_query"]`)
+	if b.offset != 0 {
+		params.Set("offset", strconv.FormatInt(b.offset, 10))
+	}
+
+	var updates []Update
+	if err := b.callTimeout(ctx, "getUpdates", params, &updates, pollTimeout+b.Timeout); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// SetWebhook registers url as webhook; Telegram then posts updates to it instead
+// of serving them through getUpdates. Secret is required and echoed in the
+// X-Telegram-Bot-Api-Secret-Token header of every update.
+func (b *Bot) SetWebhook(webhookURL, secret string) error {
+	if secret == "" {
+		return fmt.Errorf("setWebhook: a secret is required")
+	}
+	b.secret = secret
+	params := url.Values{}
+	params.Set("url", webhookURL)
+	params.Set("allowed_updates", `["message","callback_query"]`)
+	params.Set("secret_token", secret)
+	return b.call(context.Background(), "setWebhook", params, nil)
+}
+
+// ServeHTTP receives updates in webhook mode. Requests without the secret set by
+// SetWebhook are refused.
+func (b *Bot) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+	if b.secret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(b.secret)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var u Update
+	if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	b.HandleUpdate(u)
+}
+
+// HandleUpdate runs the command or button handler in an update and replies with its result.
+func (b *Bot) HandleUpdate(u Update) {
+	if u.CallbackQuery != nil {
+		b.handleCallback(u.CallbackQuery)
+		return
+	}
+
+	m := u.Message
+	if m == nil || !strings.HasPrefix(m.Text, "/") {
+		return
+	}
+	if !b.allowed(m.Chat.ID, m.From) {
+		log.Printf("Ignoring Telegram command from chat %d, user %d", m.Chat.ID, userID(m.From))
+		return
+	}
+
+	fields := strings.Fields(m.Text)
+	// Commands in groups may be addressed as /status@MyBot.
+	name, _, _ := strings.Cut(strings.TrimPrefix(fields[0], "/"), "@")
+
+	var reply string
+	if cmd, ok := b.commands[name]; !ok {
+		reply = fmt.Sprintf("Unknown command /%s, see /help", name)
+	} else if text, err := cmd.fn(fields[1:]); err != nil {
+		reply = fmt.Sprintf("❌ /%s failed: %v", name, err)
+	} else {
+		reply = text
+	}
+
+	if err := b.Reply(m.Chat.ID, reply); err != nil {
+		log.Printf("Telegram reply failed: %v", err)
+	}
+}
+
+func (b *Bot) handleCallback(q *CallbackQuery) {
+	if q.Message == nil || !b.allowed(q.Message.Chat.ID, q.From) {
+		log.Printf("Ignoring Telegram button press from user %d", userID(q.From))
+		b.answerCallback(q.ID, "Not allowed")
+		return
+	}
+
+	action, arg, _ := strings.Cut(q.Data, ":")
+	var answer string
+	if fn, ok := b.callbacks[action]; !ok {
+		answer = "Unknown button"
+	} else if text, err := fn(q, arg); err != nil {
+		answer = fmt.Sprintf("❌ %v", err)
+	} else {
+		answer = text
+	}
+	b.answerCallback(q.ID, answer)
+}
+
+// answerCallback stops the loading indicator of a pressed button and shows text, if any.
+func (b *Bot) answerCallback(queryID, text string) {
+	params := url.Values{}
+	params.Set("callback_query_id", queryID)
+	if text != "" {
+		params.Set("text", text)
+	}
+	if err := b.call(context.Background(), "answerCallbackQuery", params, nil); err != nil {
+		log.Printf("Telegram answerCallbackQuery failed: %v", err)
+	}
+}
+
+func (b *Bot) allowed(chatID int64, from *User) bool {
+	if !b.allowedChats[chatID] {
+		return false
+	}
+	if len(b.allowedUsers) == 0 {
+		return true
+	}
+	return from != nil && b.allowedUsers[from.ID]
+}
+
+func userID(u *User) int64 {
+	if u == nil {
+		return 0
+	}
+	return u.ID
+}
+
+// Reply sends text to a chat.
+func (b *Bot) Reply(chatID int64, text string) error {
+	_, err := b.SendMessage(chatID, text, nil)
+	return err
+}
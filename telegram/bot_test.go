@@ -0,0 +1,107 @@
+package telegram
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestBot(t *testing.T) (*Bot, *fakeAPI) {
+	c, api := newTestClient(t)
+	b := NewBot(c)
+	b.Allow([]int64{42}, nil)
+	b.Handle("echo", "Echo the arguments", func(args []string) (string, error) {
+		return strings.Join(args, " "), nil
+	})
+	return b, api
+}
+
+func TestHandleUpdate(t *testing.T) {
+	b, api := newTestBot(t)
+
+	b.HandleUpdate(Update{Message: &Message{Text: "/echo hello world", Chat: Chat{ID: 42}}})
+	b.HandleUpdate(Update{Message: &Message{Text: "/echo@TestBot hi", Chat: Chat{ID: 42}}})
+	b.HandleUpdate(Update{Message: &Message{Text: "/nope", Chat: Chat{ID: 42}}})
+	b.HandleUpdate(Update{Message: &Message{Text: "not a command", Chat: Chat{ID: 42}}})
+
+	want := []string{"hello world", "hi", "Unknown command /nope, see /help"}
+	if len(api.requests) != len(want) {
+		t.Fatalf("sent %d messages, want %d: %v", len(api.requests), len(want), api.requests)
+	}
+	for i, text := range want {
+		if form := api.requests[i].Form; form.Get("text") != text || form.Get("chat_id") != "42" {
+			t.Errorf("message %d = %v, want %q to chat 42", i, form, text)
+		}
+	}
+}
+
+func TestHandleUpdateAllowlist(t *testing.T) {
+	b, api := newTestBot(t)
+
+	b.HandleUpdate(Update{Message: &Message{Text: "/echo x", Chat: Chat{ID: 7}}})
+	if len(api.sent()) != 0 {
+		t.Fatalf("command from a chat that is not allowed was answered: %v", api.sent())
+	}
+
+	b.Allow(nil, []int64{100})
+	b.HandleUpdate(Update{Message: &Message{Text: "/echo x", Chat: Chat{ID: 42}, From: &User{ID: 200}}})
+	if len(api.sent()) != 0 {
+		t.Fatalf("command from a user that is not allowed was answered: %v", api.sent())
+	}
+	b.HandleUpdate(Update{Message: &Message{Text: "/echo x", Chat: Chat{ID: 42}, From: &User{ID: 100}}})
+	if len(api.sent()) != 1 {
+		t.Fatalf("command from an allowed user was not answered")
+	}
+}
+
+func TestServeHTTPSecret(t *testing.T) {
+	b, api := newTestBot(t)
+
+	// Without a secret configured every request is refused.
+	body := `{"update_id":1,"message":{"text":"/echo ok","chat":{"id":42}}}`
+	rec := httptest.NewRecorder()
+	b.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(body)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status without configured secret = %d, want 401", rec.Code)
+	}
+
+	b.secret = "s3cret"
+	rec = httptest.NewRecorder()
+	b.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(body)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("status without secret = %d, want 401", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(body))
+	req.Header.Set("X-Telegram-Bot-Api-Secret-Token", "s3cret")
+	rec = httptest.NewRecorder()
+	b.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || len(api.sent()) != 1 || api.sent()[0] != "ok" {
+		t.Errorf("status = %d, sent = %v", rec.Code, api.sent())
+	}
+}
+
+func TestHandleCallback(t *testing.T) {
+	b, api := newTestBot(t)
+	var got string
+	b.HandleCallback("approve", func(q *CallbackQuery, arg string) (string, error) {
+		got = arg
+		return "Approved", nil
+	})
+
+	press := func(chatID int64, data string) {
+		b.HandleUpdate(Update{CallbackQuery: &CallbackQuery{ID: "q1", Data: data, Message: &Message{Chat: Chat{ID: chatID}}}})
+	}
+	press(7, "approve:12")
+	if got != "" {
+		t.Fatalf("button press from a chat that is not allowed was handled")
+	}
+	press(42, "approve:12")
+	if got != "12" {
+		t.Fatalf("arg = %q, want 12", got)
+	}
+	if len(api.sent()) != 0 {
+		t.Errorf("button presses are answered with answerCallbackQuery, not messages: %v", api.sent())
+	}
+}
@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// EscapeHTML escapes text for messages sent with the HTML parse mode.
+func EscapeHTML(text string) string {
+	return htmlEscaper.Replace(text)
+}
+
+// markdownV2Special are the characters that must be escaped in MarkdownV2 text.
+const markdownV2Special = "\\_*[]()~`>#+-=|{}.!"
+
+// EscapeMarkdownV2 escapes text for messages sent with the MarkdownV2 parse mode.
+func EscapeMarkdownV2(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune(markdownV2Special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// SplitMessage splits text into parts of at most limit UTF-16 code units, the unit
+// Telegram measures messages in. It splits at the last line break of a part, or the last
+// space if the part has no line break, and only cuts words that are longer than limit.
+func SplitMessage(text string, limit int) []string {
+	if limit <= 0 {
+		return []string{text}
+	}
+	var parts []string
+	for textLength(text) > limit {
+		// Find the longest prefix within the limit.
+		end, units := 0, 0
+		for end < len(text) {
+			r, size := utf8.DecodeRuneInString(text[end:])
+			if units+runeLength(r) > limit {
+				break
+			}
+			units += runeLength(r)
+			end += size
+		}
+
+		cut, next := end, end
+		if i := strings.LastIndexByte(text[:end], '\n'); i > 0 {
+			cut, next = i, i+1
+		} else if i := strings.LastIndexByte(text[:end], ' '); i > 0 {
+			cut, next = i, i+1
+		}
+		parts = append(parts, text[:cut])
+		text = text[next:]
+	}
+	return append(parts, text)
+}
+
+func textLength(text string) int {
+	n := 0
+	for _, r := range text {
+		n += runeLength(r)
+	}
+	return n
+}
+
+// runeLength is the number of UTF-16 code units of r; invalid runes count as one.
+func runeLength(r rune) int {
+	if n := utf16.RuneLen(r); n > 0 {
+		return n
+	}
+	return 1
+}
@@ -1,58 +1,410 @@
 package telegram
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"mime/multipart"
 	"net/http"
 	"net/url"
-	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-func SendMessage(text string, chatId int64) (string, error) {
+// APIBaseURL is the Telegram Bot API server.
+const APIBaseURL = "https://api.telegram.org"
 
-	log.Printf("Sending message: %s, to chat id %d", text, chatId)
-	var apiUrl string = "https://api.telegram.org/bot" + os.Getenv("TELEGRAM_BOT_TOKEN") + "/sendMessage"
+// MaxMessageLength is the longest text Telegram accepts in one message, in UTF-16 code units.
+const MaxMessageLength = 4096
 
-	message := url.Values{
-		"text":    {text},
-		"chat_id": {strconv.FormatInt(chatId, 10)},
+// ParseMode selects how Telegram formats message text.
+type ParseMode string
+
+const (
+	PlainText  ParseMode = ""
+	HTML       ParseMode = "HTML"
+	MarkdownV2 ParseMode = "MarkdownV2"
+)
+
+// Telegram's flood limits: about one message per second per chat,
+// 20 messages per minute per group and 30 messages per second overall.
+const (
+	defaultChatInterval   = time.Second
+	defaultGroupInterval  = 3 * time.Second
+	defaultGlobalInterval = time.Second / 30
+)
+
+// Client calls the Telegram Bot API. It waits between messages to stay within the
+// flood limits and retries requests that are answered with 429 Too Many Requests.
+// A Client is safe for concurrent use.
+type Client struct {
+	token      string
+	baseURL    string
+	httpClient *http.Client
+
+	Timeout    time.Duration // Per request, 10 seconds by default
+	MaxRetries int           // Retries after 429 and 5xx responses
+
+	// Minimum time between messages to one private chat, to one group (negative
+	// chat ID) and overall. Zero disables the limit.
+	ChatInterval   time.Duration
+	GroupInterval  time.Duration
+	GlobalInterval time.Duration
+
+	mu         sync.Mutex
+	nextGlobal time.Time
+	nextChat   map[int64]time.Time
+}
+
+// NewClient creates a client for the given bot token.
+func NewClient(token string) *Client {
+	return &Client{
+		token:          token,
+		baseURL:        APIBaseURL,
+		httpClient:     &http.Client{},
+		Timeout:        10 * time.Second,
+		MaxRetries:     3,
+		ChatInterval:   defaultChatInterval,
+		GroupInterval:  defaultGroupInterval,
+		GlobalInterval: defaultGlobalInterval,
+		nextChat:       make(map[int64]time.Time),
 	}
+}
+
+// SetBaseURL points the client at another Bot API server, e.g. a local fake in tests.
+func (c *Client) SetBaseURL(baseURL string) {
+	c.baseURL = strings.TrimRight(baseURL, "/")
+}
+
+type Message struct {
+	MessageID int64  `json:"message_id"`
+	From      *User  `json:"from,omitempty"`
+	Chat      Chat   `json:"chat"`
+	Date      int64  `json:"date"`
+	Text      string `json:"text"`
+}
+
+type User struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot,omitempty"`
+	FirstName string `json:"first_name,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+type Chat struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// apiResponse is the envelope of all Bot API responses.
+type apiResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// APIError is an error returned by the Bot API.
+type APIError struct {
+	Method      string
+	Code        int // HTTP-like error code, e.g. 400 or 429
+	Description string
+	RetryAfter  time.Duration // Set for 429 Too Many Requests
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("telegram %s: %d %s", e.Method, e.Code, e.Description)
+}
 
-	// @TODO remove printf debug lines
-	fmt.Printf("URL: %s\n", apiUrl)
-	fmt.Printf("Data: %v\n", message)
+// SendOptions are the optional parameters of SendMessage, SendPhoto and EditMessage.
+type SendOptions struct {
+	ParseMode           ParseMode
+	Keyboard            InlineKeyboard // Attached to the last message
+	DisableNotification bool
+	DisablePreview      bool
+	ReplyTo             int64 // Message the first message replies to, sent without it if it was deleted
+	ThreadID            int64 // Forum topic of the messages
+}
+
+// set adds the options shared by all messages of a send to params.
+func (o *SendOptions) set(params url.Values) {
+	if o.ParseMode != PlainText {
+		params.Set("parse_mode", string(o.ParseMode))
+	}
+	if o.DisableNotification {
+		params.Set("disable_notification", "true")
+	}
+	if o.DisablePreview {
+		params.Set("link_preview_options", `{"is_disabled":true}`)
+	}
+	if o.ThreadID != 0 {
+		params.Set("message_thread_id", strconv.FormatInt(o.ThreadID, 10))
+	}
+}
+
+func (o *SendOptions) setReply(params url.Values) {
+	if o.ReplyTo != 0 {
+		params.Set("reply_parameters", fmt.Sprintf(`{"message_id":%d,"allow_sending_without_reply":true}`, o.ReplyTo))
+	}
+}
+
+// GetMe returns the bot's own user.
+func (c *Client) GetMe() (*User, error) {
+	var u User
+	if err := c.call(context.Background(), "getMe", url.Values{}, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// SendMessage sends text to a chat. Text longer than MaxMessageLength is split into
+// several messages at line breaks; formatted text should not open a tag or entity on one
+// line and close it on another. Opts may be nil.
+func (c *Client) SendMessage(chatID int64, text string, opts *SendOptions) ([]Message, error) {
+	return c.SendMessageContext(context.Background(), chatID, text, opts)
+}
+
+// SendMessageContext is SendMessage with a context that bounds the waits for the
+// flood limits and retries as well as the requests.
+func (c *Client) SendMessageContext(ctx context.Context, chatID int64, text string, opts *SendOptions) ([]Message, error) {
+	if opts == nil {
+		opts = &SendOptions{}
+	}
+
+	parts := SplitMessage(text, MaxMessageLength)
+	sent := make([]Message, 0, len(parts))
+	for i, part := range parts {
+		params := url.Values{}
+		params.Set("chat_id", strconv.FormatInt(chatID, 10))
+		params.Set("text", part)
+		opts.set(params)
+		if i == 0 {
+			opts.setReply(params)
+		}
+		if i == len(parts)-1 {
+			if err := setKeyboard(params, opts.Keyboard); err != nil {
+				return sent, err
+			}
+		}
+
+		if err := c.wait(ctx, chatID); err != nil {
+			return sent, err
+		}
+		var m Message
+		if err := c.call(ctx, "sendMessage", params, &m); err != nil {
+			return sent, err
+		}
+		sent = append(sent, m)
+	}
+	return sent, nil
+}
+
+// MaxCaptionLength is the longest photo caption Telegram accepts.
+const MaxCaptionLength = 1024
+
+// SendPhoto uploads a PNG image to a chat with an optional caption of at most
+// MaxCaptionLength characters. Opts may be nil.
+func (c *Client) SendPhoto(chatID int64, photo []byte, caption string, opts *SendOptions) (*Message, error) {
+	return c.SendPhotoContext(context.Background(), chatID, photo, caption, opts)
+}
 
-	req, err := http.NewRequest(
-		http.MethodPost,
-		apiUrl,
-		strings.NewReader(message.Encode()),
-	)
+// SendPhotoContext is SendPhoto with a context, see SendMessageContext.
+func (c *Client) SendPhotoContext(ctx context.Context, chatID int64, photo []byte, caption string, opts *SendOptions) (*Message, error) {
+	if opts == nil {
+		opts = &SendOptions{}
+	}
+	params := url.Values{}
+	params.Set("chat_id", strconv.FormatInt(chatID, 10))
+	if caption != "" {
+		params.Set("caption", caption)
+	}
+	opts.set(params)
+	opts.setReply(params)
+	if err := setKeyboard(params, opts.Keyboard); err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for key := range params {
+		if err := w.WriteField(key, params.Get(key)); err != nil {
+			return nil, err
+		}
+	}
+	part, err := w.CreateFormFile("photo", "chart.png")
 	if err != nil {
-		log.Printf("Error sending message: %s", err)
-		return "", err
+		return nil, err
+	}
+	if _, err := part.Write(photo); err != nil {
+		return nil, err
 	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := c.wait(ctx, chatID); err != nil {
+		return nil, err
+	}
+	var m Message
+	if err := c.post(ctx, "sendPhoto", w.FormDataContentType(), body.Bytes(), &m, c.Timeout); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// EditMessage replaces the text and keyboard of a sent message; without a keyboard
+// the buttons are removed. Opts may be nil; the reply and thread options are ignored.
+func (c *Client) EditMessage(chatID, messageID int64, text string, opts *SendOptions) error {
+	return c.EditMessageContext(context.Background(), chatID, messageID, text, opts)
+}
 
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+// EditMessageContext is EditMessage with a context, see SendMessageContext.
+func (c *Client) EditMessageContext(ctx context.Context, chatID, messageID int64, text string, opts *SendOptions) error {
+	if opts == nil {
+		opts = &SendOptions{}
+	}
+	params := url.Values{}
+	params.Set("chat_id", strconv.FormatInt(chatID, 10))
+	params.Set("message_id", strconv.FormatInt(messageID, 10))
+	params.Set("text", text)
+	(&SendOptions{ParseMode: opts.ParseMode, DisablePreview: opts.DisablePreview}).set(params)
+	if err := setKeyboard(params, opts.Keyboard); err != nil {
+		return err
+	}
+	if err := c.wait(ctx, chatID); err != nil {
+		return err
+	}
+	return c.call(ctx, "editMessageText", params, nil)
+}
 
-	client := &http.Client{}
-	// TODO create timout.
-	resp, err := client.Do(req)
+func setKeyboard(params url.Values, keyboard InlineKeyboard) error {
+	if keyboard == nil {
+		return nil
+	}
+	markup, err := json.Marshal(map[string]InlineKeyboard{"inline_keyboard": keyboard})
 	if err != nil {
-		log.Printf("Error client do: %s", err)
-		return "", err
+		return err
 	}
-	defer resp.Body.Close()
+	params.Set("reply_markup", string(markup))
+	return nil
+}
+
+// wait blocks until a message may be sent to the chat without exceeding the flood limits
+// or ctx is done.
+func (c *Client) wait(ctx context.Context, chatID int64) error {
+	interval := c.ChatInterval
+	if chatID < 0 {
+		interval = c.GroupInterval
+	}
+
+	c.mu.Lock()
+	now := time.Now()
+	at := now
+	if next := c.nextChat[chatID]; next.After(at) {
+		at = next
+	}
+	if c.nextGlobal.After(at) {
+		at = c.nextGlobal
+	}
+	c.nextChat[chatID] = at.Add(interval)
+	c.nextGlobal = at.Add(c.GlobalInterval)
+	c.mu.Unlock()
+
+	timer := time.NewTimer(at.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// call posts a Bot API method and decodes its result into result (if not nil).
+// 429 responses are retried after the requested delay, 5xx responses after a short backoff.
+func (c *Client) call(ctx context.Context, method string, params url.Values, result interface{}) error {
+	return c.callTimeout(ctx, method, params, result, c.Timeout)
+}
+
+func (c *Client) callTimeout(ctx context.Context, method string, params url.Values, result interface{}, timeout time.Duration) error {
+	return c.post(ctx, method, "application/x-www-form-urlencoded", []byte(params.Encode()), result, timeout)
+}
+
+func (c *Client) post(ctx context.Context, method, contentType string, body []byte, result interface{}, timeout time.Duration) error {
+	for attempt := 0; ; attempt++ {
+		err := c.do(ctx, method, contentType, body, result, timeout)
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || attempt >= c.MaxRetries {
+			return err
+		}
+		var delay time.Duration
+		switch {
+		case apiErr.Code == http.StatusTooManyRequests:
+			delay = apiErr.RetryAfter
+		case apiErr.Code >= 500:
+			delay = time.Duration(attempt+1) * time.Second
+		default:
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, method, contentType string, body []byte, result interface{}, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/bot"+c.token+"/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
 
-	var bodyBytes, errRead = io.ReadAll(resp.Body)
-	if errRead != nil {
-		log.Printf("Error reading response: %s", errRead)
-		return "", errRead
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// The error contains the URL and with it the token.
+		return fmt.Errorf("telegram %s request failed: %w", method, errors.Unwrap(err))
 	}
-	bodyString := string(bodyBytes)
-	log.Printf("Response: %s", bodyString)
+	defer resp.Body.Close()
 
-	return bodyString, nil
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var apiResp apiResponse
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return fmt.Errorf("telegram %s: invalid response (HTTP %d): %w", method, resp.StatusCode, err)
+	}
+	if !apiResp.OK {
+		code := apiResp.ErrorCode
+		if code == 0 {
+			code = resp.StatusCode
+		}
+		return &APIError{
+			Method:      method,
+			Code:        code,
+			Description: apiResp.Description,
+			RetryAfter:  time.Duration(apiResp.Parameters.RetryAfter) * time.Second,
+		}
+	}
+	if result != nil {
+		return json.Unmarshal(apiResp.Result, result)
+	}
+	return nil
 }
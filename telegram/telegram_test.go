@@ -1,56 +1,245 @@
 package telegram
 
 import (
+	"context"
 	"encoding/json"
-	"log"
-	"os"
-	"strconv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
-type Response struct {
-	OK     bool `json:"ok"`
-	Result struct {
-		MessageID int `json:"message_id"`
-		From      struct {
-			ID        int    `json:"id"`
-			IsBot     bool   `json:"is_bot"`
-			FirstName string `json:"first_name"`
-			Username  string `json:"username"`
-		} `json:"from"`
-		Chat struct {
-			ID        int    `json:"id"`
-			FirstName string `json:"first_name"`
-			LastName  string `json:"last_name"`
-			Username  string `json:"username"`
-			Type      string `json:"type"`
-		} `json:"chat"`
-		Date int64  `json:"date"`
-		Text string `json:"text"`
-	} `json:"result"`
+// fakeAPI is a local Bot API server that records the requests it receives.
+// Responses maps a method to the responses it returns in turn; other calls succeed.
+type fakeAPI struct {
+	mu        sync.Mutex
+	requests  []apiRequest
+	responses map[string][]string
+	nextID    int64
+}
+
+type apiRequest struct {
+	Method string
+	Form   url.Values
+	File   []byte // Uploaded photo, if any
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req := apiRequest{Method: path.Base(r.URL.Path)}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if file, _, err := r.FormFile("photo"); err == nil {
+			req.File, _ = io.ReadAll(file)
+			file.Close()
+		}
+	} else if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Form = r.PostForm
+	method := req.Method
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	if queued := f.responses[method]; len(queued) > 0 {
+		f.responses[method] = queued[1:]
+		w.Write([]byte(queued[0]))
+		return
+	}
+
+	var result interface{} = true
+	if method == "sendMessage" || method == "sendPhoto" {
+		f.nextID++
+		result = Message{MessageID: f.nextID, Chat: Chat{ID: 42}, Text: r.PostForm.Get("text")}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "result": result})
+}
+
+// sent returns the texts of the sendMessage requests.
+func (f *fakeAPI) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var texts []string
+	for _, r := range f.requests {
+		if r.Method == "sendMessage" {
+			texts = append(texts, r.Form.Get("text"))
+		}
+	}
+	return texts
+}
+
+func newTestClient(t *testing.T) (*Client, *fakeAPI) {
+	api := &fakeAPI{responses: make(map[string][]string)}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	c := NewClient("123:abc")
+	c.SetBaseURL(server.URL)
+	c.ChatInterval, c.GroupInterval, c.GlobalInterval = 0, 0, 0
+	return c, api
 }
 
 func TestSendMessage(t *testing.T) {
+	c, api := newTestClient(t)
 
-	text := "Runing unit test"
-	chat_id, err := strconv.Atoi(os.Getenv("TELEGRAM_CHAT_ID"))
+	msgs, err := c.SendMessage(42, "<b>Hi</b>", &SendOptions{ParseMode: HTML, Keyboard: InlineKeyboard{{{Text: "OK", Data: "ok:1"}}}})
 	if err != nil {
-		t.Errorf("error converting sting to int", err.Error())
+		t.Fatal(err)
 	}
+	if len(msgs) != 1 || msgs[0].MessageID != 1 || msgs[0].Chat.ID != 42 || msgs[0].Text != "<b>Hi</b>" {
+		t.Fatalf("messages = %+v", msgs)
+	}
+	form := api.requests[0].Form
+	if form.Get("parse_mode") != "HTML" || form.Get("reply_markup") != `{"inline_keyboard":[[{"text":"OK","callback_data":"ok:1"}]]}` {
+		t.Errorf("form = %v", form)
+	}
+}
+
+func TestSendMessageSplitsLongText(t *testing.T) {
+	c, api := newTestClient(t)
 
-	resp, err := SendMessage(text, chat_id))
+	line := strings.Repeat("x", 99)
+	text := strings.TrimSuffix(strings.Repeat(line+"\n", 60), "\n") // 5999 characters
+	msgs, err := c.SendMessage(42, text, &SendOptions{Keyboard: InlineKeyboard{{{Text: "OK", Data: "ok"}}}})
 	if err != nil {
-		t.Errorf("Error sending message to Telegram, got %s", err.Error())
+		t.Fatal(err)
+	}
+	sent := api.sent()
+	if len(msgs) != 2 || len(sent) != 2 {
+		t.Fatalf("sent %d messages, want 2", len(sent))
+	}
+	if strings.Join(sent, "\n") != text || len(sent[0]) != 40*100-1 {
+		t.Errorf("parts have %d and %d characters", len(sent[0]), len(sent[1]))
+	}
+	if api.requests[0].Form.Has("reply_markup") || !api.requests[1].Form.Has("reply_markup") {
+		t.Error("keyboard must only be attached to the last part")
 	}
+}
 
-	var respData Response
-	err = json.Unmarshal([]byte(resp), &respData)
-	if err != nil {
-		t.Errorf("Error unmarshalling response from Telegram, got %s", err.Error())
+func TestRetryAfter(t *testing.T) {
+	c, api := newTestClient(t)
+	api.responses["sendMessage"] = []string{
+		`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 1","parameters":{"retry_after":1}}`,
+	}
+
+	start := time.Now()
+	if _, err := c.SendMessage(42, "hi", nil); err != nil {
+		t.Fatal(err)
 	}
-	// check := strings.Split(resp, "")[4]
-	if text != respData.Result.Text {
-		t.Errorf("Expected response to be %s, got %s", text, respData.Result.Text)
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %s, want at least 1s", elapsed)
+	}
+	if len(api.sent()) != 2 {
+		t.Errorf("sent %d requests, want 2", len(api.sent()))
 	}
 
+	api.responses["sendMessage"] = []string{`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`}
+	_, err := c.SendMessage(7, "hi", nil)
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.Code != 400 || strings.Contains(err.Error(), "123:abc") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestFloodLimit(t *testing.T) {
+	c, _ := newTestClient(t)
+	c.ChatInterval = 100 * time.Millisecond
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := c.SendMessage(42, "hi", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("3 messages to one chat took %s, want at least 200ms", elapsed)
+	}
+
+	start = time.Now()
+	if _, err := c.SendMessage(43, "hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("message to another chat waited %s", elapsed)
+	}
+}
+
+func TestSendMessageContext(t *testing.T) {
+	c, api := newTestClient(t)
+	c.ChatInterval = time.Minute
+	api.responses["sendMessage"] = []string{
+		`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 60","parameters":{"retry_after":60}}`,
+	}
+
+	// Neither the retry after 429 nor the flood limit outlasts the context.
+	for _, step := range []string{"retry", "flood limit"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		start := time.Now()
+		_, err := c.SendMessageContext(ctx, 42, "hi", nil)
+		cancel()
+		if err == nil {
+			t.Fatalf("%s: expected an error", step)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: returned after %s", step, elapsed)
+		}
+	}
+	if len(api.sent()) != 1 {
+		t.Errorf("sent %d requests, want 1", len(api.sent()))
+	}
+}
+
+func TestEscaping(t *testing.T) {
+	if got := EscapeHTML("a < b && c > d"); got != "a &lt; b &amp;&amp; c &gt; d" {
+		t.Errorf("EscapeHTML = %q", got)
+	}
+	if got := EscapeMarkdownV2("PnL: +1.5 (XBT/USD) [ok]_"); got != `PnL: \+1\.5 \(XBT/USD\) \[ok\]\_` {
+		t.Errorf("EscapeMarkdownV2 = %q", got)
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		text  string
+		limit int
+		want  []string
+	}{
+		{"short", 10, []string{"short"}},
+		{"one two three", 8, []string{"one two", "three"}},
+		{"line one\nline two", 12, []string{"line one", "line two"}},
+		{"abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+		{"😀😀😀", 4, []string{"😀😀", "😀"}}, // Emoji take two UTF-16 code units
+	}
+	for _, tt := range tests {
+		got := SplitMessage(tt.text, tt.limit)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("SplitMessage(%q, %d) = %q, want %q", tt.text, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestSendPhoto(t *testing.T) {
+	c, api := newTestClient(t)
+
+	msg, err := c.SendPhoto(42, []byte("\x89PNG"), "<b>Filled</b>", &SendOptions{ParseMode: HTML})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.MessageID != 1 {
+		t.Errorf("message = %+v", msg)
+	}
+	r := api.requests[0]
+	if r.Method != "sendPhoto" || r.Form.Get("chat_id") != "42" || r.Form.Get("caption") != "<b>Filled</b>" ||
+		r.Form.Get("parse_mode") != "HTML" || string(r.File) != "\x89PNG" {
+		t.Errorf("request = %+v", r)
+	}
 }